@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+)
+
+// getACLEntries returns the IDs of the devices and phone numbers the given caller has ACL entries
+// for. A device account is also considered to have an entry for its own device.
+func (h *Handler) getACLEntries(ctx context.Context, authCtx auth.AuthContext) (
+	deviceIDs map[string]struct{}, phoneNumberIDs map[string]struct{}, err error,
+) {
+	deviceIDs = make(map[string]struct{})
+	phoneNumberIDs = make(map[string]struct{})
+	if authCtx.IsDevice() {
+		deviceIDs[authCtx.DeviceID] = struct{}{}
+	}
+
+	acls, err := h.Store.ListACLsByUserID(ctx, authCtx.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, acl := range acls {
+		if acl.PhoneNumberID != "" {
+			phoneNumberIDs[acl.PhoneNumberID] = struct{}{}
+		}
+		if acl.DeviceID != "" {
+			deviceIDs[acl.DeviceID] = struct{}{}
+		}
+	}
+
+	return deviceIDs, phoneNumberIDs, nil
+}
+
+// getAccessiblePhoneNumberIDs resolves the set of phone number IDs whose SMS the given caller may read,
+// following the rules documented on models.ACL:
+//   - a phone number ACL entry grants access to that phone number;
+//   - a device ACL entry grants access to every phone number in Device.PhoneNumberIDs.
+//
+// A device account can additionally always access the phone numbers of its own device.
+func (h *Handler) getAccessiblePhoneNumberIDs(ctx context.Context, authCtx auth.AuthContext) (map[string]struct{}, error) {
+	deviceIDs, phoneNumberIDs, err := h.getACLEntries(ctx, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expand device entries into their phone numbers
+	for id := range deviceIDs {
+		device, err := h.Store.GetDeviceByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if device == nil {
+			logger.Printf("device %s referenced by ACL not found", id)
+			continue
+		}
+		for _, phoneNumberID := range device.PhoneNumberIDs {
+			phoneNumberIDs[phoneNumberID] = struct{}{}
+		}
+	}
+
+	return phoneNumberIDs, nil
+}
+
+// canAccessPhoneNumber reports whether the given caller may read the SMS of the given phone number.
+func (h *Handler) canAccessPhoneNumber(ctx context.Context, authCtx auth.AuthContext, phoneNumberID string) (bool, error) {
+	phoneNumberIDs, err := h.getAccessiblePhoneNumberIDs(ctx, authCtx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := phoneNumberIDs[phoneNumberID]
+	return ok, nil
+}
+
+// canAccessDevice reports whether the given caller may manage the given device, i.e. has an ACL
+// entry for it.
+func (h *Handler) canAccessDevice(ctx context.Context, authCtx auth.AuthContext, targetDeviceID string) (bool, error) {
+	deviceIDs, _, err := h.getACLEntries(ctx, authCtx)
+	if err != nil {
+		return false, err
+	}
+	_, ok := deviceIDs[targetDeviceID]
+	return ok, nil
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	defaultDeviceCommandPageSize = 10
+	maxDeviceCommandPageSize     = 50
+
+	// maxDeviceCommandWait bounds long polling, as API Gateway times out integrations after 29 seconds.
+	maxDeviceCommandWait      = time.Second * 20
+	deviceCommandPollInterval = time.Second
+)
+
+type ListDeviceCommandsResponse struct {
+	Commands []models.DeviceCommand `json:"commands"` // Pending commands, oldest first
+}
+
+type AckDeviceCommandsRequest struct {
+	CommandIDs []string `json:"command_ids"` // IDs of the handled commands
+}
+
+type AckDeviceCommandsResponse struct {
+	Acknowledged []string `json:"acknowledged"` // IDs of the commands that were pending and are now deleted
+}
+
+type OutboundSMSStatusRequest struct {
+	ID     string `json:"id"`              // ID of the outbound SMS
+	Status string `json:"status"`          // One of "sent", "delivered" or "failed"
+	Error  string `json:"error,omitempty"` // Reason of the failure, if the status is "failed"
+}
+
+// handleGetDeviceCommands returns the pending commands of the calling device. If there are none and
+// the wait query parameter is set, it long-polls for up to that many seconds until commands arrive.
+// Commands remain pending until acknowledged, so devices must deduplicate them by ID.
+func (h *Handler) handleGetDeviceCommands(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsDevice() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only devices can receive commands"), nil
+	}
+
+	// Validate query parameters
+	limit := defaultDeviceCommandPageSize
+	if limitParam := request.QueryStringParameters["limit"]; limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 || parsed > maxDeviceCommandPageSize {
+			return response.Error(ctx, 400, response.CodeInvalidRequest,
+				"limit must be between 1 and "+strconv.Itoa(maxDeviceCommandPageSize)), nil
+		}
+		limit = parsed
+	}
+	var wait time.Duration
+	if waitParam := request.QueryStringParameters["wait"]; waitParam != "" {
+		seconds, err := strconv.Atoi(waitParam)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxDeviceCommandWait {
+			return response.Error(ctx, 400, response.CodeInvalidRequest,
+				"wait must be between 0 and "+strconv.Itoa(int(maxDeviceCommandWait.Seconds()))+" seconds"), nil
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	// Poll until there are commands or the wait is over, leaving time to respond before the
+	// invocation times out
+	deadline := time.Now().Add(wait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Add(-deviceCommandPollInterval).Before(deadline) {
+		deadline = ctxDeadline.Add(-deviceCommandPollInterval)
+	}
+	var commands []models.DeviceCommand
+	for {
+		commands, err = h.Store.ListDeviceCommands(ctx, authCtx.DeviceID, limit)
+		if err != nil {
+			logger.Printf("failed to list commands of device %s: %v", authCtx.DeviceID, err)
+			return response.InternalServerError(ctx), nil
+		}
+		if len(commands) > 0 || time.Now().Add(deviceCommandPollInterval).After(deadline) {
+			break
+		}
+		select {
+		case <-time.After(deviceCommandPollInterval):
+		case <-ctx.Done():
+			return response.Error(ctx, 503, response.CodeServiceUnavailable, "Service Unavailable"), nil
+		}
+	}
+
+	return response.JSON(ctx, 200, ListDeviceCommandsResponse{Commands: commands}), nil
+}
+
+// handlePostDeviceCommandsAck deletes the handled commands of the calling device.
+func (h *Handler) handlePostDeviceCommandsAck(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsDevice() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only devices can acknowledge commands"), nil
+	}
+
+	var ackReq AckDeviceCommandsRequest
+	if err := json.Unmarshal([]byte(request.Body), &ackReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if len(ackReq.CommandIDs) == 0 || len(ackReq.CommandIDs) > maxDeviceCommandPageSize {
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"command_ids must contain between 1 and "+strconv.Itoa(maxDeviceCommandPageSize)+" IDs"), nil
+	}
+
+	acknowledged := make([]string, 0, len(ackReq.CommandIDs))
+	for _, commandID := range ackReq.CommandIDs {
+		deleted, err := h.Store.DeleteDeviceCommand(ctx, authCtx.DeviceID, commandID)
+		if err != nil {
+			logger.Printf("failed to delete command %s of device %s: %v", commandID, authCtx.DeviceID, err)
+			return response.InternalServerError(ctx), nil
+		}
+		if deleted {
+			acknowledged = append(acknowledged, commandID)
+		}
+	}
+
+	return response.JSON(ctx, 200, AckDeviceCommandsResponse{Acknowledged: acknowledged}), nil
+}
+
+// handlePostOutboundSMSStatus records the status of an outbound SMS reported by the device sending
+// it. Reporting the current final status again is a no-op, so that devices can safely retry.
+func (h *Handler) handlePostOutboundSMSStatus(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsDevice() {
+		return response.Error(ctx, 403, response.CodeForbidden,
+			"Only devices can report the status of outbound SMS"), nil
+	}
+
+	var statusReq OutboundSMSStatusRequest
+	if err := json.Unmarshal([]byte(request.Body), &statusReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	switch statusReq.Status {
+	case models.OutboundSMSStatusSent, models.OutboundSMSStatusDelivered, models.OutboundSMSStatusFailed:
+	default:
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"status must be one of sent, delivered or failed"), nil
+	}
+
+	// Devices may only report the SMS they were asked to send
+	outboundSMS, err := h.Store.GetOutboundSMSByID(ctx, statusReq.ID)
+	if err != nil {
+		logger.Printf("failed to get outbound SMS by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if outboundSMS == nil || outboundSMS.DeviceID != authCtx.DeviceID {
+		return response.Error(ctx, 404, response.CodeNotFound, "Outbound SMS not found"), nil
+	}
+	if outboundSMS.IsFinal() {
+		if outboundSMS.Status == statusReq.Status {
+			return response.JSON(ctx, 200, outboundSMS), nil
+		}
+		return response.Error(ctx, 409, response.CodeInvalidStatusTransition,
+			"Outbound SMS is already "+outboundSMS.Status), nil
+	}
+
+	errorMessage := ""
+	if statusReq.Status == models.OutboundSMSStatusFailed {
+		errorMessage = statusReq.Error
+	}
+	err = h.Store.UpdateOutboundSMSStatus(ctx, outboundSMS.ID, statusReq.Status, errorMessage)
+	if errors.Is(err, store.ErrConflict) {
+		// A concurrent report made the status final since it was read
+		return response.Error(ctx, 409, response.CodeInvalidStatusTransition,
+			"Outbound SMS already has a final status"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to update status of outbound SMS %s: %v", outboundSMS.ID, err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("outbound SMS %s is %s", outboundSMS.ID, statusReq.Status)
+
+	outboundSMS.Status = statusReq.Status
+	outboundSMS.Error = errorMessage
+	outboundSMS.UpdatedAt = models.FormatTimestamp(time.Now())
+	return response.JSON(ctx, 200, outboundSMS), nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+// staleOutboundSMSStore returns the outbound SMS as they were before a concurrent status report.
+type staleOutboundSMSStore struct {
+	store.Store
+	stale models.OutboundSMS
+}
+
+func (s *staleOutboundSMSStore) GetOutboundSMSByID(ctx context.Context, outboundSMSID string) (*models.OutboundSMS, error) {
+	outboundSMS := s.stale
+	return &outboundSMS, nil
+}
+
+func TestPostOutboundSMSStatusKeepsFinalStatus(t *testing.T) {
+	ctx := context.Background()
+	h, authCtx := newSMSTestHandler(t, &flakyPublisher{})
+	outboundSMS := models.OutboundSMS{
+		ID: "outbound-sms-1", PhoneNumberID: "phone-number-1", DeviceID: "device-1",
+		To: "+15550199", Body: "hello", Status: models.OutboundSMSStatusQueued,
+	}
+	if err := h.Store.PutOutboundSMS(ctx, &outboundSMS); err != nil {
+		t.Fatalf("PutOutboundSMS: %v", err)
+	}
+	report := func(status string) events.APIGatewayProxyResponse {
+		t.Helper()
+		resp, err := h.handlePostOutboundSMSStatus(ctx, authCtx, events.APIGatewayProxyRequest{
+			Body: `{"id":"outbound-sms-1","status":"` + status + `"}`,
+		})
+		if err != nil {
+			t.Fatalf("handlePostOutboundSMSStatus: %v", err)
+		}
+		return resp
+	}
+
+	if resp := report(models.OutboundSMSStatusDelivered); resp.StatusCode != 200 {
+		t.Fatalf("delivered: got %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+	if resp := report(models.OutboundSMSStatusDelivered); resp.StatusCode != 200 {
+		t.Errorf("delivered again: got %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+	checkProblem(t, report(models.OutboundSMSStatusFailed), 409, response.CodeInvalidStatusTransition)
+
+	// A report racing with the delivery read the SMS before it was delivered
+	h.Store = &staleOutboundSMSStore{Store: h.Store, stale: outboundSMS}
+	checkProblem(t, report(models.OutboundSMSStatusFailed), 409, response.CodeInvalidStatusTransition)
+
+	stored, err := h.Store.(*staleOutboundSMSStore).Store.GetOutboundSMSByID(ctx, "outbound-sms-1")
+	if err != nil || stored == nil {
+		t.Fatalf("GetOutboundSMSByID: got %+v, %v", stored, err)
+	}
+	if stored.Status != models.OutboundSMSStatusDelivered {
+		t.Errorf("got status %s, want delivered", stored.Status)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+const maxAppVersionLength = 64
+
+type DeviceHeartbeatRequest struct {
+	BatteryLevel *int   `json:"battery_level,omitempty"` // Battery level in percent, from 0 to 100
+	Charging     bool   `json:"charging,omitempty"`      // Whether the device is charging
+	SignalLevel  *int   `json:"signal_level,omitempty"`  // Cellular signal level in bars, from 0 to 4
+	SIMState     string `json:"sim_state,omitempty"`     // State of the SIM, e.g. "ready" or "absent"
+	AppVersion   string `json:"app_version,omitempty"`   // Version of the relay app
+}
+
+type DeviceHeartbeatResponse struct {
+	LastSeenAt string `json:"last_seen_at"` // Timestamp of the recorded heartbeat
+}
+
+// handlePostDeviceHeartbeat records a heartbeat of the calling device along with its reported
+// status. Devices missing heartbeats are reported offline by the monitor package.
+func (h *Handler) handlePostDeviceHeartbeat(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsDevice() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only devices can send heartbeats"), nil
+	}
+
+	// Validate and parse the request body
+	var heartbeatReq DeviceHeartbeatRequest
+	if err := json.Unmarshal([]byte(request.Body), &heartbeatReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if level := heartbeatReq.BatteryLevel; level != nil && (*level < 0 || *level > 100) {
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"battery_level must be between 0 and 100"), nil
+	}
+	if level := heartbeatReq.SignalLevel; level != nil && (*level < 0 || *level > 4) {
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"signal_level must be between 0 and 4"), nil
+	}
+	if heartbeatReq.SIMState != "" && !models.IsValidSIMState(heartbeatReq.SIMState) {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "Invalid sim_state"), nil
+	}
+	if len(heartbeatReq.AppVersion) > maxAppVersionLength {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "app_version is too long"), nil
+	}
+
+	device, err := h.Store.GetDeviceByID(ctx, authCtx.DeviceID)
+	if err != nil {
+		logger.Printf("failed to get device by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if device == nil {
+		return response.Error(ctx, 404, response.CodeNotFound, "Device not found"), nil
+	}
+
+	lastSeenAt := models.FormatTimestamp(time.Now())
+	status := models.DeviceStatus{
+		BatteryLevel: heartbeatReq.BatteryLevel,
+		Charging:     heartbeatReq.Charging,
+		SignalLevel:  heartbeatReq.SignalLevel,
+		SIMState:     heartbeatReq.SIMState,
+		AppVersion:   heartbeatReq.AppVersion,
+	}
+	if err := h.Store.UpdateDeviceHeartbeat(ctx, authCtx.DeviceID, status, lastSeenAt); err != nil {
+		logger.Printf("failed to record heartbeat of device %s: %v", authCtx.DeviceID, err)
+		return response.InternalServerError(ctx), nil
+	}
+
+	return response.JSON(ctx, 200, DeviceHeartbeatResponse{LastSeenAt: lastSeenAt}), nil
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+type DeviceRequest struct {
+	Name string `json:"name"` // Displayed name of the device
+}
+
+type ListDevicesResponse struct {
+	Devices []models.Device `json:"devices"`
+}
+
+func (h *Handler) handleListDevices(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	deviceIDs, _, err := h.getACLEntries(ctx, authCtx)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	devices := []models.Device{}
+	for id := range deviceIDs {
+		device, err := h.Store.GetDeviceByID(ctx, id)
+		if err != nil {
+			logger.Printf("failed to get device by ID: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		if device != nil {
+			devices = append(devices, *device)
+		}
+	}
+	slices.SortFunc(devices, func(a, b models.Device) int {
+		return cmp.Or(strings.Compare(a.CreatedAt, b.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	return response.JSON(ctx, 200, ListDevicesResponse{Devices: devices}), nil
+}
+
+// handlePostDevice creates a device owned by the calling user, along with its device user, like
+// pairing does but returning the device's credentials to the user.
+func (h *Handler) handlePostDevice(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage devices"), nil
+	}
+	name, errResp := parseDeviceRequest(ctx, request)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	enrollment, err := newDeviceEnrollment(authCtx.UserID, name, time.Now())
+	if err != nil {
+		logger.Printf("failed to create device: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if err := h.Store.CreateDevice(ctx, &enrollment.Device, &enrollment.User, &enrollment.ACL); err != nil {
+		logger.Printf("failed to save device: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s created device %s", authCtx.UserID, enrollment.Device.ID)
+
+	return h.newEnrollDeviceResponse(ctx, enrollment)
+}
+
+func (h *Handler) handleGetDevice(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	targetID := request.PathParameters["id"]
+	device, errResp := h.getAccessibleDevice(ctx, authCtx, targetID)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 200, device), nil
+}
+
+// handlePatchDevice renames a device.
+func (h *Handler) handlePatchDevice(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	targetID := request.PathParameters["id"]
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage devices"), nil
+	}
+	if _, errResp := h.getAccessibleDevice(ctx, authCtx, targetID); errResp != nil {
+		return *errResp, nil
+	}
+	name, errResp := parseDeviceRequest(ctx, request)
+	if errResp != nil {
+		return *errResp, nil
+	}
+
+	err = h.Store.UpdateDeviceName(ctx, targetID, name)
+	if errors.Is(err, store.ErrConflict) {
+		return response.Error(ctx, 404, response.CodeNotFound, "Device not found"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to rename device: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+
+	return h.handleGetDevice(ctx, authCtx, request)
+}
+
+// handleDeleteDevice deletes a device and its device users. Its phone numbers must be detached
+// first, so that they aren't left attached to a missing device.
+func (h *Handler) handleDeleteDevice(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	targetID := request.PathParameters["id"]
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage devices"), nil
+	}
+	if _, errResp := h.getAccessibleDevice(ctx, authCtx, targetID); errResp != nil {
+		return *errResp, nil
+	}
+
+	err = h.Store.DeleteDevice(ctx, targetID)
+	if errors.Is(err, store.ErrConflict) {
+		return response.Error(ctx, 409, response.CodeDeviceHasPhoneNumbers,
+			"Detach the phone numbers of the device before deleting it"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to delete device: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s deleted device %s", authCtx.UserID, targetID)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 204,
+	}, nil
+}
+
+// handlePutDevicePhoneNumber attaches a phone number to a device, which then relays its SMS and
+// sends its outbound SMS.
+func (h *Handler) handlePutDevicePhoneNumber(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	targetID, phoneNumberID := request.PathParameters["id"], request.PathParameters["phoneNumberID"]
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage devices"), nil
+	}
+	if _, errResp := h.getAccessibleDevice(ctx, authCtx, targetID); errResp != nil {
+		return *errResp, nil
+	}
+	if _, errResp := h.getAccessiblePhoneNumber(ctx, authCtx, phoneNumberID); errResp != nil {
+		return *errResp, nil
+	}
+
+	err = h.Store.AttachPhoneNumber(ctx, targetID, phoneNumberID)
+	if errors.Is(err, store.ErrConflict) {
+		return response.Error(ctx, 409, response.CodePhoneNumberAttached,
+			"Phone number is attached to another device, or was modified concurrently"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to attach phone number: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s attached phone number %s to device %s", authCtx.UserID, phoneNumberID, targetID)
+
+	return h.handleGetDevice(ctx, authCtx, request)
+}
+
+// handleDeleteDevicePhoneNumber detaches a phone number from a device.
+func (h *Handler) handleDeleteDevicePhoneNumber(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	targetID, phoneNumberID := request.PathParameters["id"], request.PathParameters["phoneNumberID"]
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage devices"), nil
+	}
+	if _, errResp := h.getAccessibleDevice(ctx, authCtx, targetID); errResp != nil {
+		return *errResp, nil
+	}
+
+	err = h.Store.DetachPhoneNumber(ctx, targetID, phoneNumberID)
+	if errors.Is(err, store.ErrConflict) {
+		return response.Error(ctx, 409, response.CodeConflict,
+			"Phone number was not found, or was modified concurrently"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to detach phone number: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s detached phone number %s from device %s", authCtx.UserID, phoneNumberID, targetID)
+
+	return h.handleGetDevice(ctx, authCtx, request)
+}
+
+// getAccessibleDevice returns the device with the given ID if the given caller has access to it, or
+// the error response to return otherwise. Inaccessible devices are reported as not found.
+func (h *Handler) getAccessibleDevice(ctx context.Context, authCtx auth.AuthContext, targetID string) (
+	*models.Device, *events.APIGatewayProxyResponse,
+) {
+	allowed, err := h.canAccessDevice(ctx, authCtx, targetID)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		errResp := response.InternalServerError(ctx)
+		return nil, &errResp
+	}
+	var device *models.Device
+	if allowed {
+		device, err = h.Store.GetDeviceByID(ctx, targetID)
+		if err != nil {
+			logger.Printf("failed to get device by ID: %v", err)
+			errResp := response.InternalServerError(ctx)
+			return nil, &errResp
+		}
+	}
+	if device == nil {
+		errResp := response.Error(ctx, 404, response.CodeNotFound, "Device not found")
+		return nil, &errResp
+	}
+	return device, nil
+}
+
+// parseDeviceRequest returns the validated name of the device in the request body, or the error
+// response to return.
+func parseDeviceRequest(ctx context.Context, request events.APIGatewayProxyRequest) (string, *events.APIGatewayProxyResponse) {
+	var deviceReq DeviceRequest
+	if err := json.Unmarshal([]byte(request.Body), &deviceReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		errResp := response.InvalidBody(ctx)
+		return "", &errResp
+	}
+	name := strings.TrimSpace(deviceReq.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxDeviceNameLength {
+		errResp := response.Error(ctx, 400, response.CodeInvalidRequest,
+			"name is required and must be at most 64 characters")
+		return "", &errResp
+	}
+	return name, nil
+}
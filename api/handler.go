@@ -0,0 +1,115 @@
+// Package api implements the SMS relay REST API on top of API Gateway proxy events, so that it can
+// be served both by the sms-relay-api-handler Lambda and by the standalone server.
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/queue"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/router"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	idempotencyRecordTTL = time.Hour * 24 // 1 day
+
+	accessTokenValidityDuration  = time.Minute * 15    // 15 minutes
+	refreshTokenValidityDuration = time.Hour * 24 * 30 // 30 days
+)
+
+var logger = log.Default()
+
+// Handler serves the API requests. The authorizer context of each request must already have been
+// populated from a validated token, except for the public /login, /token/refresh, /devices/pair and
+// /.well-known/jwks.json routes.
+type Handler struct {
+	Store   store.Store
+	Secrets common.SecretsProvider
+	Queue   queue.Publisher // Queue of the SMS relay requests consumed by the forwarder
+
+	routerOnce sync.Once
+	router     *router.Router
+}
+
+// Handle processes an API Gateway request and routes it to the appropriate function based on
+// the request method and path.
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	h.routerOnce.Do(func() {
+		h.router = h.newRouter()
+	})
+	return h.router.Serve(ctx, request)
+}
+
+// newRouter returns the router of the API routes. The routes other than /login, /token/refresh,
+// /devices/pair and /.well-known/jwks.json are authenticated, requiring the authorizer context of a
+// validated token.
+func (h *Handler) newRouter() *router.Router {
+	r := router.New()
+	r.Use(router.RequestLogger, router.Recoverer)
+
+	r.Handle("POST", "/login", h.handlePostLogin)
+	r.Handle("POST", "/token/refresh", h.handlePostTokenRefresh)
+	r.Handle("POST", "/devices/pair", h.handlePostPairDevice)
+	r.Handle("GET", "/.well-known/jwks.json", h.handleGetJWKS)
+
+	r.Handle("POST", "/logout", authenticated(h.handlePostLogout))
+	r.Handle("GET", "/user", authenticated(h.handleGetUser))
+	r.Handle("GET", "/sms", authenticated(h.handleGetSMS))
+	r.Handle("POST", "/sms", authenticated(h.handlePostSMS))
+	r.Handle("GET", "/sms/outbound", authenticated(h.handleGetOutboundSMS))
+	r.Handle("POST", "/sms/outbound", authenticated(h.handlePostOutboundSMS))
+
+	// Routes of the calling device
+	r.Handle("GET", "/device/commands", authenticated(h.handleGetDeviceCommands))
+	r.Handle("POST", "/device/commands/ack", authenticated(h.handlePostDeviceCommandsAck))
+	r.Handle("POST", "/device/outbound-sms/status", authenticated(h.handlePostOutboundSMSStatus))
+	r.Handle("POST", "/device/heartbeat", authenticated(h.handlePostDeviceHeartbeat))
+
+	// Management of devices and phone numbers
+	r.Handle("POST", "/devices/pairing-codes", authenticated(h.handlePostPairingCode))
+	r.Handle("GET", "/devices", authenticated(h.handleListDevices))
+	r.Handle("POST", "/devices", authenticated(h.handlePostDevice))
+	r.Handle("GET", "/devices/{id}", authenticated(h.handleGetDevice))
+	r.Handle("PATCH", "/devices/{id}", authenticated(h.handlePatchDevice))
+	r.Handle("DELETE", "/devices/{id}", authenticated(h.handleDeleteDevice))
+	r.Handle("PUT", "/devices/{id}/phone-numbers/{phoneNumberID}", authenticated(h.handlePutDevicePhoneNumber))
+	r.Handle("DELETE", "/devices/{id}/phone-numbers/{phoneNumberID}", authenticated(h.handleDeleteDevicePhoneNumber))
+	r.Handle("GET", "/phone-numbers", authenticated(h.handleListPhoneNumbers))
+	r.Handle("POST", "/phone-numbers", authenticated(h.handlePostPhoneNumber))
+	r.Handle("GET", "/phone-numbers/{id}", authenticated(h.handleGetPhoneNumber))
+	r.Handle("PATCH", "/phone-numbers/{id}", authenticated(h.handlePatchPhoneNumber))
+	r.Handle("DELETE", "/phone-numbers/{id}", authenticated(h.handleDeletePhoneNumber))
+	r.Handle("GET", "/phone-numbers/{id}/forward-destinations", authenticated(h.handleGetForwardDestinations))
+	r.Handle("PUT", "/phone-numbers/{id}/forward-destinations", authenticated(h.handlePutForwardDestinations))
+	r.Handle("POST", "/phone-numbers/{id}/forward-destinations", authenticated(h.handlePostForwardDestination))
+	r.Handle("DELETE", "/phone-numbers/{id}/forward-destinations/{index}", authenticated(h.handleDeleteForwardDestination))
+
+	return r
+}
+
+// authHandlerFunc handles a request authenticated by a validated token, whose caller is given by
+// authCtx.
+type authHandlerFunc func(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	events.APIGatewayProxyResponse, error,
+)
+
+// authenticated adapts a handler of authenticated requests to a route handler. It parses the
+// authorizer context of the validated token, which API Gateway and the standalone server only
+// populate for the routes that aren't public, and rejects the requests without a valid one.
+func authenticated(fn authHandlerFunc) router.HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		authCtx, err := auth.ParseAuthorizerContext(request.RequestContext.Authorizer)
+		if err != nil {
+			logger.Printf("invalid authorization context of %s %s: %v", request.HTTPMethod, request.Path, err)
+			return response.Error(ctx, 401, response.CodeUnauthorized, "Unauthorized"), nil
+		}
+		return fn(ctx, authCtx, request)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 128
+)
+
+// getIdempotencyKey returns the idempotency key of an SMS request, taken from the Idempotency-Key
+// header or else from the client message ID in the body. It returns an empty string if neither is set.
+func getIdempotencyKey(request events.APIGatewayProxyRequest, smsReq SMSRequest) string {
+	for name, value := range request.Headers {
+		if http.CanonicalHeaderKey(name) == idempotencyKeyHeader && value != "" {
+			return value
+		}
+	}
+	return smsReq.ClientMessageID
+}
+
+// beginIdempotentRequest claims the idempotency key of a device. If the key was already claimed, it
+// returns the response to replay instead: the original response if the first request completed, or
+// a 409 if it is still in progress. Otherwise it returns the ID of the claimed record, which must
+// be passed to finishIdempotentRequest, and the ID of the SMS to create: smsID for a new key, or
+// the ID claimed by a failed attempt being retried, which may already have stored its SMS.
+func (h *Handler) beginIdempotentRequest(ctx context.Context, deviceID, key, smsID string) (
+	recordID string, claimedSMSID string, replay *events.APIGatewayProxyResponse, err error,
+) {
+	now := time.Now()
+	recordID = "sms#" + deviceID + "#" + key
+	created, err := h.Store.CreateIdempotencyRecord(ctx, &models.IdempotencyRecord{
+		ID:        recordID,
+		Status:    models.IdempotencyStatusPending,
+		SMSID:     smsID,
+		CreatedAt: models.FormatTimestamp(now),
+		ExpiresAt: now.Add(idempotencyRecordTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	if created {
+		return recordID, smsID, nil, nil
+	}
+
+	// The key was already claimed, replay the original response if there is one
+	record, err := h.Store.GetIdempotencyRecord(ctx, recordID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if record != nil && record.Status == models.IdempotencyStatusCompleted {
+		logger.Printf("replaying response of SMS %s for idempotency key %s", record.SMSID, key)
+		return "", "", &events.APIGatewayProxyResponse{
+			StatusCode: record.StatusCode,
+			Headers:    record.ResponseHeaders,
+			Body:       record.ResponseBody,
+		}, nil
+	}
+	if record != nil && record.Status == models.IdempotencyStatusFailed {
+		retried, err := h.Store.RetryIdempotencyRecord(ctx, recordID)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if retried {
+			logger.Printf("retrying SMS %s for idempotency key %s", record.SMSID, key)
+			return recordID, record.SMSID, nil, nil
+		}
+	}
+	logger.Printf("request with idempotency key %s is already in progress", key)
+	errResp := response.Error(ctx, 409, response.CodeRequestInProgress,
+		"A request with the same idempotency key is in progress")
+	return "", "", &errResp, nil
+}
+
+// finishIdempotentRequest records a successful response for replay, or marks the claim on the
+// idempotency key as failed if the request failed so that it can be retried with the same SMS ID.
+func (h *Handler) finishIdempotentRequest(ctx context.Context, recordID string, resp events.APIGatewayProxyResponse) {
+	if resp.StatusCode == 200 {
+		err := h.Store.CompleteIdempotencyRecord(ctx, recordID, resp.StatusCode, resp.Headers, resp.Body)
+		if err != nil {
+			logger.Printf("failed to complete idempotency record %s: %v", recordID, err)
+		}
+		return
+	}
+	if err := h.Store.FailIdempotencyRecord(ctx, recordID); err != nil {
+		logger.Printf("failed to release idempotency record %s: %v", recordID, err)
+	}
+}
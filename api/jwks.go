@@ -0,0 +1,27 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+const jwksMaxAge = 300 // Seconds clients may cache the JWKS for, like the authenticator
+
+// handleGetJWKS returns the public keys of the asymmetric JWT signing keys, for other services to
+// validate tokens without access to the JWT secret.
+func (h *Handler) handleGetJWKS(ctx context.Context, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	keySet, err := auth.GetKeySet(ctx, h.Secrets)
+	if err != nil {
+		logger.Printf("failed to retrieve JWT secret: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	resp = response.JSON(ctx, 200, keySet.JWKS())
+	resp.Headers["Cache-Control"] = fmt.Sprintf("public, max-age=%d", jwksMaxAge)
+	return resp, nil
+}
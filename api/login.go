@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	User *models.User `json:"user,omitempty"`
+	TokenResponse
+}
+
+func (h *Handler) handlePostLogin(ctx context.Context, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	// Validate and parse the request body
+	var loginReq LoginRequest
+	if err := json.Unmarshal([]byte(request.Body), &loginReq); err != nil {
+		logger.Printf("error unmarshalling login request: %v\n", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if loginReq.Username == "" || loginReq.Password == "" {
+		logger.Println("username or password is empty")
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"Username and password are required"), nil
+	}
+
+	// Fetch user from DynamoDB
+	user, err := h.Store.GetUserByUsername(ctx, loginReq.Username)
+	if err != nil {
+		logger.Println("error fetching user")
+		return response.InternalServerError(ctx), nil
+	}
+	if user == nil {
+		logger.Println("user not found")
+		return response.Error(ctx, 404, response.CodeInvalidCredentials,
+			"Username or password is incorrect"), nil
+	}
+
+	// Validate password
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginReq.Password))
+	if err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			logger.Println("password not matched")
+		} else {
+			logger.Println("error validating password")
+		}
+		return response.Error(ctx, 401, response.CodeInvalidCredentials,
+			"Username or password is incorrect"), nil
+	}
+
+	// Generate the tokens of a new session
+	tokens, err := h.issueTokens(ctx, user, uuid.NewString())
+	if err != nil {
+		logger.Printf("error generating tokens: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+
+	// Generate and return the response
+	logger.Printf("user %s logged in successfully\n", user.Username)
+	return response.JSON(ctx, 200, LoginResponse{
+		User:          user,
+		TokenResponse: tokens,
+	}), nil
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/outbound"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+type OutboundSMSRequest struct {
+	PhoneNumberID string `json:"phone_number_id"` // ID of the phone number to send from
+	To            string `json:"to"`              // Phone number of the recipient, in E.164 format
+	Body          string `json:"body"`            // Content of the SMS message
+}
+
+// handlePostOutboundSMS queues an SMS composed by the user to be sent by the device the chosen phone
+// number is attached to. The device picks it up from its command queue and reports its status.
+func (h *Handler) handlePostOutboundSMS(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	// Validate and parse the request body
+	var outboundReq OutboundSMSRequest
+	if err := json.Unmarshal([]byte(request.Body), &outboundReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if outboundReq.PhoneNumberID == "" || outboundReq.To == "" || outboundReq.Body == "" {
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"phone_number_id, to and body are required"), nil
+	}
+	outboundSMSReq := outbound.Request{
+		To:     outboundReq.To,
+		Body:   outboundReq.Body,
+		UserID: authCtx.UserID,
+	}
+	if err := outboundSMSReq.Validate(); err != nil {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, err.Error()), nil
+	}
+
+	// Enforce the ACL of the phone number
+	allowed, err := h.canAccessPhoneNumber(ctx, authCtx, outboundReq.PhoneNumberID)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if !allowed {
+		logger.Printf("user %s is not allowed to send from phone number %s", authCtx.UserID, outboundReq.PhoneNumberID)
+		return response.Error(ctx, 403, response.CodeForbidden, "Access to the phone number is denied"), nil
+	}
+
+	// Find the device sending from the phone number
+	phoneNumber, err := h.Store.GetPhoneNumberByID(ctx, outboundReq.PhoneNumberID)
+	if err != nil {
+		logger.Printf("failed to get phone number by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if phoneNumber == nil {
+		return response.Error(ctx, 404, response.CodeNotFound, "Phone number not found"), nil
+	}
+	// Queue the SMS on the device the phone number is attached to
+	outboundSMSReq.PhoneNumber = phoneNumber
+	outboundSMS, err := outbound.QueueSMS(ctx, h.Store, outboundSMSReq)
+	if errors.Is(err, outbound.ErrNotAttached) {
+		logger.Printf("phone number %s is not attached to a device", phoneNumber.ID)
+		return response.Error(ctx, 409, response.CodePhoneNumberNotAttached,
+			"Phone number is not attached to a device"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to queue outbound SMS: %v", err)
+		return response.Error(ctx, 500, response.CodeInternalError, "Failed to send message"), nil
+	}
+	logger.Printf("outbound SMS %s queued for device %s", outboundSMS.ID, outboundSMS.DeviceID)
+
+	return response.JSON(ctx, 202, outboundSMS), nil
+}
+
+// handleGetOutboundSMS returns the outbound SMS given by the id query parameter, including its
+// delivery status.
+func (h *Handler) handleGetOutboundSMS(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	outboundSMSID := request.QueryStringParameters["id"]
+	if outboundSMSID == "" {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "id is required"), nil
+	}
+
+	outboundSMS, err := h.Store.GetOutboundSMSByID(ctx, outboundSMSID)
+	if err != nil {
+		logger.Printf("failed to get outbound SMS by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if outboundSMS == nil {
+		return response.Error(ctx, 404, response.CodeNotFound, "Outbound SMS not found"), nil
+	}
+
+	// Hide the SMS from users without access to its phone number
+	allowed, err := h.canAccessPhoneNumber(ctx, authCtx, outboundSMS.PhoneNumberID)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if !allowed {
+		return response.Error(ctx, 404, response.CodeNotFound, "Outbound SMS not found"), nil
+	}
+
+	return response.JSON(ctx, 200, outboundSMS), nil
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	pairingCodeValidity = time.Minute * 10
+
+	// Pairing codes are 10 characters of Crockford's base32, i.e. 50 random bits, which leaves no
+	// realistic chance of guessing one of the few codes valid at any time.
+	pairingCodeLength   = 10
+	pairingCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+	devicePasswordLength = 32 // Random bytes of generated device passwords
+	maxDeviceNameLength  = 64
+)
+
+// pairingCodeNormalizer maps the characters users may type in place of those of the alphabet, and
+// drops the separators, so that codes are accepted however they were transcribed.
+var pairingCodeNormalizer = strings.NewReplacer("I", "1", "L", "1", "O", "0", "-", "", " ", "")
+
+type CreatePairingCodeRequest struct {
+	DeviceName string `json:"device_name"` // Displayed name of the device to enroll
+}
+
+type CreatePairingCodeResponse struct {
+	Code       string `json:"code"`        // One-time code to enter on the device, e.g. "7KQ2M-X9DPA"
+	PairingURI string `json:"pairing_uri"` // URI carrying the server URL and the code, to show as a QR code
+	ExpiresAt  string `json:"expires_at"`  // RFC 3339 timestamp after which the code can't be used
+}
+
+type PairDeviceRequest struct {
+	Code string `json:"code"` // Pairing code issued by POST /devices/pairing-codes
+}
+
+// EnrollDeviceResponse is returned when a device is created, by pairing or by its owner.
+type EnrollDeviceResponse struct {
+	Device        *models.Device `json:"device"`
+	User          *models.User   `json:"user"`     // Device user to log in as
+	Password      string         `json:"password"` // Generated password of the device user, only returned once
+	TokenResponse                // Tokens of the device user, as returned by /login
+}
+
+// handlePostPairingCode issues a one-time code enrolling a new device owned by the calling user.
+func (h *Handler) handlePostPairingCode(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can enroll devices"), nil
+	}
+
+	// Validate and parse the request body
+	var codeReq CreatePairingCodeRequest
+	if err := json.Unmarshal([]byte(request.Body), &codeReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	codeReq.DeviceName = strings.TrimSpace(codeReq.DeviceName)
+	if codeReq.DeviceName == "" || utf8.RuneCountInString(codeReq.DeviceName) > maxDeviceNameLength {
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"device_name is required and must be at most 64 characters"), nil
+	}
+
+	code := newPairingCode()
+	now := time.Now()
+	expiresAt := now.Add(pairingCodeValidity)
+	pairingCode := models.PairingCode{
+		ID:         hashPairingCode(code),
+		UserID:     authCtx.UserID,
+		DeviceName: codeReq.DeviceName,
+		CreatedAt:  models.FormatTimestamp(now),
+		ExpiresAt:  expiresAt.Unix(),
+	}
+	if err := h.Store.PutPairingCode(ctx, &pairingCode); err != nil {
+		logger.Printf("failed to save pairing code: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s issued a pairing code for device %q", authCtx.UserID, codeReq.DeviceName)
+
+	displayedCode := code[:pairingCodeLength/2] + "-" + code[pairingCodeLength/2:]
+	pairingURI := url.URL{
+		Scheme: "sms-relay",
+		Host:   "pair",
+		RawQuery: url.Values{
+			"server": {apiBaseURL(request)},
+			"code":   {displayedCode},
+		}.Encode(),
+	}
+	return response.JSON(ctx, 201, CreatePairingCodeResponse{
+		Code:       displayedCode,
+		PairingURI: pairingURI.String(),
+		ExpiresAt:  expiresAt.UTC().Format(time.RFC3339),
+	}), nil
+}
+
+// handlePostPairDevice exchanges a pairing code for a new device and its device user, whose
+// credentials are returned to the device. The code can only be used once.
+func (h *Handler) handlePostPairDevice(ctx context.Context, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	// Validate and parse the request body
+	var pairReq PairDeviceRequest
+	if err := json.Unmarshal([]byte(request.Body), &pairReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if pairReq.Code == "" {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "code is required"), nil
+	}
+	invalidCode := response.Error(ctx, 404, response.CodeInvalidPairingCode,
+		"Pairing code is invalid or expired")
+	code := pairingCodeNormalizer.Replace(strings.ToUpper(pairReq.Code))
+	if len(code) != pairingCodeLength {
+		return invalidCode, nil
+	}
+	codeID := hashPairingCode(code)
+
+	pairingCode, err := h.Store.GetPairingCode(ctx, codeID)
+	if err != nil {
+		logger.Printf("failed to get pairing code: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	now := time.Now()
+	if pairingCode == nil || pairingCode.ExpiresAt <= now.Unix() {
+		logger.Println("pairing code not found or expired")
+		return invalidCode, nil
+	}
+
+	// Create the device, its user with a random password, and the owner's ACL entry
+	enrollment, err := newDeviceEnrollment(pairingCode.UserID, pairingCode.DeviceName, now)
+	if err != nil {
+		logger.Printf("failed to create device: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	paired, err := h.Store.CompletePairing(ctx, codeID, &enrollment.Device, &enrollment.User, &enrollment.ACL)
+	if err != nil {
+		logger.Printf("failed to complete pairing: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if !paired {
+		logger.Println("pairing code was used concurrently or expired")
+		return invalidCode, nil
+	}
+	logger.Printf("device %s paired for user %s", enrollment.Device.ID, pairingCode.UserID)
+
+	return h.newEnrollDeviceResponse(ctx, enrollment)
+}
+
+// deviceEnrollment holds the models created to enroll a new device.
+type deviceEnrollment struct {
+	Device   models.Device
+	User     models.User // Device user the device logs in as
+	ACL      models.ACL  // ACL entry of the owner of the device
+	Password string      // Generated password of the device user
+}
+
+// newDeviceEnrollment creates the models of a new device named name, owned by the given user.
+func newDeviceEnrollment(ownerID string, name string, now time.Time) (*deviceEnrollment, error) {
+	password := base64.RawURLEncoding.EncodeToString(randomBytes(devicePasswordLength))
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := models.FormatTimestamp(now)
+	deviceID := uuid.NewString()
+	return &deviceEnrollment{
+		Device: models.Device{
+			ID:             deviceID,
+			Name:           name,
+			PhoneNumberIDs: []string{},
+			CreatedAt:      timestamp,
+			UpdatedAt:      timestamp,
+		},
+		User: models.User{
+			ID:        uuid.NewString(),
+			Username:  "device-" + deviceID,
+			Password:  string(passwordHash),
+			UserType:  models.UserTypeDevice,
+			Name:      name,
+			DeviceID:  deviceID,
+			CreatedAt: timestamp,
+			UpdatedAt: timestamp,
+		},
+		ACL: models.ACL{
+			ID:        uuid.NewString(),
+			UserID:    ownerID,
+			DeviceID:  deviceID,
+			CreatedAt: timestamp,
+			UpdatedAt: timestamp,
+		},
+		Password: password,
+	}, nil
+}
+
+// newEnrollDeviceResponse logs the enrolled device in, so that it can start relaying right away.
+func (h *Handler) newEnrollDeviceResponse(
+	ctx context.Context, enrollment *deviceEnrollment,
+) (events.APIGatewayProxyResponse, error) {
+	tokens, err := h.issueTokens(ctx, &enrollment.User, uuid.NewString())
+	if err != nil {
+		logger.Printf("error generating tokens: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	return response.JSON(ctx, 201, EnrollDeviceResponse{
+		Device:        &enrollment.Device,
+		User:          &enrollment.User,
+		Password:      enrollment.Password,
+		TokenResponse: tokens,
+	}), nil
+}
+
+// newPairingCode returns a random code of pairingCodeLength characters of pairingCodeAlphabet.
+func newPairingCode() string {
+	code := randomBytes(pairingCodeLength)
+	for i, b := range code {
+		code[i] = pairingCodeAlphabet[int(b)%len(pairingCodeAlphabet)] // 256 is a multiple of 32, so unbiased
+	}
+	return string(code)
+}
+
+// hashPairingCode returns the ID under which a normalized pairing code is stored.
+func hashPairingCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b) // Never returns an error
+	return b
+}
+
+// apiBaseURL returns the URL the API was called at, for clients to call it again, e.g.
+// "https://abc123.execute-api.us-west-2.amazonaws.com/prod".
+func apiBaseURL(request events.APIGatewayProxyRequest) string {
+	scheme := "http"
+	if proto := request.Headers["X-Forwarded-Proto"]; proto != "" {
+		scheme = proto
+	}
+	host := request.Headers["Host"]
+	if request.RequestContext.DomainName != "" {
+		host = request.RequestContext.DomainName
+	}
+	baseURL := scheme + "://" + host
+	if stage := request.RequestContext.Stage; stage != "" && strings.Contains(host, ".execute-api.") {
+		baseURL += "/" + stage
+	}
+	return baseURL
+}
@@ -0,0 +1,368 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/forwarder"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	maxPhoneNumberNameLength = 64
+	maxForwardDestinations   = 20
+)
+
+// errForwardDestinationNotFound is returned by updates of forward destinations at missing indexes.
+var errForwardDestinationNotFound = errors.New("forward destination not found")
+
+// PhoneNumberRequest creates a phone number, or updates the fields set of an existing one.
+type PhoneNumberRequest struct {
+	PhoneNumber         *string                     `json:"phone_number,omitempty"`         // Phone number in E.164 format, required on creation
+	Name                *string                     `json:"name,omitempty"`                 // Displayed name of the phone number
+	ForwardDestinations *models.ForwardDestinations `json:"forward_destinations,omitempty"` // Destinations SMS are forwarded to
+}
+
+type ListPhoneNumbersResponse struct {
+	PhoneNumbers []models.PhoneNumber `json:"phone_numbers"`
+}
+
+type ForwardDestinationsRequest struct {
+	ForwardDestinations models.ForwardDestinations `json:"forward_destinations"`
+}
+
+type ForwardDestinationsResponse struct {
+	ForwardDestinations models.ForwardDestinations `json:"forward_destinations"`
+}
+
+func (h *Handler) handleListPhoneNumbers(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberIDs, err := h.getAccessiblePhoneNumberIDs(ctx, authCtx)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	phoneNumbers := []models.PhoneNumber{}
+	for id := range phoneNumberIDs {
+		phoneNumber, err := h.Store.GetPhoneNumberByID(ctx, id)
+		if err != nil {
+			logger.Printf("failed to get phone number by ID: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		if phoneNumber != nil {
+			phoneNumbers = append(phoneNumbers, *phoneNumber)
+		}
+	}
+	slices.SortFunc(phoneNumbers, func(a, b models.PhoneNumber) int {
+		return cmp.Or(strings.Compare(a.CreatedAt, b.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	return response.JSON(ctx, 200, ListPhoneNumbersResponse{PhoneNumbers: phoneNumbers}), nil
+}
+
+// handlePostPhoneNumber creates a phone number owned by the calling user. It must then be attached
+// to a device for its SMS to be relayed.
+func (h *Handler) handlePostPhoneNumber(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage phone numbers"), nil
+	}
+
+	// Validate and parse the request body
+	var phoneNumberReq PhoneNumberRequest
+	if err := json.Unmarshal([]byte(request.Body), &phoneNumberReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if phoneNumberReq.PhoneNumber == nil {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "phone_number is required"), nil
+	}
+	timestamp := models.FormatTimestamp(time.Now())
+	phoneNumber := models.PhoneNumber{
+		ID:                  uuid.NewString(),
+		ForwardDestinations: models.ForwardDestinations{},
+		CreatedAt:           timestamp,
+		UpdatedAt:           timestamp,
+	}
+	if err := phoneNumberReq.apply(&phoneNumber); err != nil {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, err.Error()), nil
+	}
+
+	acl := models.ACL{
+		ID:            uuid.NewString(),
+		UserID:        authCtx.UserID,
+		PhoneNumberID: phoneNumber.ID,
+		CreatedAt:     timestamp,
+		UpdatedAt:     timestamp,
+	}
+	err = h.Store.CreatePhoneNumber(ctx, &phoneNumber, &acl)
+	if errors.Is(err, store.ErrPhoneNumberTaken) {
+		return response.Error(ctx, 409, response.CodePhoneNumberTaken, "Phone number already exists"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to save phone number: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s created phone number %s", authCtx.UserID, phoneNumber.ID)
+
+	return response.JSON(ctx, 201, phoneNumber), nil
+}
+
+func (h *Handler) handleGetPhoneNumber(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	phoneNumber, errResp := h.getAccessiblePhoneNumber(ctx, authCtx, phoneNumberID)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 200, phoneNumber), nil
+}
+
+// handlePatchPhoneNumber updates the fields set in the request body of a phone number.
+func (h *Handler) handlePatchPhoneNumber(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	var phoneNumberReq PhoneNumberRequest
+	if err := json.Unmarshal([]byte(request.Body), &phoneNumberReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+
+	phoneNumber, errResp := h.updatePhoneNumber(ctx, authCtx, phoneNumberID, phoneNumberReq.apply)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 200, phoneNumber), nil
+}
+
+// handleDeletePhoneNumber deletes a phone number. It must be detached from its device first, so
+// that the device isn't left relaying a missing phone number.
+func (h *Handler) handleDeletePhoneNumber(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	if !authCtx.IsUser() {
+		return response.Error(ctx, 403, response.CodeForbidden, "Only users can manage phone numbers"), nil
+	}
+	if _, errResp := h.getAccessiblePhoneNumber(ctx, authCtx, phoneNumberID); errResp != nil {
+		return *errResp, nil
+	}
+
+	err = h.Store.DeletePhoneNumber(ctx, phoneNumberID)
+	if errors.Is(err, store.ErrConflict) {
+		return response.Error(ctx, 409, response.CodePhoneNumberAttached,
+			"Detach the phone number from its device before deleting it"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to delete phone number: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	logger.Printf("user %s deleted phone number %s", authCtx.UserID, phoneNumberID)
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 204,
+	}, nil
+}
+
+func (h *Handler) handleGetForwardDestinations(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	phoneNumber, errResp := h.getAccessiblePhoneNumber(ctx, authCtx, phoneNumberID)
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 200, ForwardDestinationsResponse{ForwardDestinations: phoneNumber.ForwardDestinations}), nil
+}
+
+// handlePutForwardDestinations replaces all forward destinations of a phone number.
+func (h *Handler) handlePutForwardDestinations(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	var destinationsReq ForwardDestinationsRequest
+	if err := json.Unmarshal([]byte(request.Body), &destinationsReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+
+	phoneNumber, errResp := h.updatePhoneNumber(ctx, authCtx, phoneNumberID, func(phoneNumber *models.PhoneNumber) error {
+		return setForwardDestinations(phoneNumber, destinationsReq.ForwardDestinations)
+	})
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 200, ForwardDestinationsResponse{ForwardDestinations: phoneNumber.ForwardDestinations}), nil
+}
+
+// handlePostForwardDestination appends a forward destination to those of a phone number.
+func (h *Handler) handlePostForwardDestination(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	var destination models.ForwardDestination
+	if err := json.Unmarshal([]byte(request.Body), &destination); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+
+	phoneNumber, errResp := h.updatePhoneNumber(ctx, authCtx, phoneNumberID, func(phoneNumber *models.PhoneNumber) error {
+		destinations := append(slices.Clone(phoneNumber.ForwardDestinations), destination)
+		return setForwardDestinations(phoneNumber, destinations)
+	})
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 201, ForwardDestinationsResponse{ForwardDestinations: phoneNumber.ForwardDestinations}), nil
+}
+
+// handleDeleteForwardDestination removes the forward destination at the given index of those of a
+// phone number, shifting the following ones.
+func (h *Handler) handleDeleteForwardDestination(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	phoneNumberID := request.PathParameters["id"]
+	index, err := strconv.Atoi(request.PathParameters["index"])
+	if err != nil {
+		return response.Error(ctx, 404, response.CodeNotFound, "Forward destination not found"), nil
+	}
+
+	phoneNumber, errResp := h.updatePhoneNumber(ctx, authCtx, phoneNumberID, func(phoneNumber *models.PhoneNumber) error {
+		if index < 0 || index >= len(phoneNumber.ForwardDestinations) {
+			return errForwardDestinationNotFound
+		}
+		phoneNumber.ForwardDestinations = slices.Delete(slices.Clone(phoneNumber.ForwardDestinations), index, index+1)
+		return nil
+	})
+	if errResp != nil {
+		return *errResp, nil
+	}
+	return response.JSON(ctx, 200, ForwardDestinationsResponse{ForwardDestinations: phoneNumber.ForwardDestinations}), nil
+}
+
+// updatePhoneNumber applies update to a phone number the calling user may manage and saves it,
+// provided that it wasn't modified concurrently. It returns the updated phone number, or the error
+// response to return, which is a 400 with the error of update as body if it fails.
+func (h *Handler) updatePhoneNumber(
+	ctx context.Context, authCtx auth.AuthContext, phoneNumberID string,
+	update func(*models.PhoneNumber) error,
+) (*models.PhoneNumber, *events.APIGatewayProxyResponse) {
+	if !authCtx.IsUser() {
+		errResp := response.Error(ctx, 403, response.CodeForbidden, "Only users can manage phone numbers")
+		return nil, &errResp
+	}
+	phoneNumber, errResp := h.getAccessiblePhoneNumber(ctx, authCtx, phoneNumberID)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	expectedUpdatedAt := phoneNumber.UpdatedAt
+	if err := update(phoneNumber); errors.Is(err, errForwardDestinationNotFound) {
+		errResp := response.Error(ctx, 404, response.CodeNotFound, "Forward destination not found")
+		return nil, &errResp
+	} else if err != nil {
+		errResp := response.Error(ctx, 400, response.CodeInvalidRequest, err.Error())
+		return nil, &errResp
+	}
+	err := h.Store.UpdatePhoneNumber(ctx, phoneNumber, expectedUpdatedAt)
+	if errors.Is(err, store.ErrPhoneNumberTaken) {
+		errResp := response.Error(ctx, 409, response.CodePhoneNumberTaken, "Phone number already exists")
+		return nil, &errResp
+	}
+	if errors.Is(err, store.ErrConflict) {
+		errResp := response.Error(ctx, 409, response.CodeConcurrentModification,
+			"Phone number was modified concurrently, please retry")
+		return nil, &errResp
+	}
+	if err != nil {
+		logger.Printf("failed to update phone number: %v", err)
+		errResp := response.InternalServerError(ctx)
+		return nil, &errResp
+	}
+	logger.Printf("user %s updated phone number %s", authCtx.UserID, phoneNumberID)
+
+	return phoneNumber, nil
+}
+
+// getAccessiblePhoneNumber returns the phone number with the given ID if the given caller has
+// access to it, or the error response to return otherwise. Inaccessible phone numbers are reported
+// as not found.
+func (h *Handler) getAccessiblePhoneNumber(ctx context.Context, authCtx auth.AuthContext, phoneNumberID string) (
+	*models.PhoneNumber, *events.APIGatewayProxyResponse,
+) {
+	allowed, err := h.canAccessPhoneNumber(ctx, authCtx, phoneNumberID)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		errResp := response.InternalServerError(ctx)
+		return nil, &errResp
+	}
+	var phoneNumber *models.PhoneNumber
+	if allowed {
+		phoneNumber, err = h.Store.GetPhoneNumberByID(ctx, phoneNumberID)
+		if err != nil {
+			logger.Printf("failed to get phone number by ID: %v", err)
+			errResp := response.InternalServerError(ctx)
+			return nil, &errResp
+		}
+	}
+	if phoneNumber == nil {
+		errResp := response.Error(ctx, 404, response.CodeNotFound, "Phone number not found")
+		return nil, &errResp
+	}
+	return phoneNumber, nil
+}
+
+// apply validates the fields set in the request and sets them on the phone number.
+func (r *PhoneNumberRequest) apply(phoneNumber *models.PhoneNumber) error {
+	if r.PhoneNumber != nil {
+		if !models.IsE164(*r.PhoneNumber) {
+			return errors.New("phone_number must be in E.164 format, e.g. +14155550123")
+		}
+		phoneNumber.PhoneNumber = *r.PhoneNumber
+	}
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if utf8.RuneCountInString(name) > maxPhoneNumberNameLength {
+			return errors.New("name must be at most 64 characters")
+		}
+		phoneNumber.Name = name
+	}
+	if r.ForwardDestinations != nil {
+		return setForwardDestinations(phoneNumber, *r.ForwardDestinations)
+	}
+	return nil
+}
+
+// setForwardDestinations validates the destinations and sets them on the phone number.
+func setForwardDestinations(phoneNumber *models.PhoneNumber, destinations models.ForwardDestinations) error {
+	if len(destinations) > maxForwardDestinations {
+		return fmt.Errorf("at most %d forward destinations are allowed", maxForwardDestinations)
+	}
+	for i, dest := range destinations {
+		if err := forwarder.ValidateDestination(dest); err != nil {
+			return fmt.Errorf("invalid forward destination %d: %w", i, err)
+		}
+	}
+	if destinations == nil {
+		destinations = models.ForwardDestinations{}
+	}
+	phoneNumber.ForwardDestinations = destinations
+	return nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+func TestPhoneNumberIsUnique(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{Store: store.NewMemoryStore()}
+	authCtx := auth.AuthContext{UserID: "user-1", UserType: models.UserTypeUser}
+	create := func(number string) events.APIGatewayProxyResponse {
+		t.Helper()
+		resp, err := h.handlePostPhoneNumber(ctx, authCtx, events.APIGatewayProxyRequest{
+			Body: `{"phone_number":"` + number + `"}`,
+		})
+		if err != nil {
+			t.Fatalf("handlePostPhoneNumber: %v", err)
+		}
+		return resp
+	}
+	update := func(phoneNumberID string, number string) events.APIGatewayProxyResponse {
+		t.Helper()
+		resp, err := h.handlePatchPhoneNumber(ctx, authCtx, events.APIGatewayProxyRequest{
+			PathParameters: map[string]string{"id": phoneNumberID},
+			Body:           `{"phone_number":"` + number + `"}`,
+		})
+		if err != nil {
+			t.Fatalf("handlePatchPhoneNumber: %v", err)
+		}
+		return resp
+	}
+
+	resp := create("+15550100")
+	if resp.StatusCode != 201 {
+		t.Fatalf("create: got %d %s, want 201", resp.StatusCode, resp.Body)
+	}
+	checkProblem(t, create("+15550100"), 409, response.CodePhoneNumberTaken)
+
+	resp = create("+15550101")
+	if resp.StatusCode != 201 {
+		t.Fatalf("create another number: got %d %s, want 201", resp.StatusCode, resp.Body)
+	}
+	var other models.PhoneNumber
+	if err := json.Unmarshal([]byte(resp.Body), &other); err != nil {
+		t.Fatalf("failed to unmarshal phone number: %v", err)
+	}
+	checkProblem(t, update(other.ID, "+15550100"), 409, response.CodePhoneNumberTaken)
+
+	// A phone number keeps its own number, and frees it when changing it
+	if resp := update(other.ID, "+15550101"); resp.StatusCode != 200 {
+		t.Errorf("update to its own number: got %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+	if resp := update(other.ID, "+15550102"); resp.StatusCode != 200 {
+		t.Errorf("update to a new number: got %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+	if resp := create("+15550101"); resp.StatusCode != 201 {
+		t.Errorf("create with a freed number: got %d %s, want 201", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	defaultSMSPageSize = 50
+	maxSMSPageSize     = 100
+)
+
+type SMSRequest struct {
+	PhoneNumber string `json:"phone_number"`          // Phone number receiving the SMS, in E.164 format
+	From        string `json:"from"`                  // Phone number of the sender, in E.164 format
+	Body        string `json:"body"`                  // Content of the SMS message
+	ReceivedAt  string `json:"received_at,omitempty"` // Optional RFC 3339 timestamp of when the device received the SMS
+
+	// ClientMessageID is an optional device-generated ID of the SMS. Requests with the same ID (or
+	// Idempotency-Key header) from the same device are only relayed once.
+	ClientMessageID string `json:"client_message_id,omitempty"`
+}
+
+type ListSMSResponse struct {
+	SMS        []models.SMS `json:"sms"`                   // SMS messages, newest first
+	NextCursor string       `json:"next_cursor,omitempty"` // Cursor of the next page, empty if there are no more pages
+}
+
+func (h *Handler) handlePostSMS(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	if !authCtx.IsDevice() {
+		logger.Printf("user %s of type %s is not a device", authCtx.UserID, authCtx.UserType)
+		return response.Error(ctx, 403, response.CodeForbidden, "Only devices can send SMS"), nil
+	}
+
+	// Validate and parse the request body
+	var smsReq SMSRequest
+	if err := json.Unmarshal([]byte(request.Body), &smsReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if smsReq.PhoneNumber == "" || smsReq.From == "" || smsReq.Body == "" {
+		logger.Println("phone number or body is empty")
+		return response.Error(ctx, 400, response.CodeInvalidRequest,
+			"Phone number, from and body are required"), nil
+	}
+	now := time.Now()
+	receivedAt := now
+	if smsReq.ReceivedAt != "" {
+		receivedAt, err = time.Parse(time.RFC3339, smsReq.ReceivedAt)
+		if err != nil {
+			logger.Printf("invalid received_at: %v", err)
+			return response.Error(ctx, 400, response.CodeInvalidRequest,
+				"received_at must be an RFC 3339 timestamp"), nil
+		}
+	}
+
+	smsID := uuid.NewString()
+
+	// Deduplicate retried requests carrying an idempotency key
+	if key := getIdempotencyKey(request, smsReq); key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			return response.Error(ctx, 400, response.CodeInvalidRequest, "Idempotency key is too long"), nil
+		}
+		recordID, claimedSMSID, replay, err := h.beginIdempotentRequest(ctx, authCtx.DeviceID, key, smsID)
+		if err != nil {
+			logger.Printf("failed to claim idempotency key: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		if replay != nil {
+			return *replay, nil
+		}
+		smsID = claimedSMSID
+		defer func() {
+			h.finishIdempotentRequest(ctx, recordID, resp)
+		}()
+	}
+
+	// Get Device by ID
+	device, err := h.Store.GetDeviceByID(ctx, authCtx.DeviceID)
+	if err != nil {
+		logger.Printf("failed to get device by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if device == nil {
+		logger.Println("device not found")
+		return response.Error(ctx, 404, response.CodeNotFound, "Device not found"), nil
+	}
+
+	// Get Phone Number by number
+	phoneNumber, err := h.Store.GetPhoneNumberByPhoneNumber(ctx, smsReq.PhoneNumber)
+	if err != nil {
+		logger.Printf("failed to get phone number by number: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if phoneNumber == nil {
+		logger.Println("phone number not found")
+		return response.Error(ctx, 404, response.CodeNotFound, "Phone number not found"), nil
+	}
+
+	// Validate that the phone number is associated with the device
+	phoneNumberIDSet := make(map[string]struct{})
+	for _, num := range device.PhoneNumberIDs {
+		phoneNumberIDSet[num] = struct{}{}
+	}
+	if _, ok := phoneNumberIDSet[phoneNumber.ID]; !ok {
+		logger.Println("phone number is not associated with the device")
+		return response.Error(ctx, 403, response.CodeForbidden,
+			"Phone number is not associated with the device"), nil
+	}
+
+	// Persist the SMS so that it can still be retrieved if forwarding fails
+	sms := models.SMS{
+		ID:            smsID,
+		From:          smsReq.From,
+		Body:          smsReq.Body,
+		PhoneNumberID: phoneNumber.ID,
+		ReceivedAt:    models.FormatTimestamp(receivedAt),
+		CreatedAt:     models.FormatTimestamp(now),
+	}
+	if err := h.Store.PutSMS(ctx, &sms); errors.Is(err, store.ErrConflict) {
+		// Stored by a failed attempt of the same idempotent request, which is now retried
+		logger.Printf("SMS %s was already saved", sms.ID)
+	} else if err != nil {
+		logger.Printf("failed to save SMS: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+
+	// Construct the SQS message
+	smsRelayRequest := models.SMSRelayRequest{
+		Device:      *device,
+		DeviceName:  authCtx.UserName,
+		PhoneNumber: *phoneNumber,
+		SMS:         sms,
+	}
+
+	// Send the SMSRelayRequest to the forwarder queue
+	messageBody, err := json.Marshal(smsRelayRequest)
+	if err != nil {
+		logger.Printf("failed to marshal SMSRelayRequest: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if err := h.Queue.Publish(ctx, string(messageBody)); err != nil {
+		logger.Printf("failed to send message to queue: %v", err)
+		return response.Error(ctx, 500, response.CodeInternalError, "Failed to send message"), nil
+	}
+	logger.Printf("SMSRelayRequest successfully sent to queue (SMS ID: %s)", sms.ID)
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		Body:       "Message sent successfully",
+	}, nil
+}
+
+// handleGetSMS lists the SMS messages of the phone number given by the phone_number_id query
+// parameter, newest first. Pages are limited by the limit query parameter and continued by passing
+// the next_cursor of the previous response as the cursor query parameter.
+//
+// The phone number is required rather than defaulting to all the numbers of the caller: messages
+// are stored and paged per phone number (the PhoneNumberIDIndex of SMSTable), so a page across
+// numbers would need one cursor per number. Callers list their numbers with GET /phone-numbers,
+// and only see the messages of the numbers their ACL grants them.
+func (h *Handler) handleGetSMS(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	// Validate query parameters
+	phoneNumberID := request.QueryStringParameters["phone_number_id"]
+	if phoneNumberID == "" {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "phone_number_id is required"), nil
+	}
+	limit := defaultSMSPageSize
+	if limitParam := request.QueryStringParameters["limit"]; limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 || parsed > maxSMSPageSize {
+			return response.Error(ctx, 400, response.CodeInvalidRequest,
+				"limit must be between 1 and "+strconv.Itoa(maxSMSPageSize)), nil
+		}
+		limit = parsed
+	}
+
+	// Enforce the ACL of the phone number
+	allowed, err := h.canAccessPhoneNumber(ctx, authCtx, phoneNumberID)
+	if err != nil {
+		logger.Printf("failed to resolve ACL: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if !allowed {
+		logger.Printf("user %s is not allowed to access phone number %s", authCtx.UserID, phoneNumberID)
+		return response.Error(ctx, 403, response.CodeForbidden, "Access to the phone number is denied"), nil
+	}
+
+	// Fetch the page
+	smsList, nextCursor, err := h.Store.ListSMSByPhoneNumberID(
+		ctx, phoneNumberID, limit, request.QueryStringParameters["cursor"])
+	if errors.Is(err, store.ErrInvalidCursor) {
+		return response.Error(ctx, 400, response.CodeInvalidCursor, "Invalid cursor"), nil
+	}
+	if err != nil {
+		logger.Printf("failed to get SMS by phone number ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+
+	// Return the page in the response
+	return response.JSON(ctx, 200, ListSMSResponse{
+		SMS:        smsList,
+		NextCursor: nextCursor,
+	}), nil
+}
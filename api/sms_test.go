@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+// flakyPublisher fails its first publishes, as many as failures, then records the published bodies.
+type flakyPublisher struct {
+	failures  int
+	published []string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, body string) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("queue unavailable")
+	}
+	p.published = append(p.published, body)
+	return nil
+}
+
+// newSMSTestHandler returns a handler whose store has a device with one phone number, and the auth
+// context of that device.
+func newSMSTestHandler(t *testing.T, queue *flakyPublisher) (*Handler, auth.AuthContext) {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	err := s.CreateDevice(ctx,
+		&models.Device{ID: "device-1", PhoneNumberIDs: []string{}},
+		&models.User{ID: "device-user-1", Username: "device-1", UserType: models.UserTypeDevice, DeviceID: "device-1"},
+		&models.ACL{ID: "acl-1", UserID: "user-1", DeviceID: "device-1"})
+	if err != nil {
+		t.Fatalf("CreateDevice: %v", err)
+	}
+	err = s.CreatePhoneNumber(ctx,
+		&models.PhoneNumber{ID: "phone-number-1", PhoneNumber: "+15550100", ForwardDestinations: models.ForwardDestinations{}},
+		&models.ACL{ID: "acl-2", UserID: "user-1", PhoneNumberID: "phone-number-1"})
+	if err != nil {
+		t.Fatalf("CreatePhoneNumber: %v", err)
+	}
+	if err := s.AttachPhoneNumber(ctx, "device-1", "phone-number-1"); err != nil {
+		t.Fatalf("AttachPhoneNumber: %v", err)
+	}
+
+	authCtx := auth.AuthContext{UserID: "device-user-1", UserType: models.UserTypeDevice, DeviceID: "device-1"}
+	return &Handler{Store: s, Queue: queue}, authCtx
+}
+
+func TestPostSMSRetryReusesSMSID(t *testing.T) {
+	ctx := context.Background()
+	queue := &flakyPublisher{failures: 1}
+	h, authCtx := newSMSTestHandler(t, queue)
+	request := events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Idempotency-Key": "key-1"},
+		Body:    `{"phone_number":"+15550100","from":"+15550199","body":"hello"}`,
+	}
+
+	// The SMS is saved before publishing fails
+	resp, err := h.handlePostSMS(ctx, authCtx, request)
+	if err != nil || resp.StatusCode != 500 {
+		t.Fatalf("first attempt: got %d, %v, want 500", resp.StatusCode, err)
+	}
+	retried, err := h.handlePostSMS(ctx, authCtx, request)
+	if err != nil || retried.StatusCode != 200 {
+		t.Fatalf("retry: got %d %s, %v, want 200", retried.StatusCode, retried.Body, err)
+	}
+	replayed, err := h.handlePostSMS(ctx, authCtx, request)
+	if err != nil || replayed.StatusCode != 200 {
+		t.Fatalf("replay: got %d, %v, want 200", replayed.StatusCode, err)
+	}
+
+	smsList, _, err := h.Store.ListSMSByPhoneNumberID(ctx, "phone-number-1", 10, "")
+	if err != nil {
+		t.Fatalf("ListSMSByPhoneNumberID: %v", err)
+	}
+	if len(smsList) != 1 {
+		t.Errorf("got %d saved SMS, want 1", len(smsList))
+	}
+	if len(queue.published) != 1 {
+		t.Errorf("got %d published messages, want 1", len(queue.published))
+	}
+	if got, want := replayed.Headers["Content-Type"], retried.Headers["Content-Type"]; got != want || got == "" {
+		t.Errorf("replayed Content-Type %q, want %q", got, want)
+	}
+	if replayed.Body != retried.Body {
+		t.Errorf("replayed body %q, want %q", replayed.Body, retried.Body)
+	}
+}
+
+func TestPostSMSInProgress(t *testing.T) {
+	ctx := context.Background()
+	h, authCtx := newSMSTestHandler(t, &flakyPublisher{})
+	_, _, _, err := h.beginIdempotentRequest(ctx, authCtx.DeviceID, "key-1", "sms-1")
+	if err != nil {
+		t.Fatalf("beginIdempotentRequest: %v", err)
+	}
+
+	resp, err := h.handlePostSMS(ctx, authCtx, events.APIGatewayProxyRequest{
+		Body: `{"phone_number":"+15550100","from":"+15550199","body":"hello","client_message_id":"key-1"}`,
+	})
+	if err != nil || resp.StatusCode != 409 {
+		t.Errorf("got %d, %v, want 409", resp.StatusCode, err)
+	}
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+const refreshTokenLength = 32 // Random bytes of a refresh token
+
+// TokenResponse holds a new pair of tokens: a short-lived access token to authenticate requests
+// with, and a refresh token to exchange once for a new pair at /token/refresh before it expires.
+type TokenResponse struct {
+	Token                   string `json:"token"`                      // JWT access token
+	TokenExpireAfter        string `json:"token_expire_after"`         // RFC 3339 timestamp of when the access token expires
+	RefreshToken            string `json:"refresh_token"`              // Opaque refresh token, only returned once
+	RefreshTokenExpireAfter string `json:"refresh_token_expire_after"` // RFC 3339 timestamp of when the refresh token expires
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"` // Refresh token of the session to end, if any
+	AllSessions  bool   `json:"all_sessions,omitempty"`  // Whether to end all sessions of the caller
+}
+
+// handlePostTokenRefresh exchanges a refresh token for a new pair of tokens. Each refresh token can
+// only be exchanged once: presenting one again means that it leaked, so all the tokens rotated from
+// the same login are revoked, logging out both the legitimate client and the attacker.
+func (h *Handler) handlePostTokenRefresh(ctx context.Context, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	var refreshReq RefreshTokenRequest
+	if err := json.Unmarshal([]byte(request.Body), &refreshReq); err != nil {
+		logger.Printf("failed to unmarshal request body: %v", err)
+		return response.InvalidBody(ctx), nil
+	}
+	if refreshReq.RefreshToken == "" {
+		return response.Error(ctx, 400, response.CodeInvalidRequest, "refresh_token is required"), nil
+	}
+
+	token, err := h.Store.GetRefreshToken(ctx, hashRefreshToken(refreshReq.RefreshToken))
+	if err != nil {
+		logger.Printf("failed to get refresh token: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if token == nil {
+		return response.Error(ctx, 401, response.CodeInvalidRefreshToken, "Invalid or expired refresh token"), nil
+	}
+
+	used := token.UsedAt != ""
+	if !used {
+		// Concurrent requests with the same token may all have read it unused, only one can use it
+		ok, err := h.Store.UseRefreshToken(ctx, token.ID, models.FormatTimestamp(time.Now()))
+		if err != nil {
+			logger.Printf("failed to use refresh token: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		used = !ok
+	}
+	if used {
+		logger.Printf("refresh token of user %s reused, revoking family %s", token.UserID, token.FamilyID)
+		if err := h.revokeRefreshTokenFamily(ctx, token.UserID, token.FamilyID); err != nil {
+			logger.Printf("failed to revoke refresh token family: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		return response.Error(ctx, 401, response.CodeRefreshTokenReused,
+			"Refresh token was already used, log in again"), nil
+	}
+
+	user, err := h.Store.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		logger.Printf("failed to get user by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if user == nil {
+		// e.g. the device user of a deleted device
+		return response.Error(ctx, 401, response.CodeInvalidRefreshToken, "Invalid or expired refresh token"), nil
+	}
+
+	tokens, err := h.issueTokens(ctx, user, token.FamilyID)
+	if err != nil {
+		logger.Printf("error generating tokens: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	return response.JSON(ctx, 200, tokens), nil
+}
+
+// handlePostLogout revokes the access token of the request, along with the session of the given
+// refresh token, or all sessions of the caller. Revoked access tokens may still be accepted for up
+// to a minute, while the authorizer caches the revocation list and API Gateway caches its result.
+func (h *Handler) handlePostLogout(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	var logoutReq LogoutRequest
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &logoutReq); err != nil {
+			logger.Printf("failed to unmarshal request body: %v", err)
+			return response.InvalidBody(ctx), nil
+		}
+	}
+
+	if authCtx.TokenID != "" {
+		// The token can't outlive the validity of the access tokens issued since it was set
+		err := h.revokeAccessToken(ctx, authCtx.UserID, authCtx.TokenID, time.Now().Add(accessTokenValidityDuration).Unix())
+		if err != nil {
+			logger.Printf("failed to revoke access token: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+	}
+
+	switch {
+	case logoutReq.AllSessions:
+		tokens, err := h.Store.ListRefreshTokensByUserID(ctx, authCtx.UserID)
+		if err == nil {
+			err = h.revokeRefreshTokens(ctx, tokens)
+		}
+		if err != nil {
+			logger.Printf("failed to revoke refresh tokens: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		logger.Printf("user %s logged out of all sessions", authCtx.UserID)
+	case logoutReq.RefreshToken != "":
+		token, err := h.Store.GetRefreshToken(ctx, hashRefreshToken(logoutReq.RefreshToken))
+		if err == nil && token != nil && token.UserID == authCtx.UserID {
+			err = h.revokeRefreshTokenFamily(ctx, token.UserID, token.FamilyID)
+		}
+		if err != nil {
+			logger.Printf("failed to revoke refresh tokens: %v", err)
+			return response.InternalServerError(ctx), nil
+		}
+		logger.Printf("user %s logged out", authCtx.UserID)
+	default:
+		logger.Printf("user %s logged out", authCtx.UserID)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 204,
+	}, nil
+}
+
+// issueTokens generates a new pair of tokens of the user, the refresh token belonging to the given
+// family, i.e. the session of a login.
+func (h *Handler) issueTokens(ctx context.Context, user *models.User, familyID string) (TokenResponse, error) {
+	keySet, err := auth.GetKeySet(ctx, h.Secrets)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to retrieve JWT secret: %w", err)
+	}
+	keyID, signingMethod, signingKey := keySet.SigningKey()
+	now := time.Now()
+	accessTokenID := uuid.NewString()
+	accessTokenExpiresAt := now.Add(accessTokenValidityDuration)
+	signedToken, err := user.GenerateJWT(signingMethod, keyID, signingKey, accessTokenID, accessTokenExpiresAt)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	refreshToken := base64.RawURLEncoding.EncodeToString(randomBytes(refreshTokenLength))
+	refreshTokenExpiresAt := now.Add(refreshTokenValidityDuration)
+	err = h.Store.PutRefreshToken(ctx, &models.RefreshToken{
+		ID:                   hashRefreshToken(refreshToken),
+		UserID:               user.ID,
+		FamilyID:             familyID,
+		AccessTokenID:        accessTokenID,
+		AccessTokenExpiresAt: accessTokenExpiresAt.Unix(),
+		CreatedAt:            models.FormatTimestamp(now),
+		ExpiresAt:            refreshTokenExpiresAt.Unix(),
+	})
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return TokenResponse{
+		Token:                   signedToken,
+		TokenExpireAfter:        accessTokenExpiresAt.Format(time.RFC3339),
+		RefreshToken:            refreshToken,
+		RefreshTokenExpireAfter: refreshTokenExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// revokeRefreshTokenFamily revokes the refresh tokens of the user rotated from the same login, along
+// with the access tokens issued with them.
+func (h *Handler) revokeRefreshTokenFamily(ctx context.Context, userID string, familyID string) error {
+	tokens, err := h.Store.ListRefreshTokensByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var family []models.RefreshToken
+	for _, token := range tokens {
+		if token.FamilyID == familyID {
+			family = append(family, token)
+		}
+	}
+	return h.revokeRefreshTokens(ctx, family)
+}
+
+// revokeRefreshTokens deletes refresh tokens, after revoking the unexpired access tokens issued with
+// them.
+func (h *Handler) revokeRefreshTokens(ctx context.Context, tokens []models.RefreshToken) error {
+	now := time.Now().Unix()
+	for _, token := range tokens {
+		if token.AccessTokenExpiresAt > now {
+			if err := h.revokeAccessToken(ctx, token.UserID, token.AccessTokenID, token.AccessTokenExpiresAt); err != nil {
+				return err
+			}
+		}
+		if err := h.Store.DeleteRefreshToken(ctx, token.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeAccessToken adds an access token expiring at expiresAt to the revocation list.
+func (h *Handler) revokeAccessToken(ctx context.Context, userID string, tokenID string, expiresAt int64) error {
+	return h.Store.PutRevokedToken(ctx, &models.RevokedToken{
+		ID:        tokenID,
+		UserID:    userID,
+		RevokedAt: models.FormatTimestamp(time.Now()),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// hashRefreshToken returns the ID under which a refresh token is stored.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+// staticSecrets provides the secrets of a map.
+type staticSecrets map[string]string
+
+func (s staticSecrets) GetSecret(ctx context.Context, secretName string) (string, error) {
+	value, ok := s[secretName]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return value, nil
+}
+
+// newTokenTestHandler returns a handler whose store has a user, and that user.
+func newTokenTestHandler(t *testing.T) (*Handler, *models.User) {
+	t.Helper()
+	s := store.NewMemoryStore()
+	user := models.User{ID: "user-1", Username: "alice", UserType: models.UserTypeUser}
+	s.PutUser(user)
+	return &Handler{Store: s, Secrets: staticSecrets{"JWTSecret": `{"JWTKey":"test-key"}`}}, &user
+}
+
+// refresh exchanges a refresh token, returning the response and the new tokens if it succeeded.
+func refresh(t *testing.T, h *Handler, refreshToken string) (events.APIGatewayProxyResponse, TokenResponse) {
+	t.Helper()
+	body, err := json.Marshal(RefreshTokenRequest{RefreshToken: refreshToken})
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	resp, err := h.handlePostTokenRefresh(context.Background(), events.APIGatewayProxyRequest{Body: string(body)})
+	if err != nil {
+		t.Fatalf("handlePostTokenRefresh: %v", err)
+	}
+	var tokens TokenResponse
+	if resp.StatusCode == 200 {
+		if err := json.Unmarshal([]byte(resp.Body), &tokens); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+	}
+	return resp, tokens
+}
+
+// checkProblem checks that the response is an error with the given status and code.
+func checkProblem(t *testing.T, resp events.APIGatewayProxyResponse, status int, code string) {
+	t.Helper()
+	var problem response.Problem
+	if err := json.Unmarshal([]byte(resp.Body), &problem); err != nil {
+		t.Fatalf("failed to unmarshal problem %q: %v", resp.Body, err)
+	}
+	if resp.StatusCode != status || problem.Code != code {
+		t.Errorf("got %d %s, want %d %s", resp.StatusCode, problem.Code, status, code)
+	}
+}
+
+func TestRefreshTokenIsExchangedOnce(t *testing.T) {
+	ctx := context.Background()
+	h, user := newTokenTestHandler(t)
+	login, err := h.issueTokens(ctx, user, "family-1")
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+
+	resp, rotated := refresh(t, h, login.RefreshToken)
+	if resp.StatusCode != 200 {
+		t.Fatalf("first exchange: got %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+
+	// Without caching, so that the revocations are seen right away
+	validator := &auth.KeySetValidator{Secrets: h.Secrets}
+	revocations := auth.NewRevocationList(h.Store, 0)
+	if _, err := auth.Authenticate(ctx, validator, revocations, rotated.Token); err != nil {
+		t.Fatalf("rotated access token rejected before reuse: %v", err)
+	}
+
+	// Presenting the exchanged token again revokes the whole family
+	resp, _ = refresh(t, h, login.RefreshToken)
+	checkProblem(t, resp, 401, response.CodeRefreshTokenReused)
+	resp, _ = refresh(t, h, rotated.RefreshToken)
+	checkProblem(t, resp, 401, response.CodeInvalidRefreshToken)
+
+	for name, token := range map[string]string{"login": login.Token, "rotated": rotated.Token} {
+		if _, err := auth.Authenticate(ctx, validator, revocations, token); !errors.Is(err, auth.ErrTokenRevoked) {
+			t.Errorf("%s access token: got %v, want ErrTokenRevoked", name, err)
+		}
+	}
+}
+
+func TestRefreshTokenOfOtherFamilyIsKept(t *testing.T) {
+	ctx := context.Background()
+	h, user := newTokenTestHandler(t)
+	stolen, err := h.issueTokens(ctx, user, "family-1")
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+	other, err := h.issueTokens(ctx, user, "family-2")
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+
+	if resp, _ := refresh(t, h, stolen.RefreshToken); resp.StatusCode != 200 {
+		t.Fatalf("first exchange: got %d, want 200", resp.StatusCode)
+	}
+	resp, _ := refresh(t, h, stolen.RefreshToken)
+	checkProblem(t, resp, 401, response.CodeRefreshTokenReused)
+
+	if resp, _ := refresh(t, h, other.RefreshToken); resp.StatusCode != 200 {
+		t.Errorf("session of another login: got %d %s, want 200", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+// handleGetUser retrieves user information based on the user ID provided in the authorization context.
+// It returns the user details in the response body.
+func (h *Handler) handleGetUser(ctx context.Context, authCtx auth.AuthContext, request events.APIGatewayProxyRequest) (
+	resp events.APIGatewayProxyResponse, err error,
+) {
+	// Fetch user details from the database
+	user, err := h.Store.GetUserByID(ctx, authCtx.UserID)
+	if err != nil {
+		logger.Printf("failed to get user by ID: %v", err)
+		return response.InternalServerError(ctx), nil
+	}
+	if user == nil {
+		return response.Error(ctx, 404, response.CodeNotFound, "User not found"), nil
+	}
+
+	// Return user details in the response
+	return response.JSON(ctx, 200, user), nil
+}
@@ -0,0 +1,118 @@
+// Package auth issues and validates the JWTs used to authenticate API requests.
+//
+// Tokens are signed with the active key of the keyset stored in the JWTSecret secret, see KeySet,
+// and validated with the key named by their kid header. Since access tokens are short-lived and
+// clients get new ones with their refresh tokens, which don't depend on the keys, the signing key
+// can be rotated without logging anyone out:
+//
+//  1. Add the new key to the keys of the secret, with a new kid, and make it the active_kid. The
+//     components pick it up as their cache of the secret expires (common.DefaultSecretsCacheTTL).
+//     Those still caching the previous value refresh it when they see a token with an unknown kid.
+//  2. Keep the previous key until the tokens signed with it have expired, i.e. the validity of the
+//     access tokens plus the secrets cache TTL, then mark it retired or remove it.
+//
+// A secret in the legacy format, holding only JWTKey, is migrated the same way: keep JWTKey next
+// to the keys so that the tokens without a kid header stay valid, and remove it in step 2.
+//
+// If a key leaked, retire it right away instead: its tokens are rejected as soon as the cache of
+// the secret expires, and their clients have to refresh them.
+//
+// Keys may be HS256 secrets or EdDSA and ES256 private keys, generated with tools/jwt-keygen. The
+// public keys of the latter are served at /.well-known/jwks.json, which JWKSValidator validates
+// tokens against, so that sms-relay-api-authenticator and other services don't need the secret.
+// To move from HS256 to an asymmetric key, rotate to it as above, then once the HS256 tokens have
+// expired set the JWKS_URL of sms-relay-api-authenticator (the JWKSURL parameter of the
+// CloudFormation template).
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+)
+
+const (
+	jwtSecretName = "JWTSecret"
+
+	bearerPrefix = "Bearer "
+)
+
+var logger = log.Default()
+
+// TokenValidator validates access tokens and returns their claims.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, tokenString string) (jwt.MapClaims, error)
+}
+
+// KeySetValidator validates tokens against the keyset of the JWT secret, see ValidateToken. It
+// requires access to the secret, unlike JWKSValidator.
+type KeySetValidator struct {
+	Secrets common.SecretsProvider
+}
+
+func (v *KeySetValidator) ValidateToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	return ValidateToken(ctx, v.Secrets, tokenString)
+}
+
+// ValidateToken parses and validates a JWT against the keyset from the secrets provider, and
+// returns its claims. An unknown kid or a signature mismatch may mean that the keys were rotated
+// since they were cached, so in that case the keyset is refreshed and the token validated once
+// more.
+func ValidateToken(ctx context.Context, secrets common.SecretsProvider, tokenString string) (jwt.MapClaims, error) {
+	keySet, err := GetKeySet(ctx, secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve JWT secret: %w", err)
+	}
+	claims, err := ParseToken(tokenString, keySet)
+	if (errors.Is(err, jwt.ErrTokenSignatureInvalid) || errors.Is(err, ErrUnknownKeyID)) &&
+		common.RefreshSecret(secrets, jwtSecretName) {
+		if keySet, err = GetKeySet(ctx, secrets); err != nil {
+			return nil, fmt.Errorf("failed to retrieve JWT secret: %w", err)
+		}
+		claims, err = ParseToken(tokenString, keySet)
+	}
+	return claims, err
+}
+
+// TokenFromHeader extracts the token from the value of a "Bearer" Authorization header.
+func TokenFromHeader(header string) (string, error) {
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return "", errors.New("invalid authorization header format")
+	}
+	return strings.TrimPrefix(header, bearerPrefix), nil
+}
+
+// ParseToken parses and validates a JWT signed with a key of the keyset, and returns its claims.
+func ParseToken(tokenString string, keySet *KeySet) (jwt.MapClaims, error) {
+	validMethods := []string{AlgorithmHS256, AlgorithmEdDSA, AlgorithmES256}
+	return parseToken(tokenString, validMethods, func(token *jwt.Token) (any, error) {
+		keyID, ok := token.Header["kid"].(string)
+		if !ok && token.Header["kid"] != nil {
+			return nil, fmt.Errorf("%w: kid is a %T", ErrUnknownKeyID, token.Header["kid"])
+		}
+		return keySet.VerificationKey(keyID, token.Method.Alg())
+	})
+}
+
+// parseToken parses and validates a JWT signed with one of the given methods and the key returned
+// by keyFunc, and returns its claims.
+func parseToken(tokenString string, validMethods []string, keyFunc jwt.Keyfunc) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, keyFunc, jwt.WithValidMethods(validMethods))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("failed to parse token claims")
+	}
+	return claims, nil
+}
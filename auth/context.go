@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// Keys of the authorizer context passed to the API handler, by the REST API authorizer as well as
+// by the standalone server.
+const (
+	authorizerUserIDKey   = "user_id"
+	authorizerUserTypeKey = "user_type"
+	authorizerUserNameKey = "user_name"
+	authorizerDeviceIDKey = "device_id"
+	authorizerTokenIDKey  = "token_id"
+)
+
+var ErrInvalidAuthContext = errors.New("invalid auth context")
+
+// AuthContext is the identity of the caller of an authenticated request, taken from the claims of
+// its validated token. It is only built through NewAuthContext and ParseAuthorizerContext, which
+// validate it, so a device account always has a device ID.
+type AuthContext struct {
+	UserID   string
+	UserType string // models.UserTypeUser or models.UserTypeDevice
+	UserName string
+	DeviceID string // ID of the device of device accounts, empty for users
+	TokenID  string // ID (jti claim) of the access token, empty for tokens issued before it was set
+}
+
+// NewAuthContext returns the auth context of the claims of a validated token. It returns an error
+// wrapping ErrInvalidAuthContext if claims are missing or inconsistent, e.g. a device token
+// without a device ID.
+func NewAuthContext(claims jwt.MapClaims) (AuthContext, error) {
+	return ParseAuthorizerContext(map[string]any{
+		authorizerUserIDKey:   claims["sub"],
+		authorizerUserTypeKey: claims["user_type"],
+		authorizerUserNameKey: claims["user_name"],
+		authorizerDeviceIDKey: claims["device_id"],
+		authorizerTokenIDKey:  claims["jti"],
+	})
+}
+
+// ParseAuthorizerContext returns the auth context of the authorizer context of an API Gateway
+// request, as populated with AuthorizerContext. It returns an error wrapping ErrInvalidAuthContext
+// if it is missing or invalid, e.g. for the public routes.
+func ParseAuthorizerContext(authorizer map[string]any) (AuthContext, error) {
+	var authCtx AuthContext
+	for key, field := range map[string]*string{
+		authorizerUserIDKey:   &authCtx.UserID,
+		authorizerUserTypeKey: &authCtx.UserType,
+		authorizerUserNameKey: &authCtx.UserName,
+		authorizerDeviceIDKey: &authCtx.DeviceID,
+		authorizerTokenIDKey:  &authCtx.TokenID,
+	} {
+		switch value := authorizer[key].(type) {
+		case nil:
+		case string:
+			*field = value
+		default:
+			return AuthContext{}, fmt.Errorf("%w: %s is a %T, not a string", ErrInvalidAuthContext, key, value)
+		}
+	}
+	if err := authCtx.validate(); err != nil {
+		return AuthContext{}, err
+	}
+	return authCtx, nil
+}
+
+func (a AuthContext) validate() error {
+	if a.UserID == "" {
+		return fmt.Errorf("%w: missing user ID", ErrInvalidAuthContext)
+	}
+	switch a.UserType {
+	case models.UserTypeUser:
+		if a.DeviceID != "" {
+			return fmt.Errorf("%w: user %s has a device ID", ErrInvalidAuthContext, a.UserID)
+		}
+	case models.UserTypeDevice:
+		if a.DeviceID == "" {
+			return fmt.Errorf("%w: device user %s has no device ID", ErrInvalidAuthContext, a.UserID)
+		}
+	default:
+		return fmt.Errorf("%w: invalid user type %q", ErrInvalidAuthContext, a.UserType)
+	}
+	return nil
+}
+
+// IsUser reports whether the caller is a user account, which may manage devices and phone numbers.
+func (a AuthContext) IsUser() bool {
+	return a.UserType == models.UserTypeUser
+}
+
+// IsDevice reports whether the caller is the account of the device a.DeviceID.
+func (a AuthContext) IsDevice() bool {
+	return a.UserType == models.UserTypeDevice
+}
+
+// AuthorizerContext returns the authorizer context passed to the API handler, which API Gateway
+// requires to only hold strings, numbers and booleans.
+func (a AuthContext) AuthorizerContext() map[string]any {
+	return map[string]any{
+		authorizerUserIDKey:   a.UserID,
+		authorizerUserTypeKey: a.UserType,
+		authorizerUserNameKey: a.UserName,
+		authorizerDeviceIDKey: a.DeviceID,
+		authorizerTokenIDKey:  a.TokenID,
+	}
+}
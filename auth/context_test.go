@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// authContextTests are the claims of tokens, keyed as in the authorizer context, and the auth
+// context they make, if valid.
+var authContextTests = []struct {
+	name    string
+	claims  map[string]any // Keyed as in the authorizer context
+	want    AuthContext
+	wantErr bool
+}{
+	{
+		name:   "user without device ID",
+		claims: map[string]any{"user_id": "user-1", "user_type": models.UserTypeUser, "user_name": "Alice", "token_id": "jti-1"},
+		want:   AuthContext{UserID: "user-1", UserType: models.UserTypeUser, UserName: "Alice", TokenID: "jti-1"},
+	},
+	{
+		name:   "user of a token without ID",
+		claims: map[string]any{"user_id": "user-1", "user_type": models.UserTypeUser},
+		want:   AuthContext{UserID: "user-1", UserType: models.UserTypeUser},
+	},
+	{
+		name:   "device with device ID",
+		claims: map[string]any{"user_id": "device-user-1", "user_type": models.UserTypeDevice, "device_id": "device-1"},
+		want:   AuthContext{UserID: "device-user-1", UserType: models.UserTypeDevice, DeviceID: "device-1"},
+	},
+	{
+		name:    "device without device ID",
+		claims:  map[string]any{"user_id": "device-user-1", "user_type": models.UserTypeDevice},
+		wantErr: true,
+	},
+	{
+		name:    "device with empty device ID",
+		claims:  map[string]any{"user_id": "device-user-1", "user_type": models.UserTypeDevice, "device_id": ""},
+		wantErr: true,
+	},
+	{
+		name:    "user with device ID",
+		claims:  map[string]any{"user_id": "user-1", "user_type": models.UserTypeUser, "device_id": "device-1"},
+		wantErr: true,
+	},
+	{
+		name:    "missing user ID",
+		claims:  map[string]any{"user_type": models.UserTypeUser},
+		wantErr: true,
+	},
+	{
+		name:    "non-string user ID",
+		claims:  map[string]any{"user_id": 42.0, "user_type": models.UserTypeUser},
+		wantErr: true,
+	},
+	{
+		name:    "non-string device ID",
+		claims:  map[string]any{"user_id": "device-user-1", "user_type": models.UserTypeDevice, "device_id": true},
+		wantErr: true,
+	},
+	{
+		name:    "unknown user type",
+		claims:  map[string]any{"user_id": "user-1", "user_type": "ADMIN"},
+		wantErr: true,
+	},
+	{
+		name:    "missing user type",
+		claims:  map[string]any{"user_id": "user-1"},
+		wantErr: true,
+	},
+}
+
+// jwtClaimNames maps the keys of the authorizer context to the names of the claims of a token.
+var jwtClaimNames = map[string]string{
+	"user_id":   "sub",
+	"user_type": "user_type",
+	"user_name": "user_name",
+	"device_id": "device_id",
+	"token_id":  "jti",
+}
+
+func checkAuthContext(t *testing.T, got AuthContext, err error, want AuthContext, wantErr bool) {
+	t.Helper()
+	if wantErr {
+		if !errors.Is(err, ErrInvalidAuthContext) {
+			t.Errorf("got %+v, %v, want ErrInvalidAuthContext", got, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNewAuthContext(t *testing.T) {
+	for _, tt := range authContextTests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{}
+			for key, value := range tt.claims {
+				claims[jwtClaimNames[key]] = value
+			}
+			got, err := NewAuthContext(claims)
+			checkAuthContext(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestParseAuthorizerContext(t *testing.T) {
+	for _, tt := range authContextTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuthorizerContext(tt.claims)
+			checkAuthContext(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+// TestAuthorizerContextRoundTrip checks that the authorizer context of an auth context, with the
+// empty fields API Gateway passes on as empty strings, parses back to the same auth context.
+func TestAuthorizerContextRoundTrip(t *testing.T) {
+	for _, tt := range authContextTests {
+		if tt.wantErr {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuthorizerContext(tt.want.AuthorizerContext())
+			checkAuthContext(t, got, err, tt.want, false)
+		})
+	}
+}
+
+func TestParseAuthorizerContextOfPublicRoute(t *testing.T) {
+	if _, err := ParseAuthorizerContext(nil); !errors.Is(err, ErrInvalidAuthContext) {
+		t.Errorf("got %v, want ErrInvalidAuthContext", err)
+	}
+}
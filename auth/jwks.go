@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	maxJWKSSize             = 1 << 16 // 64 KiB
+	minJWKSRefreshInterval  = time.Minute
+	defaultJWKSFetchTimeout = time.Second * 5
+)
+
+// JWKS is a JSON Web Key Set (RFC 7517) of public keys, as served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key of an asymmetric signing key (RFC 7517, RFC 8037 for Ed25519).
+type JWK struct {
+	KeyType   string `json:"kty"` // "OKP" for Ed25519, "EC" for P-256
+	Curve     string `json:"crv"` // "Ed25519" or "P-256"
+	X         string `json:"x"`
+	Y         string `json:"y,omitempty"` // Only for EC keys
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"` // EdDSA or ES256
+	Use       string `json:"use"` // Always "sig"
+}
+
+// NewJWK returns the JWK of an Ed25519 or P-256 public key.
+func NewJWK(keyID string, publicKey any) (JWK, error) {
+	switch publicKey := publicKey.(type) {
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			KeyID:     keyID,
+			Algorithm: AlgorithmEdDSA,
+			Use:       "sig",
+		}, nil
+	case *ecdsa.PublicKey:
+		if publicKey.Curve != elliptic.P256() {
+			return JWK{}, errors.New("unsupported elliptic curve")
+		}
+		return JWK{
+			KeyType:   "EC",
+			Curve:     "P-256",
+			X:         base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, 32))),
+			Y:         base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, 32))),
+			KeyID:     keyID,
+			Algorithm: AlgorithmES256,
+			Use:       "sig",
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key %T", publicKey)
+	}
+}
+
+// PublicKey returns the public key of the JWK, an ed25519.PublicKey or an *ecdsa.PublicKey.
+func (j JWK) PublicKey() (any, error) {
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x of key %q: %w", j.KeyID, err)
+	}
+	switch {
+	case j.KeyType == "OKP" && j.Curve == "Ed25519" && j.Algorithm == AlgorithmEdDSA:
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %q", j.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	case j.KeyType == "EC" && j.Curve == "P-256" && j.Algorithm == AlgorithmES256:
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y of key %q: %w", j.KeyID, err)
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, fmt.Errorf("invalid P-256 key %q", j.KeyID)
+		}
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported key %q: kty %s, crv %s, alg %s", j.KeyID, j.KeyType, j.Curve, j.Algorithm)
+	}
+}
+
+// JWKSValidator validates the tokens signed with asymmetric keys against the public keys of a
+// JWKS URL, without access to the JWT secret. Tokens signed with HS256 keys are rejected. The
+// JWKS is cached for a TTL, and fetched again early when a token has an unknown kid, but at most
+// once a minute. Kept in a package-level variable of a Lambda, the cache is shared by all
+// invocations of a warm instance.
+type JWKSValidator struct {
+	URL    string
+	TTL    time.Duration
+	Client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]JWK
+	fetchedAt   time.Time
+	attemptedAt time.Time // Time of the last fetch, successful or not
+}
+
+func NewJWKSValidator(url string, ttl time.Duration) *JWKSValidator {
+	return &JWKSValidator{
+		URL:    url,
+		TTL:    ttl,
+		Client: &http.Client{Timeout: defaultJWKSFetchTimeout},
+	}
+}
+
+func (v *JWKSValidator) ValidateToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	return parseToken(tokenString, []string{AlgorithmEdDSA, AlgorithmES256}, func(token *jwt.Token) (any, error) {
+		keyID, _ := token.Header["kid"].(string)
+		return v.publicKey(ctx, keyID, token.Method.Alg())
+	})
+}
+
+// publicKey returns the public key with the given ID, which must be of the given algorithm.
+func (v *JWKSValidator) publicKey(ctx context.Context, keyID string, algorithm string) (any, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	jwk, ok := v.keys[keyID]
+	if (!ok || time.Since(v.fetchedAt) >= v.TTL) && time.Since(v.attemptedAt) >= minJWKSRefreshInterval {
+		v.attemptedAt = time.Now()
+		if err := v.fetch(ctx); err != nil {
+			// Keep validating with the cached keys, if any, while the JWKS is unavailable
+			logger.Printf("failed to refresh JWKS: %v", err)
+		}
+		jwk, ok = v.keys[keyID]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, keyID)
+	}
+	if jwk.Algorithm != algorithm {
+		return nil, fmt.Errorf("unexpected signing method %s of key %q", algorithm, keyID)
+	}
+	return jwk.PublicKey()
+}
+
+// fetch replaces the cached keys with those of the JWKS URL. v.mu must be held.
+func (v *JWKSValidator) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&jwks); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	keys := make(map[string]JWK, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		keys[jwk.KeyID] = jwk
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
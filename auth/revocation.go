@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+var ErrTokenRevoked = errors.New("token revoked")
+
+// RevocationList tells whether access tokens were revoked, e.g. by logging out. Lookups are cached
+// for a TTL, so that the authorizer doesn't query the store on every request; a token may thus
+// still be accepted for up to the TTL after it is revoked. Kept in a package-level variable of a
+// Lambda, the cache is shared by all invocations of a warm instance.
+type RevocationList struct {
+	Store store.RevokedTokenRepository
+	TTL   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedRevocation
+}
+
+type cachedRevocation struct {
+	revoked   bool
+	fetchedAt time.Time
+}
+
+func NewRevocationList(repo store.RevokedTokenRepository, ttl time.Duration) *RevocationList {
+	return &RevocationList{
+		Store:   repo,
+		TTL:     ttl,
+		entries: make(map[string]cachedRevocation),
+	}
+}
+
+// IsRevoked reports whether the access token with the given ID was revoked.
+func (l *RevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	cached, ok := l.entries[tokenID]
+	l.mu.Unlock()
+	if ok && now.Sub(cached.fetchedAt) < l.TTL {
+		return cached.revoked, nil
+	}
+
+	entry, err := l.Store.GetRevokedToken(ctx, tokenID)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Prune the stale entries as they are added, so that the cache doesn't grow with every token
+	// seen by a long-lived instance
+	for id, cached := range l.entries {
+		if now.Sub(cached.fetchedAt) >= l.TTL {
+			delete(l.entries, id)
+		}
+	}
+	l.entries[tokenID] = cachedRevocation{revoked: entry != nil, fetchedAt: now}
+	return entry != nil, nil
+}
+
+// Authenticate validates an access token and returns the auth context of its caller. It returns an
+// error wrapping ErrTokenRevoked if the token is on the revocation list. Tokens without an ID,
+// issued before tokens could be revoked, are accepted until they expire.
+func Authenticate(
+	ctx context.Context, validator TokenValidator, revocations *RevocationList, tokenString string,
+) (AuthContext, error) {
+	claims, err := validator.ValidateToken(ctx, tokenString)
+	if err != nil {
+		return AuthContext{}, err
+	}
+	authCtx, err := NewAuthContext(claims)
+	if err != nil {
+		return AuthContext{}, err
+	}
+	if authCtx.TokenID == "" {
+		return authCtx, nil
+	}
+	revoked, err := revocations.IsRevoked(ctx, authCtx.TokenID)
+	if err != nil {
+		return AuthContext{}, fmt.Errorf("failed to check revocation of token %s: %w", authCtx.TokenID, err)
+	}
+	if revoked {
+		return AuthContext{}, fmt.Errorf("%w: %s", ErrTokenRevoked, authCtx.TokenID)
+	}
+	return authCtx, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/httpadapter"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+// publicPaths are the routes served without a token, like the methods with AuthorizationType NONE
+// in the CloudFormation template.
+var publicPaths = map[string]struct{}{
+	"/login":                 {},
+	"/devices/pair":          {},
+	"/token/refresh":         {},
+	"/.well-known/jwks.json": {},
+}
+
+// authorize wraps a handler with the token validation of sms-relay-api-authenticator. It fills in
+// the authorizer context of authenticated requests and rejects the others the way API Gateway
+// does with the gateway responses of the CloudFormation template: 401 without an Authorization
+// header and 403 with an invalid token.
+func authorize(
+	validator auth.TokenValidator, revocations *auth.RevocationList, next httpadapter.ProxyHandlerFunc,
+) httpadapter.ProxyHandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if _, ok := publicPaths[request.Path]; ok {
+			return next(ctx, request)
+		}
+
+		errCtx := response.WithRequestID(ctx, request.RequestContext.RequestID)
+		header := request.Headers[http.CanonicalHeaderKey("Authorization")]
+		if header == "" {
+			return response.Error(errCtx, 401, response.CodeUnauthorized, "Unauthorized"), nil
+		}
+
+		tokenString, err := auth.TokenFromHeader(header)
+		if err != nil {
+			logger.Println(err)
+			return forbidden(errCtx), nil
+		}
+		authCtx, err := auth.Authenticate(ctx, validator, revocations, tokenString)
+		if err != nil {
+			logger.Printf("invalid token: %v", err)
+			return forbidden(errCtx), nil
+		}
+
+		request.RequestContext.Authorizer = authCtx.AuthorizerContext()
+		return next(ctx, request)
+	}
+}
+
+func forbidden(ctx context.Context) events.APIGatewayProxyResponse {
+	return response.Error(ctx, 403, response.CodeInvalidToken, "User is not authorized to access this resource")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+// claimsValidator accepts any token, returning its claims.
+type claimsValidator jwt.MapClaims
+
+func (v claimsValidator) ValidateToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	return jwt.MapClaims(v), nil
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		wantStatus int
+		want       map[string]any // Authorizer context passed to the handler
+	}{
+		{
+			name:       "user without device_id",
+			claims:     jwt.MapClaims{"sub": "user-1", "user_type": models.UserTypeUser, "user_name": "Alice"},
+			wantStatus: 200,
+			want: map[string]any{
+				"user_id": "user-1", "user_type": models.UserTypeUser, "user_name": "Alice", "device_id": "", "token_id": "",
+			},
+		},
+		{
+			name:       "device with device_id",
+			claims:     jwt.MapClaims{"sub": "device-user-1", "user_type": models.UserTypeDevice, "device_id": "device-1"},
+			wantStatus: 200,
+			want: map[string]any{
+				"user_id": "device-user-1", "user_type": models.UserTypeDevice, "user_name": "", "device_id": "device-1", "token_id": "",
+			},
+		},
+		{
+			name:       "device without device_id",
+			claims:     jwt.MapClaims{"sub": "device-user-1", "user_type": models.UserTypeDevice},
+			wantStatus: 403,
+		},
+		{
+			name:       "missing sub",
+			claims:     jwt.MapClaims{"user_type": models.UserTypeUser},
+			wantStatus: 403,
+		},
+		{
+			name:       "non-string sub",
+			claims:     jwt.MapClaims{"sub": 42.0, "user_type": models.UserTypeUser},
+			wantStatus: 403,
+		},
+		{
+			name:       "unknown user_type",
+			claims:     jwt.MapClaims{"sub": "user-1", "user_type": "ADMIN"},
+			wantStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			handler := authorize(claimsValidator(tt.claims), auth.NewRevocationList(store.NewMemoryStore(), time.Minute),
+				func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+					got = request.RequestContext.Authorizer
+					return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+				})
+
+			resp, err := handler(context.Background(), events.APIGatewayProxyRequest{
+				Path:    "/user",
+				Headers: map[string]string{"Authorization": "Bearer token"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got authorizer context %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("got %s %v, want %v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
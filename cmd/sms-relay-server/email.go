@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/emailreply"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+const (
+	inboundEmailPath            = "/email/inbound"
+	inboundEmailTokenHeader     = "X-Inbound-Email-Token"
+	inboundEmailTokenSecretName = "InboundEmailToken"
+	maxInboundEmailSize         = 10 << 20 // 10 MiB, the SES receiving limit
+)
+
+// inboundEmailHandler receives raw reply emails, e.g. piped from an MTA, and turns them into
+// outbound SMS like sms-relay-email-receiver. Requests must carry the InboundEmailToken secret in
+// the X-Inbound-Email-Token header, and the endpoint is disabled if the secret isn't set. The
+// envelope recipients may be passed as recipient query parameters.
+func inboundEmailHandler(secrets common.SecretsProvider, receiver *emailreply.Receiver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			response.WriteError(w, r, http.StatusMethodNotAllowed, response.CodeMethodNotAllowed,
+				"Method Not Allowed")
+			return
+		}
+
+		// Authenticate the request
+		token, err := common.GetSecretValue(r.Context(), secrets, inboundEmailTokenSecretName, "token")
+		if err != nil || token == "" {
+			logger.Printf("inbound email is disabled, failed to fetch token: %v", err)
+			response.WriteError(w, r, http.StatusNotFound, response.CodeNotFound, "Not Found")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(inboundEmailTokenHeader)), []byte(token)) != 1 {
+			response.WriteError(w, r, http.StatusUnauthorized, response.CodeUnauthorized, "Unauthorized")
+			return
+		}
+
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboundEmailSize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				response.WriteError(w, r, http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge,
+					"Request Entity Too Large")
+				return
+			}
+			response.WriteError(w, r, http.StatusBadRequest, response.CodeInvalidRequest, "Bad Request")
+			return
+		}
+
+		outboundSMS, err := receiver.Receive(r.Context(), raw, r.URL.Query()["recipient"])
+		switch {
+		case errors.Is(err, emailreply.ErrDuplicate):
+			w.WriteHeader(http.StatusOK)
+		case errors.Is(err, emailreply.ErrRejected):
+			logger.Printf("rejected reply email: %v", err)
+			response.WriteError(w, r, http.StatusUnprocessableEntity, response.CodeUnprocessable,
+				"Reply email rejected")
+		case err != nil:
+			logger.Printf("failed to receive reply email: %v", err)
+			response.WriteError(w, r, http.StatusInternalServerError, response.CodeInternalError,
+				"Internal Server Error")
+		default:
+			logger.Printf("reply email queued as outbound SMS %s", outboundSMS.ID)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+}
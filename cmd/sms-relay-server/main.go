@@ -0,0 +1,235 @@
+// Command sms-relay-server runs the SMS relay as a single self-hosted server. It serves the same
+// routes as API Gateway, validates tokens like sms-relay-api-authenticator, and forwards SMS like
+// sms-relay-forwarder from an in-process worker.
+//
+// It is configured through environment variables:
+//   - LISTEN_ADDR: address to serve HTTP on, defaults to ":8080"
+//   - AWS_REGION: AWS region of the DynamoDB tables and secrets, defaults to "us-west-2"
+//   - STORE: where to store data, one of "dynamodb" (default), "sqlite" or "memory"
+//   - SQLITE_PATH: path of the SQLite database, defaults to "sms-relay.db"
+//   - QUEUE: how to queue SMS for the forwarder, one of "memory" (default), "sqlite" or "sqs"
+//   - QUEUE_SQLITE_PATH: path of the SQLite queue database, defaults to "sms-relay-queue.db"
+//   - SMS_RELAY_REQUEST_QUEUE_URL: URL of the SQS queue when QUEUE is "sqs"
+//   - SECRETS_BACKEND, SECRETS_DIR, SECRETS_CACHE_TTL: where to read secrets from, see
+//     common.NewSecretsProviderFromEnv
+//   - SMTP_SERVER, SMTP_PORT, SSL, REPLY_EMAIL_ADDRESS, TELEGRAM_API_BASE_URL: configuration of the
+//     forwarders, see forwarder.NewRegistryFromEnv
+//   - DEVICE_OFFLINE_AFTER: how long devices may miss heartbeats before they are reported offline,
+//     see monitor.NewCheckerFromEnv
+//   - DEVICE_CHECK_INTERVAL: how often device heartbeats are checked, defaults to "1m"
+//
+// Besides the API routes, it serves POST /email/inbound to receive replies to forwarded SMS emails
+// from a local MTA, see inboundEmailHandler.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/zhouziqunzzq/sms-relay-server/api"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/emailreply"
+	"github.com/zhouziqunzzq/sms-relay-server/forwarder"
+	"github.com/zhouziqunzzq/sms-relay-server/httpadapter"
+	"github.com/zhouziqunzzq/sms-relay-server/monitor"
+	"github.com/zhouziqunzzq/sms-relay-server/queue"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	defaultAWSRegion  = "us-west-2"
+	defaultListenAddr = ":8080"
+	defaultSQLitePath = "sms-relay.db"
+
+	defaultDeviceCheckInterval = time.Minute
+
+	revocationCacheTTL = time.Second * 30 // Like sms-relay-api-authenticator
+
+	defaultQueueSQLitePath = "sms-relay-queue.db"
+	queueName              = "SMSRelayRequestQueue"
+	deadLetterQueueName    = "SMSRelayRequestDLQ"
+
+	shutdownTimeout = time.Second * 10
+)
+
+var logger = log.Default()
+
+func main() {
+	// Load configuration from environment variables
+	listenAddr := defaultListenAddr
+	if addr := os.Getenv("LISTEN_ADDR"); addr != "" {
+		listenAddr = addr
+	}
+	awsRegion := defaultAWSRegion
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		awsRegion = region
+	}
+
+	// Initialize AWS clients
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(awsRegion))
+	if err != nil {
+		logger.Fatalf("unable to load SDK config, %v", err)
+	}
+	secrets, err := common.NewSecretsProviderFromEnv(cfg)
+	if err != nil {
+		logger.Fatalf("unable to initialize secrets provider, %v", err)
+	}
+	logger.Println("secrets provider initialized")
+
+	// Open the store and the queue
+	dataStore, closeStore := openStore(cfg)
+	defer closeStore()
+	requestQueue, closeQueue := openQueue(cfg)
+	defer closeQueue()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the forwarder worker
+	registry, err := forwarder.NewRegistryFromEnv(secrets)
+	if err != nil {
+		logger.Fatalf("unable to initialize forwarders, %v", err)
+	}
+	processor := &forwarder.Processor{
+		Registry: registry,
+		Records:  dataStore,
+	}
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		queue.Consume(ctx, requestQueue, func(ctx context.Context, message queue.Message) error {
+			return processor.ProcessMessage(ctx, message.ID, message.Body)
+		})
+	}()
+
+	// Start the device monitor, which alerts through the same forwarders
+	deviceCheckInterval := defaultDeviceCheckInterval
+	if interval := os.Getenv("DEVICE_CHECK_INTERVAL"); interval != "" {
+		deviceCheckInterval, err = time.ParseDuration(interval)
+		if err != nil || deviceCheckInterval <= 0 {
+			logger.Fatalf("invalid DEVICE_CHECK_INTERVAL %q", interval)
+		}
+	}
+	checker, err := monitor.NewCheckerFromEnv(dataStore, registry)
+	if err != nil {
+		logger.Fatalf("unable to initialize device checker, %v", err)
+	}
+	go checker.Run(ctx, deviceCheckInterval)
+
+	// Serve the API
+	handler := &api.Handler{
+		Store:   dataStore,
+		Secrets: secrets,
+		Queue:   requestQueue,
+	}
+	mux := http.NewServeMux()
+	mux.Handle(inboundEmailPath, inboundEmailHandler(secrets, &emailreply.Receiver{
+		Store:   dataStore,
+		Secrets: secrets,
+	}))
+	revocations := auth.NewRevocationList(dataStore, revocationCacheTTL)
+	mux.Handle("/", httpadapter.Handler(authorize(&auth.KeySetValidator{Secrets: secrets}, revocations, handler.Handle)))
+	server := &http.Server{
+		Addr:              listenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Printf("failed to shut down HTTP server: %v", err)
+		}
+	}()
+
+	logger.Printf("listening on %s", listenAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalf("HTTP server failed: %v", err)
+	}
+	<-workerDone
+	logger.Println("server stopped")
+}
+
+// openStore opens the store selected by the STORE environment variable, and returns it along with
+// a function closing it.
+func openStore(cfg aws.Config) (store.Store, func()) {
+	switch storeType := os.Getenv("STORE"); storeType {
+	case "", "dynamodb":
+		return store.NewDynamoDBStore(dynamodb.NewFromConfig(cfg)), func() {}
+	case "sqlite":
+		sqlitePath := defaultSQLitePath
+		if path := os.Getenv("SQLITE_PATH"); path != "" {
+			sqlitePath = path
+		}
+		sqliteStore, err := store.OpenSQLiteStore(sqlitePath)
+		if err != nil {
+			logger.Fatalf("failed to open SQLite store %s: %v", sqlitePath, err)
+		}
+		return sqliteStore, func() { sqliteStore.Close() }
+	case "memory":
+		logger.Println("using in-memory store, data will be lost on exit")
+		return store.NewMemoryStore(), func() {}
+	default:
+		logger.Fatalf("unknown STORE %q", storeType)
+		return nil, nil
+	}
+}
+
+type publisherConsumer interface {
+	queue.Publisher
+	queue.Consumer
+}
+
+// openQueue opens the queue selected by the QUEUE environment variable, and returns it along with
+// a function closing it. The local queues dead-letter messages like SMSRelayRequestQueue does.
+func openQueue(cfg aws.Config) (publisherConsumer, func()) {
+	switch queueType := os.Getenv("QUEUE"); queueType {
+	case "", "memory":
+		logger.Println("using in-memory queue, queued SMS will be lost on exit")
+		return queue.NewMemoryQueue(&queue.RedrivePolicy{
+			MaxReceiveCount: queue.DefaultMaxReceiveCount,
+			DeadLetterQueue: queue.NewMemoryQueue(nil),
+		}), func() {}
+	case "sqlite":
+		sqlitePath := defaultQueueSQLitePath
+		if path := os.Getenv("QUEUE_SQLITE_PATH"); path != "" {
+			sqlitePath = path
+		}
+		deadLetterQueue, err := queue.OpenSQLiteQueue(sqlitePath, deadLetterQueueName, nil)
+		if err != nil {
+			logger.Fatalf("failed to open SQLite queue %s: %v", sqlitePath, err)
+		}
+		requestQueue, err := queue.OpenSQLiteQueue(sqlitePath, queueName, &queue.RedrivePolicy{
+			MaxReceiveCount: queue.DefaultMaxReceiveCount,
+			DeadLetterQueue: deadLetterQueue,
+		})
+		if err != nil {
+			logger.Fatalf("failed to open SQLite queue %s: %v", sqlitePath, err)
+		}
+		return requestQueue, func() {
+			requestQueue.Close()
+			deadLetterQueue.Close()
+		}
+	case "sqs":
+		queueURL := os.Getenv("SMS_RELAY_REQUEST_QUEUE_URL")
+		if queueURL == "" {
+			logger.Fatalf("SMS_RELAY_REQUEST_QUEUE_URL environment variable is not set")
+		}
+		return queue.NewSQSQueue(sqs.NewFromConfig(cfg), queueURL), func() {}
+	default:
+		logger.Fatalf("unknown QUEUE %q", queueType)
+		return nil, nil
+	}
+}
@@ -4,35 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 )
 
-// GetSecretValue retrieves the value of a secret from AWS Secrets Manager. If a key is provided,
+const (
+	DefaultSecretsCacheTTL = time.Minute * 5
+
+	envSecretPrefix = "SMS_RELAY_SECRET_"
+)
+
+// SecretsProvider provides the values of secrets by name.
+type SecretsProvider interface {
+	// GetSecret returns the value of the secret with the given name.
+	GetSecret(ctx context.Context, secretName string) (string, error)
+}
+
+// GetSecretValue retrieves the value of a secret from the provider. If a key is provided,
 // it parses the JSON secret and returns the value for the key.
-func GetSecretValue(ctx context.Context, secretsClient *secretsmanager.Client, secretName string, key string) (string, error) {
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: &secretName,
-	}
-	result, err := secretsClient.GetSecretValue(ctx, input)
+func GetSecretValue(ctx context.Context, provider SecretsProvider, secretName string, key string) (string, error) {
+	secretString, err := provider.GetSecret(ctx, secretName)
 	if err != nil {
-		var resourceNotFound *types.ResourceNotFoundException
-		if errors.As(err, &resourceNotFound) {
-			log.Printf("secret %s not found\n", secretName)
-			return "", err
-		}
-		log.Printf("error fetching secret %s: %v\n", secretName, err)
 		return "", err
 	}
 
 	if key == "" {
-		return *result.SecretString, nil
+		return secretString, nil
 	}
 
 	var secretMap map[string]string
-	if err := json.Unmarshal([]byte(*result.SecretString), &secretMap); err != nil {
+	if err := json.Unmarshal([]byte(secretString), &secretMap); err != nil {
 		log.Printf("error parsing secret %s as JSON: %v\n", secretName, err)
 		return "", err
 	}
@@ -45,3 +54,90 @@ func GetSecretValue(ctx context.Context, secretsClient *secretsmanager.Client, s
 
 	return value, nil
 }
+
+// SecretsManagerProvider provides secrets stored in AWS Secrets Manager.
+type SecretsManagerProvider struct {
+	Client *secretsmanager.Client
+}
+
+func (p *SecretsManagerProvider) GetSecret(ctx context.Context, secretName string) (string, error) {
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: &secretName,
+	}
+	result, err := p.Client.GetSecretValue(ctx, input)
+	if err != nil {
+		var resourceNotFound *types.ResourceNotFoundException
+		if errors.As(err, &resourceNotFound) {
+			log.Printf("secret %s not found\n", secretName)
+			return "", err
+		}
+		log.Printf("error fetching secret %s: %v\n", secretName, err)
+		return "", err
+	}
+	return aws.ToString(result.SecretString), nil
+}
+
+// EnvSecretsProvider provides secrets from environment variables. The value of a secret is read
+// from SMS_RELAY_SECRET_ followed by its name in upper case, e.g. SMS_RELAY_SECRET_JWTSECRET.
+type EnvSecretsProvider struct{}
+
+func (EnvSecretsProvider) GetSecret(ctx context.Context, secretName string) (string, error) {
+	envName := envSecretPrefix + strings.ToUpper(secretName)
+	value, ok := os.LookupEnv(envName)
+	if !ok {
+		return "", fmt.Errorf("secret %s not found: %s is not set", secretName, envName)
+	}
+	return value, nil
+}
+
+// FileSecretsProvider provides secrets from files named after them in a directory, as mounted by
+// Docker or Kubernetes secrets. A trailing newline of the files is ignored.
+type FileSecretsProvider struct {
+	Dir string
+}
+
+func (p *FileSecretsProvider) GetSecret(ctx context.Context, secretName string) (string, error) {
+	if secretName != filepath.Base(secretName) {
+		return "", fmt.Errorf("invalid secret name %q", secretName)
+	}
+	value, err := os.ReadFile(filepath.Join(p.Dir, secretName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", secretName, err)
+	}
+	return strings.TrimRight(string(value), "\r\n"), nil
+}
+
+// NewSecretsProviderFromEnv returns the secrets provider selected by the SECRETS_BACKEND
+// environment variable, wrapped in a cache:
+//   - "secretsmanager" (default): AWS Secrets Manager
+//   - "env": environment variables, see EnvSecretsProvider
+//   - "file": files in the SECRETS_DIR directory, see FileSecretsProvider
+//
+// The cache TTL can be set with SECRETS_CACHE_TTL as a Go duration, e.g. "10m".
+func NewSecretsProviderFromEnv(cfg aws.Config) (*CachingSecretsProvider, error) {
+	var provider SecretsProvider
+	switch backend := os.Getenv("SECRETS_BACKEND"); backend {
+	case "", "secretsmanager":
+		provider = &SecretsManagerProvider{Client: secretsmanager.NewFromConfig(cfg)}
+	case "env":
+		provider = EnvSecretsProvider{}
+	case "file":
+		dir := os.Getenv("SECRETS_DIR")
+		if dir == "" {
+			return nil, errors.New("SECRETS_DIR environment variable is not set")
+		}
+		provider = &FileSecretsProvider{Dir: dir}
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_BACKEND %q", backend)
+	}
+
+	ttl := DefaultSecretsCacheTTL
+	if ttlEnv := os.Getenv("SECRETS_CACHE_TTL"); ttlEnv != "" {
+		var err error
+		if ttl, err = time.ParseDuration(ttlEnv); err != nil {
+			return nil, fmt.Errorf("invalid SECRETS_CACHE_TTL: %w", err)
+		}
+	}
+
+	return NewCachingSecretsProvider(provider, ttl), nil
+}
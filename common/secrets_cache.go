@@ -0,0 +1,79 @@
+package common
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// minSecretRefreshInterval limits how often Refresh refetches a secret, so that a stream of
+// requests with bad signatures cannot turn into a stream of calls to the secrets backend.
+const minSecretRefreshInterval = time.Minute
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// CachingSecretsProvider caches the secrets of another provider for a TTL. Kept in a package-level
+// variable of a Lambda, the cache is shared by all invocations of a warm instance.
+type CachingSecretsProvider struct {
+	Provider SecretsProvider
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	secrets map[string]cachedSecret
+}
+
+func NewCachingSecretsProvider(provider SecretsProvider, ttl time.Duration) *CachingSecretsProvider {
+	return &CachingSecretsProvider{
+		Provider: provider,
+		TTL:      ttl,
+		secrets:  make(map[string]cachedSecret),
+	}
+}
+
+func (p *CachingSecretsProvider) GetSecret(ctx context.Context, secretName string) (string, error) {
+	p.mu.Lock()
+	cached, ok := p.secrets[secretName]
+	p.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < p.TTL {
+		return cached.value, nil
+	}
+
+	value, err := p.Provider.GetSecret(ctx, secretName)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.secrets[secretName] = cachedSecret{value: value, fetchedAt: time.Now()}
+	p.mu.Unlock()
+	return value, nil
+}
+
+// Refresh drops the cached value of a secret so that the next GetSecret fetches it again, and
+// reports whether it did. It is meant to be called when an authentication or signature failure
+// suggests that the secret was rotated. Secrets fetched less than a minute ago are kept.
+func (p *CachingSecretsProvider) Refresh(secretName string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cached, ok := p.secrets[secretName]
+	if !ok || time.Since(cached.fetchedAt) < minSecretRefreshInterval {
+		return false
+	}
+	delete(p.secrets, secretName)
+	log.Printf("refreshing secret %s\n", secretName)
+	return true
+}
+
+// RefreshSecret calls Refresh on the provider if it is a CachingSecretsProvider, and reports
+// whether the secret will be fetched again.
+func RefreshSecret(provider SecretsProvider, secretName string) bool {
+	if caching, ok := provider.(*CachingSecretsProvider); ok {
+		return caching.Refresh(secretName)
+	}
+	return false
+}
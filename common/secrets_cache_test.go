@@ -0,0 +1,96 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// countingSecrets is a secrets backend returning a new value on every fetch, e.g. "v2" on the
+// second, or err if set.
+type countingSecrets struct {
+	fetches int
+	err     error
+}
+
+func (s *countingSecrets) GetSecret(ctx context.Context, secretName string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	s.fetches++
+	return fmt.Sprintf("v%d", s.fetches), nil
+}
+
+// getSecret gets the secret from the provider and checks its value.
+func getSecret(t *testing.T, p *CachingSecretsProvider, want string) {
+	t.Helper()
+	value, err := p.GetSecret(context.Background(), "JWTSecret")
+	if err != nil {
+		t.Fatalf("GetSecret: %v", err)
+	}
+	if value != want {
+		t.Errorf("got %q, want %q", value, want)
+	}
+}
+
+func TestCachingSecretsProviderHitWithinTTL(t *testing.T) {
+	backend := &countingSecrets{}
+	p := NewCachingSecretsProvider(backend, time.Hour)
+	getSecret(t, p, "v1")
+	getSecret(t, p, "v1")
+	if backend.fetches != 1 {
+		t.Errorf("got %d fetches, want 1", backend.fetches)
+	}
+}
+
+func TestCachingSecretsProviderRefetchAfterTTL(t *testing.T) {
+	backend := &countingSecrets{}
+	p := NewCachingSecretsProvider(backend, time.Millisecond*10)
+	getSecret(t, p, "v1")
+	time.Sleep(time.Millisecond * 20)
+	getSecret(t, p, "v2")
+}
+
+func TestCachingSecretsProviderRefresh(t *testing.T) {
+	backend := &countingSecrets{}
+	p := NewCachingSecretsProvider(backend, time.Hour)
+	if RefreshSecret(p, "JWTSecret") {
+		t.Error("refreshed a secret that was never fetched")
+	}
+	getSecret(t, p, "v1")
+
+	// A secret that was just fetched is kept, however many signatures fail
+	if RefreshSecret(p, "JWTSecret") {
+		t.Error("refreshed a secret fetched less than a minute ago")
+	}
+	getSecret(t, p, "v1")
+
+	p.mu.Lock()
+	p.secrets["JWTSecret"] = cachedSecret{value: "v1", fetchedAt: time.Now().Add(-minSecretRefreshInterval)}
+	p.mu.Unlock()
+	if !RefreshSecret(p, "JWTSecret") {
+		t.Error("didn't refresh a secret fetched a minute ago")
+	}
+	getSecret(t, p, "v2")
+	if backend.fetches != 2 {
+		t.Errorf("got %d fetches, want 2", backend.fetches)
+	}
+}
+
+func TestCachingSecretsProviderDoesNotCacheErrors(t *testing.T) {
+	backend := &countingSecrets{err: errors.New("backend unavailable")}
+	p := NewCachingSecretsProvider(backend, time.Hour)
+	if _, err := p.GetSecret(context.Background(), "JWTSecret"); err == nil {
+		t.Fatal("got no error from a failing backend")
+	}
+	backend.err = nil
+	getSecret(t, p, "v1")
+}
+
+func TestRefreshSecretOfUncachedProvider(t *testing.T) {
+	if RefreshSecret(&countingSecrets{}, "JWTSecret") {
+		t.Error("RefreshSecret reported a refresh of a provider without cache")
+	}
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries the signature of a webhook request, "sha256=" followed by the
+	// hex-encoded HMAC-SHA256 of the timestamp, a dot and the request body.
+	WebhookSignatureHeader = "X-Signature"
+	// WebhookTimestampHeader carries the Unix time in seconds at which a webhook request was signed.
+	WebhookTimestampHeader = "X-Signature-Timestamp"
+
+	// DefaultWebhookTolerance is the maximum accepted age of a webhook request, which limits replays.
+	DefaultWebhookTolerance = time.Minute * 5
+
+	webhookSignaturePrefix = "sha256="
+)
+
+var (
+	ErrWebhookSignatureMismatch = errors.New("webhook signature mismatch")
+	ErrWebhookTimestampExpired  = errors.New("webhook timestamp outside of tolerance")
+)
+
+// SignWebhook returns the value of the WebhookSignatureHeader of a webhook request with the given
+// timestamp (Unix seconds, as sent in the WebhookTimestampHeader) and body.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature and timestamp headers of a webhook request against
+// its body. Requests signed more than tolerance away from now are rejected.
+func VerifyWebhookSignature(secret []byte, signature string, timestamp string, body []byte, tolerance time.Duration) error {
+	if !strings.HasPrefix(signature, webhookSignaturePrefix) {
+		return ErrWebhookSignatureMismatch
+	}
+	unixSeconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookTimestampExpired
+	}
+	age := time.Since(time.Unix(unixSeconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrWebhookTimestampExpired
+	}
+
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrWebhookSignatureMismatch
+	}
+	return nil
+}
+
+// VerifyWebhookRequest reads the body of a webhook request and verifies its signature with
+// DefaultWebhookTolerance. The body is returned only if the signature is valid.
+func VerifyWebhookRequest(r *http.Request, secret []byte) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	err = VerifyWebhookSignature(secret, r.Header.Get(WebhookSignatureHeader),
+		r.Header.Get(WebhookTimestampHeader), body, DefaultWebhookTolerance)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
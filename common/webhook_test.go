@@ -0,0 +1,91 @@
+package common
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testWebhookSecret = []byte("webhook-secret")
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"sms":{"body":"hello"}}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := SignWebhook(testWebhookSecret, now, body)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		signature string
+		timestamp string
+		body      []byte
+		want      error
+	}{
+		{name: "valid", secret: testWebhookSecret, signature: signature, timestamp: now, body: body},
+		{name: "wrong secret", secret: []byte("other-secret"), signature: signature, timestamp: now, body: body, want: ErrWebhookSignatureMismatch},
+		{name: "tampered body", secret: testWebhookSecret, signature: signature, timestamp: now, body: []byte(`{"sms":{"body":"hellO"}}`), want: ErrWebhookSignatureMismatch},
+		{name: "missing prefix", secret: testWebhookSecret, signature: strings.TrimPrefix(signature, "sha256="), timestamp: now, body: body, want: ErrWebhookSignatureMismatch},
+		{name: "missing signature", secret: testWebhookSecret, timestamp: now, body: body, want: ErrWebhookSignatureMismatch},
+		{name: "invalid timestamp", secret: testWebhookSecret, signature: signature, timestamp: "now", body: body, want: ErrWebhookTimestampExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tt.secret, tt.signature, tt.timestamp, tt.body, DefaultWebhookTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignatureTimestamp(t *testing.T) {
+	body := []byte(`{}`)
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   error
+	}{
+		{name: "recent", offset: -time.Minute},
+		{name: "slight clock skew", offset: time.Minute},
+		{name: "stale", offset: -DefaultWebhookTolerance - time.Minute, want: ErrWebhookTimestampExpired},
+		{name: "future", offset: DefaultWebhookTolerance + time.Minute, want: ErrWebhookTimestampExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Signed with the right secret, so that only the timestamp can be rejected
+			timestamp := strconv.FormatInt(time.Now().Add(tt.offset).Unix(), 10)
+			signature := SignWebhook(testWebhookSecret, timestamp, body)
+			err := VerifyWebhookSignature(testWebhookSecret, signature, timestamp, body, DefaultWebhookTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookRequest(t *testing.T) {
+	body := `{"sms":{"body":"hello"}}`
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	r := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	r.Header.Set(WebhookTimestampHeader, timestamp)
+	r.Header.Set(WebhookSignatureHeader, SignWebhook(testWebhookSecret, timestamp, []byte(body)))
+	got, err := VerifyWebhookRequest(r, testWebhookSecret)
+	if err != nil {
+		t.Fatalf("VerifyWebhookRequest: %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("got body %q, want %q", got, body)
+	}
+
+	r = httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	r.Header.Set(WebhookTimestampHeader, timestamp)
+	r.Header.Set(WebhookSignatureHeader, SignWebhook([]byte("other-secret"), timestamp, []byte(body)))
+	got, err = VerifyWebhookRequest(r, testWebhookSecret)
+	if !errors.Is(err, ErrWebhookSignatureMismatch) || got != nil {
+		t.Errorf("got %q, %v, want no body and ErrWebhookSignatureMismatch", got, err)
+	}
+}
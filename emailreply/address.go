@@ -0,0 +1,123 @@
+// Package emailreply implements reply-by-email: forwarded SMS emails carry a signed Reply-To
+// address naming the phone number and the original sender, and replies to that address are turned
+// into outbound SMS.
+package emailreply
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	secretName = "ReplyEmailSecret"
+	secretKey  = "key"
+
+	tokenVersion = 1
+	macLength    = 8 // Truncated HMAC-SHA256, keeping the local part within the 64 character limit
+)
+
+var (
+	// ErrNotReplyable is returned for phone numbers or senders that can't be encoded in a reply
+	// address, e.g. alphanumeric sender IDs, which can't be replied to anyway.
+	ErrNotReplyable = errors.New("SMS can't be replied to by email")
+	// ErrInvalidReplyAddress is returned for addresses that are not valid signed reply addresses.
+	ErrInvalidReplyAddress = errors.New("invalid reply address")
+
+	tokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GetSecret returns the key signing reply addresses from the secrets provider.
+func GetSecret(ctx context.Context, secrets common.SecretsProvider) ([]byte, error) {
+	key, err := common.GetSecretValue(ctx, secrets, secretName, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(key), nil
+}
+
+// EncodeReplyAddress returns the reply address of an SMS sent by sender to the phone number, made by
+// adding a signed token to the local part of baseAddress, e.g. "reply+<token>@example.com".
+//
+// The token packs the phone number's UUID, the sender's E.164 digits and a MAC into at most 34
+// bytes, which encode to 55 case-insensitive characters.
+func EncodeReplyAddress(secret []byte, baseAddress string, phoneNumberID string, sender string) (string, error) {
+	at := strings.LastIndex(baseAddress, "@")
+	if at <= 0 {
+		return "", errors.New("invalid base reply address")
+	}
+	phoneNumberUUID, err := uuid.Parse(phoneNumberID)
+	if err != nil || !models.IsE164(sender) {
+		return "", ErrNotReplyable
+	}
+
+	digits := sender[1:]
+	payload := make([]byte, 0, 2+len(phoneNumberUUID)+(len(digits)+1)/2+macLength)
+	payload = append(payload, tokenVersion)
+	payload = append(payload, phoneNumberUUID[:]...)
+	payload = append(payload, byte(len(digits)))
+	for i := 0; i < len(digits); i += 2 {
+		b := (digits[i] - '0') << 4
+		if i+1 < len(digits) {
+			b |= digits[i+1] - '0'
+		}
+		payload = append(payload, b)
+	}
+	payload = append(payload, tokenMAC(secret, payload)...)
+
+	token := strings.ToLower(tokenEncoding.EncodeToString(payload))
+	return baseAddress[:at] + "+" + token + baseAddress[at:], nil
+}
+
+// DecodeReplyAddress verifies a reply address made by EncodeReplyAddress and returns the phone
+// number ID and sender it encodes.
+func DecodeReplyAddress(secret []byte, address string) (phoneNumberID string, sender string, err error) {
+	at := strings.LastIndex(address, "@")
+	plus := strings.LastIndex(address[:max(at, 0)], "+")
+	if at <= 0 || plus < 0 {
+		return "", "", ErrInvalidReplyAddress
+	}
+	payload, err := tokenEncoding.DecodeString(strings.ToUpper(address[plus+1 : at]))
+	if err != nil || len(payload) < 1+16+1+macLength || payload[0] != tokenVersion {
+		return "", "", ErrInvalidReplyAddress
+	}
+
+	// Verify the MAC before trusting any of the payload
+	data, mac := payload[:len(payload)-macLength], payload[len(payload)-macLength:]
+	if !hmac.Equal(mac, tokenMAC(secret, data)) {
+		return "", "", ErrInvalidReplyAddress
+	}
+
+	phoneNumberUUID, err := uuid.FromBytes(data[1:17])
+	if err != nil {
+		return "", "", ErrInvalidReplyAddress
+	}
+	digitCount := int(data[17])
+	packed := data[18:]
+	if len(packed) != (digitCount+1)/2 {
+		return "", "", ErrInvalidReplyAddress
+	}
+	var b strings.Builder
+	b.WriteByte('+')
+	for i := 0; i < digitCount; i++ {
+		digit := packed[i/2] >> 4
+		if i%2 == 1 {
+			digit = packed[i/2] & 0x0f
+		}
+		b.WriteByte('0' + digit)
+	}
+	return phoneNumberUUID.String(), b.String(), nil
+}
+
+func tokenMAC(secret []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:macLength]
+}
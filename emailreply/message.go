@@ -0,0 +1,218 @@
+package emailreply
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// maxMIMEDepth bounds the nesting of multipart bodies that are searched for the text of a message.
+const maxMIMEDepth = 5
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlHeadPattern  = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+
+	// replyHeaderPatterns match the lines mail clients put above the quoted original message.
+	replyHeaderPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^On\b.*\bwrote:\s*$`),               // Gmail, Apple Mail, Thunderbird
+		regexp.MustCompile(`^-{2,}\s*Original Message\s*-{2,}`), // Outlook
+		regexp.MustCompile(`^_{10,}\s*$`),                       // Outlook on the web
+		regexp.MustCompile(`^Am\b.*\bschrieb\b.*:\s*$`),         // German clients
+		regexp.MustCompile(`^Le\b.*\ba écrit\s*:\s*$`),          // French clients
+		regexp.MustCompile(`写道[:：]\s*$`),                        // Chinese clients
+	}
+	// signaturePatterns match the first line of signatures.
+	signaturePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^-- ?$`),
+		regexp.MustCompile(`^Sent from my \w+`),
+		regexp.MustCompile(`^Get Outlook for \w+`),
+	}
+	outlookHeaderPattern = regexp.MustCompile(`^(Sent|Date|To|Subject):\s`)
+)
+
+// Message is the part of an inbound email needed to turn it into an SMS.
+type Message struct {
+	MessageID  string   // Message-ID header, without angle brackets
+	From       string   // Address of the sender
+	Recipients []string // Addresses of the To and Cc headers
+	Subject    string
+	Text       string // Plain text body, converted from HTML if there is no plain text part
+}
+
+// ParseMessage parses a raw RFC 5322 email.
+func ParseMessage(raw []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, errors.New("invalid From header")
+	}
+	var recipients []string
+	for _, header := range []string{"To", "Cc"} {
+		addresses, err := msg.Header.AddressList(header)
+		if err != nil {
+			continue // Missing or malformed, the envelope recipients may still be known
+		}
+		for _, address := range addresses {
+			recipients = append(recipients, address.Address)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	plain, htmlText, err := findText(msg.Header.Get("Content-Type"),
+		msg.Header.Get("Content-Transfer-Encoding"), msg.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+	text := plain
+	if text == "" && htmlText != "" {
+		text = htmlToText(htmlText)
+	}
+
+	return &Message{
+		MessageID:  strings.Trim(strings.TrimSpace(msg.Header.Get("Message-ID")), "<>"),
+		From:       from.Address,
+		Recipients: recipients,
+		Subject:    subject,
+		Text:       text,
+	}, nil
+}
+
+// findText returns the first text/plain and text/html bodies of a MIME entity, searching multipart
+// entities recursively and skipping attachments.
+func findText(contentType string, transferEncoding string, body io.Reader, depth int) (plain string, htmlText string, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil // The default of RFC 2045
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxMIMEDepth || params["boundary"] == "" {
+			return "", "", nil
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", "", err
+			}
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+			partPlain, partHTML, err := findText(part.Header.Get("Content-Type"),
+				part.Header.Get("Content-Transfer-Encoding"), part, depth+1)
+			if err != nil {
+				return "", "", err
+			}
+			if plain == "" {
+				plain = partPlain
+			}
+			if htmlText == "" {
+				htmlText = partHTML
+			}
+		}
+		return plain, htmlText, nil
+	case mediaType == "text/plain" || mediaType == "text/html":
+		text, err := decodeBody(transferEncoding, params["charset"], body)
+		if err != nil {
+			return "", "", err
+		}
+		if mediaType == "text/html" {
+			return "", text, nil
+		}
+		return text, "", nil
+	default:
+		return "", "", nil
+	}
+}
+
+// decodeBody undoes the transfer encoding of a text body and converts it to UTF-8.
+func decodeBody(transferEncoding string, charset string, body io.Reader) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	default:
+		return strings.ToValidUTF8(string(data), "�"), nil
+	}
+}
+
+// htmlToText crudely converts an HTML body to text, which is enough for short replies.
+func htmlToText(htmlText string) string {
+	htmlText = htmlHeadPattern.ReplaceAllString(htmlText, "")
+	htmlText = htmlBreakPattern.ReplaceAllString(htmlText, "\n")
+	htmlText = htmlTagPattern.ReplaceAllString(htmlText, "")
+	return html.UnescapeString(htmlText)
+}
+
+// StripQuotedReply returns the newly written part of a reply, dropping the quoted original message
+// and the signature that mail clients append.
+func StripQuotedReply(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var kept []string
+lines:
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			break
+		}
+		// Reply headers may be wrapped over two lines, in which case the header starts on this line
+		// only if the next line doesn't match on its own
+		next := ""
+		if i+1 < len(lines) {
+			next = strings.TrimSpace(lines[i+1])
+		}
+		for _, pattern := range replyHeaderPatterns {
+			if pattern.MatchString(trimmed) {
+				break lines
+			}
+			if next != "" && pattern.MatchString(trimmed+" "+next) && !pattern.MatchString(next) {
+				break lines
+			}
+		}
+		for _, pattern := range signaturePatterns {
+			if pattern.MatchString(line) {
+				break lines
+			}
+		}
+		if strings.HasPrefix(trimmed, "From:") && outlookHeaderPattern.MatchString(next) {
+			break
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
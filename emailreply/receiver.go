@@ -0,0 +1,146 @@
+package emailreply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/outbound"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+// idempotencyRecordTTL is how long the Message-ID of a received reply is remembered, so that
+// redelivered emails are not sent twice.
+const idempotencyRecordTTL = time.Hour * 24
+
+var logger = log.Default()
+
+var (
+	// ErrRejected marks emails that can never be turned into an SMS, so retrying is pointless.
+	ErrRejected = errors.New("reply email rejected")
+	// ErrDuplicate is returned for emails whose Message-ID was already received.
+	ErrDuplicate = errors.New("reply email already received")
+)
+
+// Receiver turns replies to forwarded SMS emails into outbound SMS to the original sender.
+type Receiver struct {
+	Store   store.Store
+	Secrets common.SecretsProvider
+}
+
+// Receive parses a raw reply email and queues its text as an outbound SMS. envelopeRecipients are
+// the SMTP recipients of the email, if known, which are searched for the reply address along with
+// the To and Cc headers.
+//
+// The reply must come from an email forward destination of the phone number, so that a leaked
+// reply address alone doesn't allow sending SMS. Errors wrapping ErrRejected are permanent.
+func (r *Receiver) Receive(ctx context.Context, raw []byte, envelopeRecipients []string) (*models.OutboundSMS, error) {
+	msg, err := ParseMessage(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse email: %w", ErrRejected, err)
+	}
+
+	// Find the phone number and the sender to reply to
+	secret, err := GetSecret(ctx, r.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reply email secret: %w", err)
+	}
+	var phoneNumberID, sender string
+	for _, recipient := range slices.Concat(envelopeRecipients, msg.Recipients) {
+		phoneNumberID, sender, err = DecodeReplyAddress(secret, recipient)
+		if err == nil {
+			break
+		}
+	}
+	if phoneNumberID == "" {
+		return nil, fmt.Errorf("%w: no valid reply address among the recipients", ErrRejected)
+	}
+	phoneNumber, err := r.Store.GetPhoneNumberByID(ctx, phoneNumberID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get phone number by ID: %w", err)
+	}
+	if phoneNumber == nil {
+		return nil, fmt.Errorf("%w: phone number %s not found", ErrRejected, phoneNumberID)
+	}
+	if !isEmailDestination(phoneNumber, msg.From) {
+		return nil, fmt.Errorf("%w: %s is not an email destination of phone number %s",
+			ErrRejected, msg.From, phoneNumberID)
+	}
+
+	// Build the request before claiming the Message-ID, so that invalid replies are rejected
+	// without being remembered
+	req := outbound.Request{
+		PhoneNumber: phoneNumber,
+		To:          sender,
+		Body:        StripQuotedReply(msg.Text),
+		EmailFrom:   msg.From,
+	}
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+
+	// Deduplicate redelivered emails by their Message-ID
+	recordID := ""
+	if msg.MessageID != "" {
+		now := time.Now()
+		recordID = "email#" + msg.MessageID
+		created, err := r.Store.CreateIdempotencyRecord(ctx, &models.IdempotencyRecord{
+			ID:        recordID,
+			Status:    models.IdempotencyStatusPending,
+			CreatedAt: models.FormatTimestamp(now),
+			ExpiresAt: now.Add(idempotencyRecordTTL).Unix(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim Message-ID: %w", err)
+		}
+		if !created {
+			return nil, ErrDuplicate
+		}
+	}
+
+	outboundSMS, err := outbound.QueueSMS(ctx, r.Store, req)
+	if err != nil {
+		// Release the Message-ID so that the email can be retried
+		if recordID != "" {
+			if deleteErr := r.Store.DeleteIdempotencyRecord(ctx, recordID); deleteErr != nil {
+				err = errors.Join(err, deleteErr)
+			}
+		}
+		if errors.Is(err, outbound.ErrNotAttached) {
+			return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+		return nil, err
+	}
+	if recordID != "" {
+		// A record left pending still rejects redeliveries until it expires
+		if err := r.Store.CompleteIdempotencyRecord(ctx, recordID, 202, nil, outboundSMS.ID); err != nil {
+			logger.Printf("failed to complete idempotency record %s: %v", recordID, err)
+		}
+	}
+
+	return outboundSMS, nil
+}
+
+// isEmailDestination reports whether the address is one of the email forward destinations of the
+// phone number.
+func isEmailDestination(phoneNumber *models.PhoneNumber, address string) bool {
+	for _, dest := range phoneNumber.ForwardDestinations {
+		if dest.Type != models.ForwardDestinationTypeEmail {
+			continue
+		}
+		var emailDest models.EmailForwardDestination
+		if err := dest.DecodeConfig(&emailDest); err != nil {
+			continue
+		}
+		if strings.EqualFold(emailDest.Email, address) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	discordMaxTitleLength       = 256
+	discordMaxDescriptionLength = 4096
+	discordMaxFieldLength       = 1024
+	discordEmbedColor           = 0x5865F2
+)
+
+// discordEscaper escapes the characters of Discord's markdown.
+var discordEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`, "#", `\#`,
+	"-", `\-`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+)
+
+// DiscordForwarder forwards SMS messages to Discord webhooks as embeds. The webhook URL of each
+// destination is read from the secrets provider.
+type DiscordForwarder struct {
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+
+	mu         sync.Mutex
+	resetTimes map[string]time.Time // When the exhausted rate limit bucket of each webhook resets
+}
+
+type discordMessage struct {
+	Embeds          []discordEmbed         `json:"embeds"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"` // Empty so that SMS content can't ping anyone
+}
+
+type discordRateLimitResponse struct {
+	RetryAfter float64 `json:"retry_after"` // Seconds to wait before retrying
+}
+
+func (f *DiscordForwarder) Name() string {
+	return models.ForwardDestinationTypeDiscord
+}
+
+func (f *DiscordForwarder) Validate(dest models.ForwardDestination) error {
+	var discordDest models.DiscordForwardDestination
+	if err := dest.DecodeConfig(&discordDest); err != nil {
+		return err
+	}
+	if discordDest.WebhookURL.IsEmpty() {
+		return errors.New("webhook_url is required")
+	}
+	return nil
+}
+
+func (f *DiscordForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var discordDest models.DiscordForwardDestination
+	if err := dest.DecodeConfig(&discordDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to Discord webhook: %s", discordDest.WebhookURL.Name)
+
+	webhookURL, err := common.GetSecretValue(ctx, f.Secrets, discordDest.WebhookURL.Name, discordDest.WebhookURL.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Discord webhook URL: %w", err)
+	}
+	if err := validateHTTPURL(webhookURL); err != nil {
+		return fmt.Errorf("invalid Discord webhook URL: %w", err)
+	}
+	payload, err := json.Marshal(formatDiscordMessage(smsRelayRequest))
+	if err != nil {
+		return err
+	}
+
+	err = retryRateLimited(ctx, "Discord", func() (time.Duration, error) {
+		// Wait for the bucket to reset if an earlier request exhausted it
+		if wait := time.Until(f.resetTime(webhookURL)); wait > 0 {
+			if wait > maxRetryAfter {
+				return 0, errors.New("Discord webhook rate limit exhausted")
+			}
+			if err := sleepContext(ctx, wait); err != nil {
+				return 0, err
+			}
+		}
+
+		resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPost, webhookURL,
+			http.Header{"Content-Type": {"application/json"}}, payload)
+		if err != nil {
+			return 0, fmt.Errorf("failed to call Discord webhook: %w", err)
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			f.setResetTime(webhookURL, time.Now().Add(parseRetryAfter(resp.Header.Get("X-RateLimit-Reset-After"))))
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return 0, nil
+		}
+		err = fmt.Errorf("Discord webhook returned status %d: %s", resp.StatusCode, truncateRunes(string(resp.Body), 200))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			var rateLimit discordRateLimitResponse
+			if json.Unmarshal(resp.Body, &rateLimit) == nil && rateLimit.RetryAfter > 0 {
+				retryAfter = time.Duration(rateLimit.RetryAfter * float64(time.Second))
+			}
+			return retryAfter, err
+		}
+		return 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Successfully forwarded SMS to Discord webhook: %s", discordDest.WebhookURL.Name)
+	return nil
+}
+
+func (f *DiscordForwarder) resetTime(webhookURL string) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resetTimes[webhookURL]
+}
+
+func (f *DiscordForwarder) setResetTime(webhookURL string, resetTime time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.resetTimes == nil {
+		f.resetTimes = make(map[string]time.Time)
+	}
+	f.resetTimes[webhookURL] = resetTime
+}
+
+// formatDiscordMessage formats the SMS of the request as an embed.
+func formatDiscordMessage(smsRelayRequest models.SMSRelayRequest) discordMessage {
+	embed := discordEmbed{
+		Title: escapeDiscordMarkdown(fmt.Sprintf("SMS Relay for %s - %s",
+			smsRelayRequest.DeviceName, smsRelayRequest.PhoneNumber.Name), discordMaxTitleLength),
+		Description: escapeDiscordMarkdown(smsRelayRequest.SMS.Body, discordMaxDescriptionLength),
+		Color:       discordEmbedColor,
+		Fields: []discordEmbedField{
+			{
+				Name: "Phone Number",
+				Value: escapeDiscordMarkdown(fmt.Sprintf("%s (%s)",
+					smsRelayRequest.PhoneNumber.Name, smsRelayRequest.PhoneNumber.PhoneNumber), discordMaxFieldLength),
+				Inline: true,
+			},
+			{
+				Name:   "From",
+				Value:  escapeDiscordMarkdown(smsRelayRequest.SMS.From, discordMaxFieldLength),
+				Inline: true,
+			},
+			{
+				Name: "Device",
+				Value: escapeDiscordMarkdown(fmt.Sprintf("%s (%s)",
+					smsRelayRequest.DeviceName, smsRelayRequest.Device.ID), discordMaxFieldLength),
+			},
+		},
+		Timestamp: smsRelayRequest.SMS.ReceivedAt,
+	}
+	return discordMessage{
+		Embeds:          []discordEmbed{embed},
+		AllowedMentions: discordAllowedMentions{Parse: []string{}},
+	}
+}
+
+// escapeDiscordMarkdown escapes s, shortening it so that the escaped text is at most n runes. The
+// text is cut between characters rather than after escaping, which could split an escape sequence
+// and leave a dangling backslash.
+func escapeDiscordMarkdown(s string, n int) string {
+	escaped := discordEscaper.Replace(s)
+	if utf8.RuneCountInString(escaped) <= n {
+		return escaped
+	}
+	var b strings.Builder
+	length := 0
+	for _, r := range s {
+		escapedRune := discordEscaper.Replace(string(r))
+		runeLength := utf8.RuneCountInString(escapedRune)
+		if length+runeLength > n-1 { // Room for the ellipsis
+			break
+		}
+		b.WriteString(escapedRune)
+		length += runeLength
+	}
+	b.WriteString("…")
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func discordDestination() models.ForwardDestination {
+	return models.ForwardDestination{
+		Type:   models.ForwardDestinationTypeDiscord,
+		Config: map[string]any{"webhook_url": map[string]any{"name": "sms-relay/destinations/discord"}},
+	}
+}
+
+func newDiscordForwarder(server *stubServer) *DiscordForwarder {
+	return &DiscordForwarder{Secrets: staticSecrets{"sms-relay/destinations/discord": server.URL + "/api/webhooks/1/token"}}
+}
+
+func TestEscapeDiscordMarkdown(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"**bold** _it_ `code`", 100, "\\*\\*bold\\*\\* \\_it\\_ \\`code\\`"},
+		{"> #1 [x](y) ~a~ |b| c-d \\", 100, `\> \#1 \[x\]\(y\) \~a\~ \|b\| c\-d \\`},
+		{"hello world", 6, "hello…"},
+		{"ab*cd", 4, "ab…"}, // "\*" doesn't fit, and isn't cut in half
+		{"ab*cd", 5, `ab\*…`},
+		{"héllo wörld", 4, "hél…"},
+	}
+	for _, tt := range tests {
+		if got := escapeDiscordMarkdown(tt.in, tt.n); got != tt.want {
+			t.Errorf("escapeDiscordMarkdown(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDiscordMessageTruncatesEscapedBody(t *testing.T) {
+	message := formatDiscordMessage(testRelayRequest("+15550199", strings.Repeat("*", discordMaxDescriptionLength)))
+	description := message.Embeds[0].Description
+	if n := utf8.RuneCountInString(description); n > discordMaxDescriptionLength {
+		t.Errorf("got a description of %d runes, want at most %d", n, discordMaxDescriptionLength)
+	}
+	if !strings.HasSuffix(description, `\*…`) {
+		t.Errorf("description %q…%q doesn't end with a whole escape sequence", description[:8], description[len(description)-8:])
+	}
+}
+
+func TestDiscordForwardEscapesSMS(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	f := newDiscordForwarder(server)
+
+	err := f.Forward(context.Background(), discordDestination(), testRelayRequest("+1-555-0199", "@everyone **Code:** 123"))
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if requests[0].Path != "/api/webhooks/1/token" {
+		t.Errorf("got path %s, want the webhook URL", requests[0].Path)
+	}
+	var sent discordMessage
+	if err := json.Unmarshal([]byte(requests[0].Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	embed := sent.Embeds[0]
+	if embed.Description != `@everyone \*\*Code:\*\* 123` {
+		t.Errorf("got description %q", embed.Description)
+	}
+	if embed.Fields[1].Value != `+1\-555\-0199` {
+		t.Errorf("got From %q", embed.Fields[1].Value)
+	}
+	if sent.AllowedMentions.Parse == nil || len(sent.AllowedMentions.Parse) != 0 {
+		t.Errorf("got allowed mentions %v, want none", sent.AllowedMentions.Parse)
+	}
+}
+
+func TestDiscordForwardRetriesRateLimited(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     string
+		minDelay time.Duration
+	}{
+		{name: "body", body: `{"retry_after":0.2}`, minDelay: time.Millisecond * 200},
+		{name: "Retry-After header", header: http.Header{"Retry-After": {"0.2"}}, body: `{}`, minDelay: time.Millisecond * 200},
+		// The body is more precise than the header, rounded up to whole seconds
+		{name: "body over header", header: http.Header{"Retry-After": {"1"}}, body: `{"retry_after":0.01}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					for name, values := range tt.header {
+						w.Header()[name] = values
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					fmt.Fprint(w, tt.body)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+			f := newDiscordForwarder(server)
+
+			start := time.Now()
+			if err := f.Forward(context.Background(), discordDestination(), testRelayRequest("+15550199", "hello")); err != nil {
+				t.Fatalf("Forward: %v", err)
+			}
+			elapsed := time.Since(start)
+			if n := len(server.Requests()); n != 2 {
+				t.Errorf("got %d requests, want 2", n)
+			}
+			if elapsed < tt.minDelay || elapsed > time.Millisecond*900 {
+				t.Errorf("retried after %s, want at least %s and less than the header's 1s", elapsed, tt.minDelay)
+			}
+		})
+	}
+}
+
+func TestDiscordForwardWaitsForExhaustedBucket(t *testing.T) {
+	resetAfter := "0.2"
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", resetAfter)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	f := newDiscordForwarder(server)
+	forward := func() error {
+		return f.Forward(context.Background(), discordDestination(), testRelayRequest("+15550199", "hello"))
+	}
+
+	if err := forward(); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	start := time.Now()
+	resetAfter = "30"
+	if err := forward(); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*200 {
+		t.Errorf("sent after %s, want to wait for the 0.2s reset", elapsed)
+	}
+
+	// Bucket resets too far away are left to the queue's retries
+	if err := forward(); err == nil {
+		t.Error("got no error with a bucket resetting after 30s")
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
@@ -0,0 +1,240 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/emailreply"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	smtpUsernameSecretName = "SMTPUsername"
+	smtpPasswordSecretName = "SMTPPassword"
+)
+
+// errSMTPAuth marks SMTP authentication failures, which may mean that the credentials were rotated.
+var errSMTPAuth = errors.New("SMTP authentication failed")
+
+// EmailForwarder forwards SMS messages by email through an SMTP server. The SMTP credentials are
+// read from the secrets provider.
+type EmailForwarder struct {
+	SMTPServer string
+	SMTPPort   string
+	UseSSL     bool
+	Secrets    common.SecretsProvider
+
+	// ReplyAddress is the base address of replies, e.g. "reply@example.com". If set, emails get a
+	// signed Reply-To address so that replies are sent back to the SMS sender, see emailreply.
+	ReplyAddress string
+}
+
+func (f *EmailForwarder) Name() string {
+	return models.ForwardDestinationTypeEmail
+}
+
+func (f *EmailForwarder) Validate(dest models.ForwardDestination) error {
+	var emailDest models.EmailForwardDestination
+	if err := dest.DecodeConfig(&emailDest); err != nil {
+		return err
+	}
+	if emailDest.IsEmpty() {
+		return errors.New("email is required")
+	}
+	if _, err := mail.ParseAddress(emailDest.Email); err != nil {
+		return fmt.Errorf("invalid email address: %w", err)
+	}
+	return nil
+}
+
+func (f *EmailForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var emailDest models.EmailForwardDestination
+	if err := dest.DecodeConfig(&emailDest); err != nil {
+		return err
+	}
+	err := f.forwardSMSByEmail(ctx, emailDest.Email, smsRelayRequest)
+	if errors.Is(err, errSMTPAuth) {
+		// Retry once with fresh credentials in case they were rotated
+		refreshedUsername := common.RefreshSecret(f.Secrets, smtpUsernameSecretName)
+		refreshedPassword := common.RefreshSecret(f.Secrets, smtpPasswordSecretName)
+		if refreshedUsername || refreshedPassword {
+			err = f.forwardSMSByEmail(ctx, emailDest.Email, smsRelayRequest)
+		}
+	}
+	return err
+}
+
+func (f *EmailForwarder) forwardSMSByEmail(ctx context.Context, toAddr string, smsRelayRequest models.SMSRelayRequest) error {
+	logger.Printf("Forwarding SMS to email: %s", toAddr)
+
+	// Fetch SMTP credentials
+	username, password, err := f.getSMTPCredentials(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch SMTP credentials: %w", err)
+	}
+
+	// Compose the email message
+	msg, err := f.composeEmail(ctx, username, toAddr, smsRelayRequest)
+	if err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+
+	if f.UseSSL {
+		// Establish a TLS connection
+		serverAddr := fmt.Sprintf("%s:%s", f.SMTPServer, f.SMTPPort)
+		conn, err := tls.Dial("tcp", serverAddr, &tls.Config{
+			InsecureSkipVerify: false,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to establish TLS connection: %w", err)
+		}
+		defer conn.Close()
+
+		// Create an SMTP client over the TLS connection
+		client, err := smtp.NewClient(conn, f.SMTPServer)
+		if err != nil {
+			return fmt.Errorf("failed to create SMTP client: %w", err)
+		}
+		defer client.Quit()
+
+		// Authenticate and send the email
+		if err := client.Auth(smtp.PlainAuth("", username, password, f.SMTPServer)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w: %w", errSMTPAuth, err)
+		}
+		if err := client.Mail(username); err != nil {
+			return fmt.Errorf("failed to set sender: %w", err)
+		}
+		if err := client.Rcpt(toAddr); err != nil {
+			return fmt.Errorf("failed to set recipient: %w", err)
+		}
+		writer, err := client.Data()
+		if err != nil {
+			return fmt.Errorf("failed to get writer: %w", err)
+		}
+		if _, err := writer.Write(msg); err != nil {
+			return fmt.Errorf("failed to write message: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to close writer: %w", err)
+		}
+	} else {
+		// Send the email without SSL
+		err = smtp.SendMail(
+			fmt.Sprintf("%s:%s", f.SMTPServer, f.SMTPPort),
+			smtp.PlainAuth("", username, password, f.SMTPServer),
+			username,
+			[]string{toAddr},
+			msg,
+		)
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code == 535 {
+			return fmt.Errorf("failed to send email: %w: %w", errSMTPAuth, err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+	}
+
+	logger.Printf("Successfully forwarded SMS to email: %s", toAddr)
+	return nil
+}
+
+// composeEmail builds the email forwarding the SMS of the request to toAddr. The Message-ID is
+// derived from the SMS ID and recipient, so that redelivered emails can be recognized.
+func (f *EmailForwarder) composeEmail(
+	ctx context.Context, fromAddr string, toAddr string, smsRelayRequest models.SMSRelayRequest,
+) ([]byte, error) {
+	subject := fmt.Sprintf("SMS Relay for %s - %s",
+		smsRelayRequest.DeviceName, smsRelayRequest.PhoneNumber.Name)
+	body := fmt.Sprintf("Device: %s (%s)\nPhone Number: %s (%s)\nFrom: %s\nMessage: %s",
+		smsRelayRequest.DeviceName, smsRelayRequest.Device.ID,
+		smsRelayRequest.PhoneNumber.Name, smsRelayRequest.PhoneNumber.PhoneNumber,
+		smsRelayRequest.SMS.From, smsRelayRequest.SMS.Body)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", fromAddr)
+	fmt.Fprintf(&msg, "To: %s\r\n", toAddr)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <sms-relay.%s.%s@%s>\r\n",
+		smsRelayRequest.SMS.ID, shortHash(toAddr), f.messageIDDomain(fromAddr))
+	if f.ReplyAddress != "" && smsRelayRequest.Alert == "" {
+		replyTo, err := f.getReplyAddress(ctx, smsRelayRequest)
+		if errors.Is(err, emailreply.ErrNotReplyable) {
+			logger.Printf("SMS %s can't be replied to by email", smsRelayRequest.SMS.ID)
+		} else if err != nil {
+			return nil, err
+		} else {
+			fmt.Fprintf(&msg, "Reply-To: %s\r\n", replyTo)
+		}
+	}
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	msg.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&msg)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+func (f *EmailForwarder) getReplyAddress(ctx context.Context, smsRelayRequest models.SMSRelayRequest) (string, error) {
+	secret, err := emailreply.GetSecret(ctx, f.Secrets)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch reply email secret: %w", err)
+	}
+	return emailreply.EncodeReplyAddress(secret, f.ReplyAddress,
+		smsRelayRequest.PhoneNumber.ID, smsRelayRequest.SMS.From)
+}
+
+// messageIDDomain returns the domain of the Message-ID header, preferring the reply address's.
+func (f *EmailForwarder) messageIDDomain(fromAddr string) string {
+	for _, addr := range []string{f.ReplyAddress, fromAddr} {
+		if at := strings.LastIndex(addr, "@"); at >= 0 && at < len(addr)-1 {
+			return addr[at+1:]
+		}
+	}
+	return f.SMTPServer
+}
+
+// shortHash returns a short hex digest of s, for making IDs unique without revealing s.
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+func (f *EmailForwarder) getSMTPCredentials(ctx context.Context) (username string, password string, err error) {
+	// Fetch SMTP username
+	username, err = common.GetSecretValue(ctx, f.Secrets, smtpUsernameSecretName, "username")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch SMTP username: %w", err)
+	}
+
+	// Fetch SMTP password
+	password, err = common.GetSecretValue(ctx, f.Secrets, smtpPasswordSecretName, "password")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch SMTP password: %w", err)
+	}
+
+	return username, password, nil
+}
@@ -0,0 +1,67 @@
+package forwarder
+
+import (
+	"errors"
+	"os"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+)
+
+// NewRegistryFromEnv returns a registry of the forwarders configured through environment variables:
+//   - SMTP_SERVER, SMTP_PORT, SSL: SMTP server used to forward SMS by email. Email forwarding is
+//     disabled if SMTP_SERVER is not set.
+//   - REPLY_EMAIL_ADDRESS: base address of replies to forwarded emails, e.g. "reply@example.com".
+//     Reply-by-email is disabled if it is not set.
+//   - TELEGRAM_API_BASE_URL: base URL of the Telegram Bot API, defaults to DefaultTelegramAPIBaseURL
+func NewRegistryFromEnv(secrets common.SecretsProvider) (*Registry, error) {
+	if os.Getenv("SMTP_SERVER") != "" && os.Getenv("SMTP_PORT") == "" {
+		return nil, errors.New("SMTP_PORT environment variable is not set")
+	}
+
+	registry := NewRegistry()
+	for _, f := range forwarderTypes(secrets) {
+		if email, ok := f.(*EmailForwarder); ok && email.SMTPServer == "" {
+			logger.Println("SMTP_SERVER is not set, email forwarding is disabled")
+			continue
+		}
+		registry.Register(f)
+	}
+	return registry, nil
+}
+
+// forwarderTypes returns a forwarder of each destination type, configured through the environment
+// variables of NewRegistryFromEnv. It is the only list of destination types: new forwarders only
+// need to be added here to be both registered and validated by ValidateDestination.
+func forwarderTypes(secrets common.SecretsProvider) []Forwarder {
+	return []Forwarder{
+		&EmailForwarder{
+			SMTPServer:   os.Getenv("SMTP_SERVER"),
+			SMTPPort:     os.Getenv("SMTP_PORT"),
+			UseSSL:       os.Getenv("SSL") == "true",
+			Secrets:      secrets,
+			ReplyAddress: os.Getenv("REPLY_EMAIL_ADDRESS"),
+		},
+		&TelegramForwarder{
+			APIBaseURL: os.Getenv("TELEGRAM_API_BASE_URL"),
+			Secrets:    secrets,
+		},
+		&WebhookForwarder{
+			Secrets: secrets,
+		},
+		&SlackForwarder{
+			Secrets: secrets,
+		},
+		&DiscordForwarder{
+			Secrets: secrets,
+		},
+		&NtfyForwarder{
+			Secrets: secrets,
+		},
+		&GotifyForwarder{
+			Secrets: secrets,
+		},
+		&MatrixForwarder{
+			Secrets: secrets,
+		},
+	}
+}
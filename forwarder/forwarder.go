@@ -0,0 +1,131 @@
+// Package forwarder forwards relayed SMS messages to the forward destinations of their phone number.
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+var logger = log.Default()
+
+// Forwarder forwards SMS messages to one type of destination, e.g. email.
+type Forwarder interface {
+	// Name returns the destination type handled by the forwarder, matching models.ForwardDestination.Type.
+	Name() string
+	// Validate checks that the destination's configuration is usable by the forwarder.
+	Validate(dest models.ForwardDestination) error
+	// Forward forwards the SMS of the request to the destination.
+	Forward(ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest) error
+}
+
+// ForwardResult is the outcome of forwarding an SMS to a single destination.
+type ForwardResult struct {
+	Index   int    // Index of the destination in the phone number's ForwardDestinations
+	Type    string // Type of the destination
+	Skipped bool   // Whether the destination was skipped because it was already forwarded to
+	Err     error  // Error forwarding to the destination, nil on success or skip
+}
+
+// Registry holds the available forwarders by destination type.
+type Registry struct {
+	forwarders map[string]Forwarder
+}
+
+func NewRegistry() *Registry {
+	return &Registry{forwarders: make(map[string]Forwarder)}
+}
+
+// Register adds a forwarder to the registry. It panics if a forwarder with the same name is
+// already registered.
+func (r *Registry) Register(f Forwarder) {
+	if _, exists := r.forwarders[f.Name()]; exists {
+		panic(fmt.Sprintf("forwarder %s registered twice", f.Name()))
+	}
+	r.forwarders[f.Name()] = f
+}
+
+// Get returns the forwarder of the given destination type, if registered.
+func (r *Registry) Get(name string) (Forwarder, bool) {
+	f, ok := r.forwarders[name]
+	return f, ok
+}
+
+// Validate checks that the destination has a registered type and a configuration usable by its
+// forwarder, and returns the forwarder.
+func (r *Registry) Validate(dest models.ForwardDestination) (Forwarder, error) {
+	forwarder, ok := r.Get(dest.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown forward destination type %q", dest.Type)
+	}
+	if err := forwarder.Validate(dest); err != nil {
+		return nil, fmt.Errorf("invalid %s destination: %w", dest.Type, err)
+	}
+	return forwarder, nil
+}
+
+// ForwardSMS fans the SMS of the request out to its phone number's forward destinations
+// concurrently, skipping the destinations whose indexes are in forwarded, and returns the outcome
+// of each destination in order.
+func (r *Registry) ForwardSMS(
+	ctx context.Context, smsRelayRequest models.SMSRelayRequest, forwarded map[int]struct{},
+) []ForwardResult {
+	destinations := smsRelayRequest.PhoneNumber.ForwardDestinations
+	results := make([]ForwardResult, len(destinations))
+
+	var wg sync.WaitGroup
+	for i, dest := range destinations {
+		results[i] = ForwardResult{Index: i, Type: dest.Type}
+		if _, ok := forwarded[i]; ok {
+			results[i].Skipped = true
+			continue
+		}
+
+		forwarder, err := r.Validate(dest)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Err = forwarder.Forward(ctx, dest, smsRelayRequest)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// JoinErrors combines the errors of the failed results, or returns nil if all succeeded.
+func JoinErrors(results []ForwardResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("destination %d (%s): %w", result.Index, result.Type, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// destinationTypes holds a forwarder of every destination type, configured or not, to validate
+// destinations with.
+var destinationTypes = sync.OnceValue(func() *Registry {
+	registry := NewRegistry()
+	for _, f := range forwarderTypes(nil) {
+		registry.Register(f)
+	}
+	return registry
+})
+
+// ValidateDestination checks that the destination has a known type and a configuration usable by
+// its forwarder, whether or not the forwarder is configured in this process.
+func ValidateDestination(dest models.ForwardDestination) error {
+	_, err := destinationTypes().Validate(dest)
+	return err
+}
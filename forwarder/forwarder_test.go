@@ -0,0 +1,83 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// staticSecrets provides the secrets of a map.
+type staticSecrets map[string]string
+
+func (s staticSecrets) GetSecret(ctx context.Context, secretName string) (string, error) {
+	value, ok := s[secretName]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return value, nil
+}
+
+// recordedRequest is a request received by a stubServer.
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// stubServer is a local stand-in for a remote service. It records the requests it receives and
+// answers them with its handler.
+type stubServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+func newStubServer(t *testing.T, handler http.HandlerFunc) *stubServer {
+	t.Helper()
+	s := &stubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+			Body:   string(body),
+		})
+		s.mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received so far.
+func (s *stubServer) Requests() []recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]recordedRequest(nil), s.requests...)
+}
+
+// testRelayRequest returns a relay request of an SMS with the given sender and body.
+func testRelayRequest(from string, body string) models.SMSRelayRequest {
+	return models.SMSRelayRequest{
+		Device:      models.Device{ID: "device-1", Name: "Pixel"},
+		DeviceName:  "Pixel",
+		PhoneNumber: models.PhoneNumber{ID: "phone-number-1", PhoneNumber: "+15550100", Name: "Personal"},
+		SMS: models.SMS{
+			ID:            "sms-1",
+			From:          from,
+			Body:          body,
+			PhoneNumberID: "phone-number-1",
+			CreatedAt:     "2026-01-02T03:04:05Z",
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// GotifyForwarder forwards SMS messages as push notifications through a Gotify server. The
+// application token of each destination is read from the secrets provider.
+type GotifyForwarder struct {
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+func (f *GotifyForwarder) Name() string {
+	return models.ForwardDestinationTypeGotify
+}
+
+func (f *GotifyForwarder) Validate(dest models.ForwardDestination) error {
+	var gotifyDest models.GotifyForwardDestination
+	if err := dest.DecodeConfig(&gotifyDest); err != nil {
+		return err
+	}
+	if gotifyDest.ServerURL == "" {
+		return errors.New("server_url is required")
+	}
+	if err := validateHTTPURL(gotifyDest.ServerURL); err != nil {
+		return err
+	}
+	if gotifyDest.AppToken.IsEmpty() {
+		return errors.New("app_token is required")
+	}
+	if gotifyDest.Priority < 0 || gotifyDest.Priority > 10 ||
+		gotifyDest.VerificationCodePriority < 0 || gotifyDest.VerificationCodePriority > 10 {
+		return errors.New("priorities must be between 1 and 10, or 0 for the default")
+	}
+	return nil
+}
+
+func (f *GotifyForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var gotifyDest models.GotifyForwardDestination
+	if err := dest.DecodeConfig(&gotifyDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to Gotify server: %s", redactURL(gotifyDest.ServerURL))
+
+	appToken, err := common.GetSecretValue(ctx, f.Secrets, gotifyDest.AppToken.Name, gotifyDest.AppToken.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Gotify app token: %w", err)
+	}
+
+	priority := gotifyDest.Priority
+	if gotifyDest.VerificationCodePriority != 0 && looksLikeVerificationCode(smsRelayRequest.SMS.Body) {
+		priority = gotifyDest.VerificationCodePriority
+	}
+	message := smsRelayRequest.SMS.Body
+	if len(gotifyDest.Tags) > 0 {
+		message += "\n\n#" + strings.Join(gotifyDest.Tags, " #")
+	}
+	payload, err := json.Marshal(gotifyMessage{
+		Title:    pushNotificationTitle(smsRelayRequest),
+		Message:  message,
+		Priority: priority,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(gotifyDest.ServerURL, "/") + "/message"
+	header := http.Header{
+		"Content-Type": {"application/json"},
+		"X-Gotify-Key": {appToken},
+	}
+	err = retryRateLimited(ctx, "Gotify", func() (time.Duration, error) {
+		resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPost, endpoint, header, payload)
+		if err != nil {
+			return 0, fmt.Errorf("failed to call Gotify server: %w", err)
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return 0, nil
+		}
+		err = fmt.Errorf("Gotify server returned status %d: %s", resp.StatusCode, truncateRunes(string(resp.Body), 200))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return parseRetryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Successfully forwarded SMS to Gotify server: %s", redactURL(gotifyDest.ServerURL))
+	return nil
+}
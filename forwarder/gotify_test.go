@@ -0,0 +1,89 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func gotifyDestination(serverURL string, config map[string]any) models.ForwardDestination {
+	dest := models.ForwardDestination{
+		Type: models.ForwardDestinationTypeGotify,
+		Config: map[string]any{
+			"server_url": serverURL,
+			"app_token":  map[string]any{"name": "sms-relay/destinations/gotify"},
+		},
+	}
+	for name, value := range config {
+		dest.Config[name] = value
+	}
+	return dest
+}
+
+func TestGotifyForward(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	f := &GotifyForwarder{Secrets: staticSecrets{"sms-relay/destinations/gotify": "AbCdEf.123"}}
+
+	dest := gotifyDestination(server.URL+"/gotify/", map[string]any{"priority": 6, "tags": []any{"sms", "work"}})
+	if err := f.Forward(context.Background(), dest, testRelayRequest("+15550199", "hello")); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if r.Method != http.MethodPost || r.Path != "/gotify/message" {
+		t.Errorf("got %s %s, want POST /gotify/message", r.Method, r.Path)
+	}
+	if got := r.Header.Get("X-Gotify-Key"); got != "AbCdEf.123" {
+		t.Errorf("got X-Gotify-Key %q, want the app token", got)
+	}
+	var sent gotifyMessage
+	if err := json.Unmarshal([]byte(r.Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	want := gotifyMessage{
+		Title:    "SMS from +15550199 to Personal (Pixel)",
+		Message:  "hello\n\n#sms #work",
+		Priority: 6,
+	}
+	if sent != want {
+		t.Errorf("got %+v, want %+v", sent, want)
+	}
+}
+
+func TestGotifyForwardRaisesVerificationCodePriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+		body   string
+		want   int
+	}{
+		{name: "code", config: map[string]any{"priority": 4, "verification_code_priority": 8}, body: "Your login code is 123456", want: 8},
+		{name: "other message", config: map[string]any{"priority": 4, "verification_code_priority": 8}, body: "See you at 7", want: 4},
+		{name: "not configured", config: map[string]any{"priority": 4}, body: "Your login code is 123456", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {})
+			f := &GotifyForwarder{Secrets: staticSecrets{"sms-relay/destinations/gotify": "AbCdEf.123"}}
+
+			err := f.Forward(context.Background(), gotifyDestination(server.URL, tt.config), testRelayRequest("+15550199", tt.body))
+			if err != nil {
+				t.Fatalf("Forward: %v", err)
+			}
+			var sent gotifyMessage
+			if err := json.Unmarshal([]byte(server.Requests()[0].Body), &sent); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			if sent.Priority != tt.want {
+				t.Errorf("got priority %d, want %d", sent.Priority, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxResponseBodySize limits how much of a response body forwarders read from remote services.
+	maxResponseBodySize = 1 << 20
+	// maxRetryAfter is the longest rate limit wait honoured in-process. Longer waits are left to the
+	// queue redelivering the message.
+	maxRetryAfter = time.Second * 5
+	// rateLimitMaxAttempts is the number of attempts to send a message when rate limited.
+	rateLimitMaxAttempts = 3
+)
+
+// defaultHTTPClient is used by forwarders calling HTTP APIs when no client is configured.
+var defaultHTTPClient = &http.Client{Timeout: time.Second * 10}
+
+// httpResponse is a response of a remote service with its body read.
+type httpResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return defaultHTTPClient
+}
+
+// sendHTTPRequest sends a request and reads up to maxResponseBodySize of the response body. Errors
+// never contain the URL, which often embeds credentials such as bot tokens or webhook keys.
+func sendHTTPRequest(
+	ctx context.Context, client *http.Client, method string, rawURL string, header http.Header, body []byte,
+) (*httpResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("failed to create request")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := httpClientOrDefault(client).Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &httpResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       respBody,
+	}, nil
+}
+
+// retryRateLimited calls send until it succeeds, retrying up to rateLimitMaxAttempts times while
+// send fails with a rate limit wait of at most maxRetryAfter.
+func retryRateLimited(ctx context.Context, service string, send func() (retryAfter time.Duration, err error)) error {
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := send()
+		if err == nil {
+			return nil
+		}
+		if retryAfter <= 0 || retryAfter > maxRetryAfter || attempt == rateLimitMaxAttempts {
+			return err
+		}
+		logger.Printf("%s rate limited, retrying in %s", service, retryAfter)
+		if err := sleepContext(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d, returning early with the context's error if it is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter parses a Retry-After style header given in (possibly fractional) seconds. It
+// returns 0 if the header is absent or invalid.
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(header), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// redactURL strips the user info, query and fragment of a URL for logging, as they may hold credentials.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// validateHTTPURL checks that rawURL is an absolute http or https URL.
+func validateHTTPURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.New("invalid URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("URL must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// truncateRunes shortens s to at most n runes, marking truncation with an ellipsis.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
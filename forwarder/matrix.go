@@ -0,0 +1,144 @@
+package forwarder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// MatrixForwarder forwards SMS messages to Matrix rooms through the client-server API. The access
+// token of each destination is read from the secrets provider.
+type MatrixForwarder struct {
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+type matrixError struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMS int64  `json:"retry_after_ms"` // Milliseconds to wait before retrying when rate limited
+}
+
+func (f *MatrixForwarder) Name() string {
+	return models.ForwardDestinationTypeMatrix
+}
+
+func (f *MatrixForwarder) Validate(dest models.ForwardDestination) error {
+	var matrixDest models.MatrixForwardDestination
+	if err := dest.DecodeConfig(&matrixDest); err != nil {
+		return err
+	}
+	if matrixDest.HomeserverURL == "" {
+		return errors.New("homeserver_url is required")
+	}
+	if err := validateHTTPURL(matrixDest.HomeserverURL); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(matrixDest.RoomID, "!") || !strings.Contains(matrixDest.RoomID, ":") {
+		return errors.New("room_id must be a room ID like !room:example.org")
+	}
+	if matrixDest.AccessToken.IsEmpty() {
+		return errors.New("access_token is required")
+	}
+	return nil
+}
+
+func (f *MatrixForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var matrixDest models.MatrixForwardDestination
+	if err := dest.DecodeConfig(&matrixDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to Matrix room: %s", matrixDest.RoomID)
+
+	accessToken, err := common.GetSecretValue(ctx, f.Secrets, matrixDest.AccessToken.Name, matrixDest.AccessToken.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Matrix access token: %w", err)
+	}
+	payload, err := json.Marshal(formatMatrixMessage(smsRelayRequest))
+	if err != nil {
+		return err
+	}
+
+	// Retrying with the same transaction ID makes the homeserver return the event it already
+	// created instead of sending the message again
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(matrixDest.HomeserverURL, "/"), url.PathEscape(matrixDest.RoomID),
+		url.PathEscape(matrixTransactionID(smsRelayRequest.SMS.ID, matrixDest.RoomID)))
+	header := http.Header{
+		"Authorization": {"Bearer " + accessToken},
+		"Content-Type":  {"application/json"},
+	}
+	err = retryRateLimited(ctx, "Matrix", func() (time.Duration, error) {
+		resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPut, endpoint, header, payload)
+		if err != nil {
+			return 0, fmt.Errorf("failed to call Matrix homeserver: %w", err)
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return 0, nil
+		}
+		var matrixErr matrixError
+		_ = json.Unmarshal(resp.Body, &matrixErr)
+		err = fmt.Errorf("Matrix homeserver returned status %d: %s %s",
+			resp.StatusCode, matrixErr.ErrCode, matrixErr.Error)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			if matrixErr.RetryAfterMS > 0 {
+				retryAfter = time.Duration(matrixErr.RetryAfterMS) * time.Millisecond
+			}
+			return retryAfter, err
+		}
+		return 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Successfully forwarded SMS to Matrix room: %s", matrixDest.RoomID)
+	return nil
+}
+
+// matrixTransactionID derives the transaction ID of an SMS sent to a room. Transaction IDs are
+// scoped to the access token rather than the room, so the room is part of the ID to keep
+// destinations sharing a token from deduplicating each other.
+func matrixTransactionID(smsID string, roomID string) string {
+	roomHash := sha256.Sum256([]byte(roomID))
+	return "sms-relay-" + smsID + "-" + hex.EncodeToString(roomHash[:8])
+}
+
+// formatMatrixMessage formats the SMS of the request as a text message with an HTML body.
+func formatMatrixMessage(smsRelayRequest models.SMSRelayRequest) matrixMessage {
+	title := fmt.Sprintf("SMS Relay for %s - %s", smsRelayRequest.DeviceName, smsRelayRequest.PhoneNumber.Name)
+	body := fmt.Sprintf("%s\nPhone Number: %s\nFrom: %s\n\n%s",
+		title, smsRelayRequest.PhoneNumber.PhoneNumber, smsRelayRequest.SMS.From, smsRelayRequest.SMS.Body)
+	formattedBody := fmt.Sprintf("<strong>%s</strong><br>\n<strong>Phone Number:</strong> %s<br>\n"+
+		"<strong>From:</strong> %s<br>\n<p>%s</p>",
+		html.EscapeString(title), html.EscapeString(smsRelayRequest.PhoneNumber.PhoneNumber),
+		html.EscapeString(smsRelayRequest.SMS.From),
+		strings.ReplaceAll(html.EscapeString(smsRelayRequest.SMS.Body), "\n", "<br>\n"))
+	return matrixMessage{
+		MsgType:       "m.text",
+		Body:          body,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formattedBody,
+	}
+}
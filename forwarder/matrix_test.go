@@ -0,0 +1,119 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func matrixDestination(homeserverURL string, roomID string) models.ForwardDestination {
+	return models.ForwardDestination{
+		Type: models.ForwardDestinationTypeMatrix,
+		Config: map[string]any{
+			"homeserver_url": homeserverURL,
+			"room_id":        roomID,
+			"access_token":   map[string]any{"name": "sms-relay/destinations/matrix"},
+		},
+	}
+}
+
+func TestMatrixForwardTransactionIDs(t *testing.T) {
+	calls := 0
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":10}`)
+			return
+		}
+		fmt.Fprintf(w, `{"event_id":"$event-%d"}`, calls)
+	})
+	f := &MatrixForwarder{Secrets: staticSecrets{"sms-relay/destinations/matrix": "syt_token"}}
+	forward := func(roomID string, smsID string) {
+		t.Helper()
+		request := testRelayRequest("+15550199", "hello")
+		request.SMS.ID = smsID
+		if err := f.Forward(context.Background(), matrixDestination(server.URL, roomID), request); err != nil {
+			t.Fatalf("Forward: %v", err)
+		}
+	}
+
+	forward("!room-a:example.org", "sms-1") // Rate limited, then retried
+	forward("!room-a:example.org", "sms-1") // Redelivered by the queue
+	forward("!room-b:example.org", "sms-1")
+	forward("!room-a:example.org", "sms-2")
+
+	requests := server.Requests()
+	if len(requests) != 5 {
+		t.Fatalf("got %d requests, want 5", len(requests))
+	}
+	prefix := "/_matrix/client/v3/rooms/!room-a:example.org/send/m.room.message/"
+	for i, r := range requests {
+		if r.Method != http.MethodPut {
+			t.Errorf("request %d: got method %s, want PUT", i, r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer syt_token" {
+			t.Errorf("request %d: got Authorization %q", i, got)
+		}
+	}
+	if !strings.HasPrefix(requests[0].Path, prefix) {
+		t.Fatalf("got path %s, want prefix %s", requests[0].Path, prefix)
+	}
+	for _, i := range []int{1, 2} {
+		if requests[i].Path != requests[0].Path {
+			t.Errorf("request %d: got path %s, want the same transaction as %s", i, requests[i].Path, requests[0].Path)
+		}
+	}
+
+	roomB := requests[3].Path
+	if !strings.HasPrefix(roomB, "/_matrix/client/v3/rooms/!room-b:example.org/send/m.room.message/") {
+		t.Errorf("got path %s for room B", roomB)
+	}
+	txnID := func(path string) string { return path[strings.LastIndex(path, "/")+1:] }
+	if txnID(roomB) == txnID(requests[0].Path) {
+		t.Errorf("got transaction %s in both rooms, want different ones", txnID(roomB))
+	}
+	if requests[4].Path == requests[0].Path {
+		t.Errorf("got the same transaction %s for different SMS", txnID(requests[4].Path))
+	}
+}
+
+func TestMatrixForwardEscapesHTML(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"event_id":"$event"}`)
+	})
+	f := &MatrixForwarder{Secrets: staticSecrets{"sms-relay/destinations/matrix": "syt_token"}}
+
+	request := testRelayRequest("<Bank & Co>", "<a href=\"https://evil\">Log in</a>\nnow")
+	if err := f.Forward(context.Background(), matrixDestination(server.URL+"/", "!room-a:example.org"), request); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	var sent matrixMessage
+	if err := json.Unmarshal([]byte(server.Requests()[0].Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	if sent.MsgType != "m.text" || sent.Format != "org.matrix.custom.html" {
+		t.Errorf("got msgtype %s, format %s", sent.MsgType, sent.Format)
+	}
+	for _, want := range []string{
+		"<strong>From:</strong> &lt;Bank &amp; Co&gt;<br>",
+		"<p>&lt;a href=&#34;https://evil&#34;&gt;Log in&lt;/a&gt;<br>\nnow</p>",
+	} {
+		if !strings.Contains(sent.FormattedBody, want) {
+			t.Errorf("formatted body %q doesn't contain %q", sent.FormattedBody, want)
+		}
+	}
+	if strings.Contains(sent.FormattedBody, "<a ") {
+		t.Errorf("formatted body %q contains the SMS's link", sent.FormattedBody)
+	}
+	// The plain body is left as is
+	if !strings.HasSuffix(sent.Body, "From: <Bank & Co>\n\n<a href=\"https://evil\">Log in</a>\nnow") {
+		t.Errorf("got body %q", sent.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// NtfyForwarder forwards SMS messages as push notifications through an ntfy server. The topic and
+// access token of each destination are read from the secrets provider.
+type NtfyForwarder struct {
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+}
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+func (f *NtfyForwarder) Name() string {
+	return models.ForwardDestinationTypeNtfy
+}
+
+func (f *NtfyForwarder) Validate(dest models.ForwardDestination) error {
+	var ntfyDest models.NtfyForwardDestination
+	if err := dest.DecodeConfig(&ntfyDest); err != nil {
+		return err
+	}
+	if ntfyDest.ServerURL == "" {
+		return errors.New("server_url is required")
+	}
+	if err := validateHTTPURL(ntfyDest.ServerURL); err != nil {
+		return err
+	}
+	if ntfyDest.Topic.IsEmpty() {
+		return errors.New("topic is required")
+	}
+	if ntfyDest.Priority < 0 || ntfyDest.Priority > 5 ||
+		ntfyDest.VerificationCodePriority < 0 || ntfyDest.VerificationCodePriority > 5 {
+		return errors.New("priorities must be between 1 and 5, or 0 for the default")
+	}
+	return nil
+}
+
+func (f *NtfyForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var ntfyDest models.NtfyForwardDestination
+	if err := dest.DecodeConfig(&ntfyDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to ntfy server: %s", redactURL(ntfyDest.ServerURL))
+
+	topic, err := common.GetSecretValue(ctx, f.Secrets, ntfyDest.Topic.Name, ntfyDest.Topic.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ntfy topic: %w", err)
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	if !ntfyDest.AccessToken.IsEmpty() {
+		accessToken, err := common.GetSecretValue(ctx, f.Secrets, ntfyDest.AccessToken.Name, ntfyDest.AccessToken.Key)
+		if err != nil {
+			return fmt.Errorf("failed to fetch ntfy access token: %w", err)
+		}
+		header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	priority := ntfyDest.Priority
+	if ntfyDest.VerificationCodePriority != 0 && looksLikeVerificationCode(smsRelayRequest.SMS.Body) {
+		priority = ntfyDest.VerificationCodePriority
+	}
+	payload, err := json.Marshal(ntfyMessage{
+		Topic:    topic,
+		Title:    pushNotificationTitle(smsRelayRequest),
+		Message:  smsRelayRequest.SMS.Body,
+		Priority: priority,
+		Tags:     ntfyDest.Tags,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Messages are published as JSON to the root URL of the server
+	serverURL := strings.TrimSuffix(ntfyDest.ServerURL, "/") + "/"
+	err = retryRateLimited(ctx, "ntfy", func() (time.Duration, error) {
+		resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPost, serverURL, header, payload)
+		if err != nil {
+			return 0, fmt.Errorf("failed to call ntfy server: %w", err)
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return 0, nil
+		}
+		err = fmt.Errorf("ntfy server returned status %d: %s", resp.StatusCode, truncateRunes(string(resp.Body), 200))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return parseRetryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Successfully forwarded SMS to ntfy server: %s", redactURL(ntfyDest.ServerURL))
+	return nil
+}
+
+// pushNotificationTitle returns the title of push notifications of the request.
+func pushNotificationTitle(smsRelayRequest models.SMSRelayRequest) string {
+	return fmt.Sprintf("SMS from %s to %s (%s)",
+		smsRelayRequest.SMS.From, smsRelayRequest.PhoneNumber.Name, smsRelayRequest.DeviceName)
+}
@@ -0,0 +1,124 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func ntfyDestination(serverURL string, config map[string]any) models.ForwardDestination {
+	dest := models.ForwardDestination{
+		Type: models.ForwardDestinationTypeNtfy,
+		Config: map[string]any{
+			"server_url": serverURL,
+			"topic":      map[string]any{"name": "sms-relay/destinations/ntfy", "key": "topic"},
+		},
+	}
+	for name, value := range config {
+		dest.Config[name] = value
+	}
+	return dest
+}
+
+func TestNtfyForward(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	f := &NtfyForwarder{Secrets: staticSecrets{
+		"sms-relay/destinations/ntfy":       `{"topic":"sms-a1b2c3"}`,
+		"sms-relay/destinations/ntfy-token": "tk_secret",
+	}}
+
+	dest := ntfyDestination(server.URL+"/", map[string]any{
+		"access_token": map[string]any{"name": "sms-relay/destinations/ntfy-token"},
+		"priority":     3,
+		"tags":         []any{"sms", "iphone"},
+	})
+	if err := f.Forward(context.Background(), dest, testRelayRequest("+15550199", "hello")); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if r.Method != http.MethodPost || r.Path != "/" {
+		t.Errorf("got %s %s, want POST /", r.Method, r.Path)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer tk_secret" {
+		t.Errorf("got Authorization %q, want the access token", got)
+	}
+	var sent ntfyMessage
+	if err := json.Unmarshal([]byte(r.Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	want := ntfyMessage{
+		Topic:    "sms-a1b2c3",
+		Title:    "SMS from +15550199 to Personal (Pixel)",
+		Message:  "hello",
+		Priority: 3,
+		Tags:     []string{"sms", "iphone"},
+	}
+	if sent.Topic != want.Topic || sent.Title != want.Title || sent.Message != want.Message ||
+		sent.Priority != want.Priority || !slices.Equal(sent.Tags, want.Tags) {
+		t.Errorf("got %+v, want %+v", sent, want)
+	}
+}
+
+func TestNtfyForwardWithoutAccessToken(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	f := &NtfyForwarder{Secrets: staticSecrets{"sms-relay/destinations/ntfy": `{"topic":"sms-a1b2c3"}`}}
+
+	if err := f.Forward(context.Background(), ntfyDestination(server.URL, nil), testRelayRequest("+15550199", "hello")); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	r := server.Requests()[0]
+	if got := r.Header.Get("Authorization"); got != "" {
+		t.Errorf("got Authorization %q, want none", got)
+	}
+	// Unset priorities and tags are left to the server
+	var sent map[string]any
+	if err := json.Unmarshal([]byte(r.Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	for _, field := range []string{"priority", "tags"} {
+		if _, ok := sent[field]; ok {
+			t.Errorf("got %s %v, want it omitted", field, sent[field])
+		}
+	}
+}
+
+func TestNtfyForwardRaisesVerificationCodePriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+		body   string
+		want   int
+	}{
+		{name: "code", config: map[string]any{"priority": 3, "verification_code_priority": 5}, body: "Your login code is 123456", want: 5},
+		{name: "other message", config: map[string]any{"priority": 3, "verification_code_priority": 5}, body: "See you at 7", want: 3},
+		{name: "not configured", config: map[string]any{"priority": 2}, body: "Your login code is 123456", want: 2},
+		{name: "default priority", config: map[string]any{"verification_code_priority": 4}, body: "Your login code is 123456", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {})
+			f := &NtfyForwarder{Secrets: staticSecrets{"sms-relay/destinations/ntfy": `{"topic":"sms-a1b2c3"}`}}
+
+			err := f.Forward(context.Background(), ntfyDestination(server.URL, tt.config), testRelayRequest("+15550199", tt.body))
+			if err != nil {
+				t.Fatalf("Forward: %v", err)
+			}
+			var sent ntfyMessage
+			if err := json.Unmarshal([]byte(server.Requests()[0].Body), &sent); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			if sent.Priority != tt.want {
+				t.Errorf("got priority %d, want %d", sent.Priority, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	idempotencyRecordTTL = time.Hour * 24 // 1 day
+)
+
+// Processor forwards queued SMS relay requests, remembering which destinations each message has
+// been forwarded to so that redelivered messages are not forwarded twice.
+type Processor struct {
+	Registry *Registry
+	Records  store.IdempotencyRepository
+}
+
+// ProcessMessage forwards the SMS relay request in the body of a queued message. It returns an
+// error only if the message should be retried.
+func (p *Processor) ProcessMessage(ctx context.Context, messageID string, body string) error {
+	var smsRelayRequest models.SMSRelayRequest
+	if err := json.Unmarshal([]byte(body), &smsRelayRequest); err != nil {
+		// A malformed message will never succeed, so drop it instead of retrying it
+		logger.Printf("dropping malformed message %s: %v", messageID, err)
+		return nil
+	}
+
+	logger.Printf("Processing SMS Relay Request (Message ID: %s, Device ID: %s, Phone Number: %s)",
+		messageID, smsRelayRequest.Device.ID, smsRelayRequest.PhoneNumber.PhoneNumber)
+
+	// Skip the destinations a previous delivery of the message already forwarded to
+	forwarded, err := p.getForwardedDestinations(ctx, messageID)
+	if err != nil {
+		logger.Printf("failed to get forwarded destinations of message %s: %v", messageID, err)
+		return err
+	}
+
+	// Forward SMS to all remaining destinations and report each outcome
+	results := p.Registry.ForwardSMS(ctx, smsRelayRequest, forwarded)
+	var succeeded []int
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			logger.Printf("skipped SMS %s to destination %d (%s): already forwarded",
+				smsRelayRequest.SMS.ID, result.Index, result.Type)
+		case result.Err != nil:
+			logger.Printf("failed to forward SMS %s to destination %d (%s): %v",
+				smsRelayRequest.SMS.ID, result.Index, result.Type, result.Err)
+		default:
+			logger.Printf("forwarded SMS %s to destination %d (%s)",
+				smsRelayRequest.SMS.ID, result.Index, result.Type)
+			succeeded = append(succeeded, result.Index)
+		}
+	}
+	if err := p.markDestinationsForwarded(ctx, messageID, succeeded); err != nil {
+		// Not fatal, a redelivery would at worst forward to these destinations again
+		logger.Printf("failed to mark destinations of message %s as forwarded: %v", messageID, err)
+	}
+	return JoinErrors(results)
+}
+
+func messageIdempotencyRecordID(messageID string) string {
+	return "sqs#" + messageID
+}
+
+// getForwardedDestinations returns the indexes of the forward destinations the message has
+// already been forwarded to by a previous delivery.
+func (p *Processor) getForwardedDestinations(ctx context.Context, messageID string) (map[int]struct{}, error) {
+	record, err := p.Records.GetIdempotencyRecord(ctx, messageIdempotencyRecordID(messageID))
+	if err != nil {
+		return nil, err
+	}
+
+	forwarded := make(map[int]struct{})
+	if record == nil {
+		return forwarded, nil // Message not seen before
+	}
+	for _, index := range record.ForwardedDestinations {
+		forwarded[index] = struct{}{}
+	}
+	return forwarded, nil
+}
+
+// markDestinationsForwarded records that the message has been forwarded to the destinations with
+// the given indexes.
+func (p *Processor) markDestinationsForwarded(ctx context.Context, messageID string, indexes []int) error {
+	expiresAt := time.Now().Add(idempotencyRecordTTL).Unix()
+	return p.Records.AddForwardedDestinations(ctx, messageIdempotencyRecordID(messageID), indexes, expiresAt)
+}
@@ -0,0 +1,139 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	slackMaxHeaderLength = 150  // Limit of plain_text in header blocks
+	slackMaxTextLength   = 3000 // Limit of text in section blocks
+)
+
+// slackEscaper escapes the control characters of Slack's mrkdwn text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackForwarder forwards SMS messages to Slack incoming webhooks as Block Kit messages. The webhook
+// URL of each destination is read from the secrets provider.
+type SlackForwarder struct {
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"` // Fallback for notifications
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"` // "plain_text" or "mrkdwn"
+	Text string `json:"text"`
+}
+
+func (f *SlackForwarder) Name() string {
+	return models.ForwardDestinationTypeSlack
+}
+
+func (f *SlackForwarder) Validate(dest models.ForwardDestination) error {
+	var slackDest models.SlackForwardDestination
+	if err := dest.DecodeConfig(&slackDest); err != nil {
+		return err
+	}
+	if slackDest.WebhookURL.IsEmpty() {
+		return errors.New("webhook_url is required")
+	}
+	return nil
+}
+
+func (f *SlackForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var slackDest models.SlackForwardDestination
+	if err := dest.DecodeConfig(&slackDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to Slack webhook: %s", slackDest.WebhookURL.Name)
+
+	webhookURL, err := common.GetSecretValue(ctx, f.Secrets, slackDest.WebhookURL.Name, slackDest.WebhookURL.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Slack webhook URL: %w", err)
+	}
+	if err := validateHTTPURL(webhookURL); err != nil {
+		return fmt.Errorf("invalid Slack webhook URL: %w", err)
+	}
+	payload, err := json.Marshal(formatSlackMessage(smsRelayRequest))
+	if err != nil {
+		return err
+	}
+
+	err = retryRateLimited(ctx, "Slack", func() (time.Duration, error) {
+		resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPost, webhookURL,
+			http.Header{"Content-Type": {"application/json"}}, payload)
+		if err != nil {
+			return 0, fmt.Errorf("failed to call Slack webhook: %w", err)
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return 0, nil
+		}
+		err = fmt.Errorf("Slack webhook returned status %d: %s", resp.StatusCode, truncateRunes(string(resp.Body), 200))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return parseRetryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Successfully forwarded SMS to Slack webhook: %s", slackDest.WebhookURL.Name)
+	return nil
+}
+
+// formatSlackMessage formats the SMS of the request as a Block Kit message. The SMS body is sent as
+// plain text, so that it is never interpreted as formatting or mentions.
+func formatSlackMessage(smsRelayRequest models.SMSRelayRequest) slackMessage {
+	title := fmt.Sprintf("SMS Relay for %s - %s", smsRelayRequest.DeviceName, smsRelayRequest.PhoneNumber.Name)
+	return slackMessage{
+		Text: slackEscaper.Replace(fmt.Sprintf("%s: new SMS from %s", title, smsRelayRequest.SMS.From)),
+		Blocks: []slackBlock{
+			{
+				Type: "header",
+				Text: &slackText{Type: "plain_text", Text: truncateRunes(title, slackMaxHeaderLength)},
+			},
+			{
+				Type: "section",
+				Fields: []slackText{
+					{Type: "mrkdwn", Text: "*Phone Number:*\n" + slackEscaper.Replace(fmt.Sprintf("%s (%s)",
+						smsRelayRequest.PhoneNumber.Name, smsRelayRequest.PhoneNumber.PhoneNumber))},
+					{Type: "mrkdwn", Text: "*From:*\n" + slackEscaper.Replace(smsRelayRequest.SMS.From)},
+				},
+			},
+			{
+				Type: "section",
+				Text: &slackText{Type: "plain_text", Text: truncateRunes(smsRelayRequest.SMS.Body, slackMaxTextLength)},
+			},
+			{
+				Type: "context",
+				Elements: []slackText{
+					{Type: "mrkdwn", Text: "Device: " + slackEscaper.Replace(fmt.Sprintf("%s (%s)",
+						smsRelayRequest.DeviceName, smsRelayRequest.Device.ID))},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func slackDestination() models.ForwardDestination {
+	return models.ForwardDestination{
+		Type:   models.ForwardDestinationTypeSlack,
+		Config: map[string]any{"webhook_url": map[string]any{"name": "sms-relay/destinations/slack"}},
+	}
+}
+
+func newSlackForwarder(server *stubServer) *SlackForwarder {
+	return &SlackForwarder{Secrets: staticSecrets{"sms-relay/destinations/slack": server.URL + "/services/T0/B0/x"}}
+}
+
+func TestSlackForwardEscapesSMS(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	f := newSlackForwarder(server)
+
+	err := f.Forward(context.Background(), slackDestination(), testRelayRequest("<!channel> & co", "*Code:* <https://evil|bank>"))
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	var sent slackMessage
+	if err := json.Unmarshal([]byte(requests[0].Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	if want := "SMS Relay for Pixel - Personal: new SMS from &lt;!channel&gt; &amp; co"; sent.Text != want {
+		t.Errorf("got fallback text %q, want %q", sent.Text, want)
+	}
+	if from := sent.Blocks[1].Fields[1]; from.Text != "*From:*\n&lt;!channel&gt; &amp; co" {
+		t.Errorf("got From %q", from.Text)
+	}
+	// The body is plain text, which Slack doesn't format
+	if body := sent.Blocks[2].Text; body.Type != "plain_text" || body.Text != "*Code:* <https://evil|bank>" {
+		t.Errorf("got body %s %q, want it unchanged as plain_text", body.Type, body.Text)
+	}
+}
+
+func TestSlackForwardRetriesRateLimited(t *testing.T) {
+	calls := 0
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	})
+	f := newSlackForwarder(server)
+
+	start := time.Now()
+	if err := f.Forward(context.Background(), slackDestination(), testRelayRequest("+15550199", "hello")); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Retry-After", elapsed)
+	}
+}
+
+func TestSlackForwardLeavesLongRateLimitsToTheQueue(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	f := newSlackForwarder(server)
+
+	if err := f.Forward(context.Background(), slackDestination(), testRelayRequest("+15550199", "hello")); err == nil {
+		t.Error("got no error for a rate limit longer than maxRetryAfter")
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
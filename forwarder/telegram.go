@@ -0,0 +1,147 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	DefaultTelegramAPIBaseURL = "https://api.telegram.org"
+
+	// telegramMaxBodyLength keeps messages within the 4096 character limit of the Bot API.
+	telegramMaxBodyLength = 3500
+)
+
+// telegramMarkdownV2Escaper escapes the characters reserved by the MarkdownV2 parse mode.
+var telegramMarkdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`,
+	"`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`,
+	"}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// TelegramForwarder forwards SMS messages to Telegram chats through the Bot API. The bot token of
+// each destination is read from the secrets provider.
+type TelegramForwarder struct {
+	APIBaseURL string // Base URL of the Bot API, defaults to DefaultTelegramAPIBaseURL
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+}
+
+type telegramSendMessageRequest struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"` // Seconds to wait before retrying when rate limited
+	} `json:"parameters"`
+}
+
+func (f *TelegramForwarder) Name() string {
+	return models.ForwardDestinationTypeTelegram
+}
+
+func (f *TelegramForwarder) Validate(dest models.ForwardDestination) error {
+	var telegramDest models.TelegramForwardDestination
+	if err := dest.DecodeConfig(&telegramDest); err != nil {
+		return err
+	}
+	if telegramDest.ChatID == "" {
+		return errors.New("chat_id is required")
+	}
+	if telegramDest.BotToken.IsEmpty() {
+		return errors.New("bot_token is required")
+	}
+	return nil
+}
+
+func (f *TelegramForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var telegramDest models.TelegramForwardDestination
+	if err := dest.DecodeConfig(&telegramDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to Telegram chat: %s", telegramDest.ChatID)
+
+	botToken, err := common.GetSecretValue(ctx, f.Secrets, telegramDest.BotToken.Name, telegramDest.BotToken.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Telegram bot token: %w", err)
+	}
+	payload, err := json.Marshal(telegramSendMessageRequest{
+		ChatID:    telegramDest.ChatID,
+		Text:      formatTelegramMessage(smsRelayRequest),
+		ParseMode: "MarkdownV2",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = retryRateLimited(ctx, "Telegram", func() (time.Duration, error) {
+		return f.sendMessage(ctx, botToken, payload)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Successfully forwarded SMS to Telegram chat: %s", telegramDest.ChatID)
+	return nil
+}
+
+// sendMessage calls the sendMessage method of the Bot API. If the bot is rate limited, it returns
+// the time to wait before retrying along with the error.
+func (f *TelegramForwarder) sendMessage(ctx context.Context, botToken string, payload []byte) (time.Duration, error) {
+	baseURL := f.APIBaseURL
+	if baseURL == "" {
+		baseURL = DefaultTelegramAPIBaseURL
+	}
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/bot" + botToken + "/sendMessage"
+
+	resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPost, endpoint,
+		http.Header{"Content-Type": {"application/json"}}, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call Telegram Bot API: %w", err)
+	}
+
+	var result telegramResponse
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return 0, fmt.Errorf("failed to decode Telegram response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.OK {
+		return 0, nil
+	}
+	err = fmt.Errorf("Telegram Bot API returned error %d: %s", result.ErrorCode, result.Description)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return time.Duration(result.Parameters.RetryAfter) * time.Second, err
+	}
+	return 0, err
+}
+
+// formatTelegramMessage formats the SMS of the request as a MarkdownV2 message.
+func formatTelegramMessage(smsRelayRequest models.SMSRelayRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*SMS Relay for %s \\- %s*\n",
+		escapeTelegramMarkdownV2(smsRelayRequest.DeviceName),
+		escapeTelegramMarkdownV2(smsRelayRequest.PhoneNumber.Name))
+	fmt.Fprintf(&b, "*Phone Number:* %s\n", escapeTelegramMarkdownV2(smsRelayRequest.PhoneNumber.PhoneNumber))
+	fmt.Fprintf(&b, "*From:* %s\n\n", escapeTelegramMarkdownV2(smsRelayRequest.SMS.From))
+	b.WriteString(escapeTelegramMarkdownV2(truncateRunes(smsRelayRequest.SMS.Body, telegramMaxBodyLength)))
+	return b.String()
+}
+
+func escapeTelegramMarkdownV2(s string) string {
+	return telegramMarkdownV2Escaper.Replace(s)
+}
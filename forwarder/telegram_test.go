@@ -0,0 +1,163 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func telegramDestination() models.ForwardDestination {
+	return models.ForwardDestination{
+		Type: models.ForwardDestinationTypeTelegram,
+		Config: map[string]any{
+			"chat_id":   "-100123",
+			"bot_token": map[string]any{"name": "sms-relay/destinations/telegram"},
+		},
+	}
+}
+
+func newTelegramForwarder(server *stubServer) *TelegramForwarder {
+	return &TelegramForwarder{
+		APIBaseURL: server.URL,
+		Secrets:    staticSecrets{"sms-relay/destinations/telegram": "123:token"},
+	}
+}
+
+func TestEscapeTelegramMarkdownV2(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hello", "hello"},
+		{"+15550100", `\+15550100`},
+		{"Your code is 123-456.", `Your code is 123\-456\.`},
+		{"*bold* _italic_ [link](http://x)", `\*bold\* \_italic\_ \[link\]\(http://x\)`},
+		{"a\\b", `a\\b`},
+		{"~`>#=|{}!", "\\~\\`\\>\\#\\=\\|\\{\\}\\!"},
+	}
+	for _, tt := range tests {
+		if got := escapeTelegramMarkdownV2(tt.in); got != tt.want {
+			t.Errorf("escapeTelegramMarkdownV2(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTelegramForwardEscapesSMS(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+	f := newTelegramForwarder(server)
+
+	err := f.Forward(context.Background(), telegramDestination(), testRelayRequest("+1 (555) 0199", "Code: 1234. Don't share_it!"))
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if requests[0].Path != "/bot123:token/sendMessage" {
+		t.Errorf("got path %s, want /bot123:token/sendMessage", requests[0].Path)
+	}
+	var sent telegramSendMessageRequest
+	if err := json.Unmarshal([]byte(requests[0].Body), &sent); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if sent.ChatID != "-100123" || sent.ParseMode != "MarkdownV2" {
+		t.Errorf("got chat %s, parse mode %s", sent.ChatID, sent.ParseMode)
+	}
+	for _, want := range []string{`*From:* \+1 \(555\) 0199`, `Code: 1234\. Don't share\_it\!`} {
+		if !strings.Contains(sent.Text, want) {
+			t.Errorf("message %q doesn't contain %q", sent.Text, want)
+		}
+	}
+}
+
+// telegramRateLimited answers the first requests, as many as times, with a 429 asking to retry
+// after retryAfter seconds, and the others with success.
+func telegramRateLimited(times int, retryAfter int) http.HandlerFunc {
+	calls := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= times {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, `{"ok":false,"error_code":429,"description":"Too Many Requests",`+
+				`"parameters":{"retry_after":%d}}`, retryAfter)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}
+}
+
+func TestTelegramForwardRetriesRateLimited(t *testing.T) {
+	server := newStubServer(t, telegramRateLimited(1, 1))
+	f := newTelegramForwarder(server)
+
+	start := time.Now()
+	if err := f.Forward(context.Background(), telegramDestination(), testRelayRequest("+15550199", "hello")); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s retry_after", elapsed)
+	}
+}
+
+func TestTelegramForwardLeavesLongRateLimitsToTheQueue(t *testing.T) {
+	retryAfter := int((maxRetryAfter + time.Second) / time.Second)
+	server := newStubServer(t, telegramRateLimited(1, retryAfter))
+	f := newTelegramForwarder(server)
+
+	err := f.Forward(context.Background(), telegramDestination(), testRelayRequest("+15550199", "hello"))
+	if err == nil {
+		t.Fatal("got no error for a rate limit longer than maxRetryAfter")
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	errRateLimited := errors.New("rate limited")
+	tests := []struct {
+		name         string
+		retryAfter   time.Duration // Wait asked by every failed attempt
+		failures     int           // Number of failed attempts before success
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "success", failures: 0, wantAttempts: 1},
+		{name: "retried", retryAfter: time.Millisecond, failures: 2, wantAttempts: 3},
+		{name: "too many attempts", retryAfter: time.Millisecond, failures: rateLimitMaxAttempts, wantErr: true, wantAttempts: rateLimitMaxAttempts},
+		{name: "not rate limited", retryAfter: 0, failures: 1, wantErr: true, wantAttempts: 1},
+		{name: "wait above the cap", retryAfter: maxRetryAfter + time.Millisecond, failures: 1, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryRateLimited(context.Background(), "test", func() (time.Duration, error) {
+				attempts++
+				if attempts <= tt.failures {
+					return tt.retryAfter, errRateLimited
+				}
+				return 0, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
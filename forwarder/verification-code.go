@@ -0,0 +1,18 @@
+package forwarder
+
+import "regexp"
+
+var (
+	// verificationCodeKeywordPattern matches the wording of common verification code messages.
+	verificationCodeKeywordPattern = regexp.MustCompile(
+		`(?i)(verification|verify|security|login|sign[- ]?in|confirmation|one[- ]time|auth\w*|access)\s*code|` +
+			`\b(otp|passcode|pin|2fa|mfa)\b|验证码|校验码|動態密碼|認証コード|確認コード`)
+	// verificationCodePattern matches codes of 4 to 8 digits, optionally split in two halves.
+	verificationCodePattern = regexp.MustCompile(`(^|[^\d])(\d{4,8}|\d{3}[- ]\d{3})([^\d]|$)`)
+)
+
+// looksLikeVerificationCode reports whether an SMS body looks like a one-time verification code,
+// so that forwarders can deliver it with a higher priority.
+func looksLikeVerificationCode(body string) bool {
+	return verificationCodeKeywordPattern.MatchString(body) && verificationCodePattern.MatchString(body)
+}
@@ -0,0 +1,29 @@
+package forwarder
+
+import "testing"
+
+func TestLooksLikeVerificationCode(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"Your verification code is 123456.", true},
+		{"Your login code: 4821", true},
+		{"G-482913 is your Google verification code.", true},
+		{"Use 123 456 as your sign-in code", true},
+		{"Your OTP is 99887766, valid for 5 minutes", true},
+		{"【Bank】验证码：583920，5分钟内有效", true},
+		{"Your 2FA code: 000111", true},
+		{"Your verification code is on its way", false},                  // No code
+		{"Your order 123456 has shipped", false},                         // No keyword
+		{"Your access code is 123", false},                               // Too short
+		{"Your security code is 1234567890", false},                      // Too long, e.g. a phone number
+		{"Lunch at 1230? Pinball after", false},                          // "pin" only as a whole word
+		{"Call +15550100 about the verification of your account", false}, // No "code"
+	}
+	for _, tt := range tests {
+		if got := looksLikeVerificationCode(tt.body); got != tt.want {
+			t.Errorf("looksLikeVerificationCode(%q) = %t, want %t", tt.body, got, tt.want)
+		}
+	}
+}
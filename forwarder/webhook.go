@@ -0,0 +1,125 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	webhookMaxAttempts    = 3
+	webhookInitialBackoff = time.Millisecond * 500
+	webhookUserAgent      = "sms-relay-server-webhook/1"
+)
+
+// errWebhookRetryable marks webhook failures worth retrying, i.e. server errors and network errors.
+var errWebhookRetryable = errors.New("retryable webhook failure")
+
+// WebhookForwarder forwards SMS messages to HTTP webhooks as a models.WebhookPayload, signed with
+// the destination's secret as described by common.SignWebhook.
+type WebhookForwarder struct {
+	Secrets    common.SecretsProvider
+	HTTPClient *http.Client // Defaults to an HTTP client with a 10 second timeout
+}
+
+func (f *WebhookForwarder) Name() string {
+	return models.ForwardDestinationTypeWebhook
+}
+
+func (f *WebhookForwarder) Validate(dest models.ForwardDestination) error {
+	var webhookDest models.WebhookForwardDestination
+	if err := dest.DecodeConfig(&webhookDest); err != nil {
+		return err
+	}
+	if webhookDest.URL == "" {
+		return errors.New("url is required")
+	}
+	if err := validateHTTPURL(webhookDest.URL); err != nil {
+		return err
+	}
+	if webhookDest.Secret.IsEmpty() {
+		return errors.New("secret is required")
+	}
+	return nil
+}
+
+func (f *WebhookForwarder) Forward(
+	ctx context.Context, dest models.ForwardDestination, smsRelayRequest models.SMSRelayRequest,
+) error {
+	var webhookDest models.WebhookForwardDestination
+	if err := dest.DecodeConfig(&webhookDest); err != nil {
+		return err
+	}
+	logger.Printf("Forwarding SMS to webhook: %s", redactURL(webhookDest.URL))
+
+	secret, err := common.GetSecretValue(ctx, f.Secrets, webhookDest.Secret.Name, webhookDest.Secret.Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch webhook secret: %w", err)
+	}
+	body, err := json.Marshal(models.NewWebhookPayload(smsRelayRequest))
+	if err != nil {
+		return err
+	}
+
+	backoff := webhookInitialBackoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := f.post(ctx, webhookDest, []byte(secret), body)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWebhookRetryable) || attempt == webhookMaxAttempts {
+			return err
+		}
+		wait := max(backoff, retryAfter)
+		logger.Printf("Webhook attempt %d failed, retrying in %s: %v", attempt, wait, err)
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+
+	logger.Printf("Successfully forwarded SMS to webhook: %s", redactURL(webhookDest.URL))
+	return nil
+}
+
+// post sends one signed request to the webhook. Retryable failures wrap errWebhookRetryable and
+// may come with the wait requested by the webhook's Retry-After header.
+func (f *WebhookForwarder) post(
+	ctx context.Context, webhookDest models.WebhookForwardDestination, secret []byte, body []byte,
+) (time.Duration, error) {
+	header := make(http.Header)
+	for name, value := range webhookDest.Headers {
+		header.Set(name, value)
+	}
+	// Set after the custom headers so that they cannot be overridden
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header.Set("Content-Type", "application/json")
+	header.Set("User-Agent", webhookUserAgent)
+	header.Set(common.WebhookTimestampHeader, timestamp)
+	header.Set(common.WebhookSignatureHeader, common.SignWebhook(secret, timestamp, body))
+
+	resp, err := sendHTTPRequest(ctx, f.HTTPClient, http.MethodPost, webhookDest.URL, header, body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, fmt.Errorf("failed to call webhook: %w: %w", errWebhookRetryable, err)
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return min(parseRetryAfter(resp.Header.Get("Retry-After")), maxRetryAfter),
+			fmt.Errorf("webhook returned status %d: %w", resp.StatusCode, errWebhookRetryable)
+	default:
+		return 0, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,96 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+func webhookDestination(url string) models.ForwardDestination {
+	return models.ForwardDestination{
+		Type: models.ForwardDestinationTypeWebhook,
+		Config: map[string]any{
+			"url":     url,
+			"headers": map[string]any{"Authorization": "Bearer abc", "X-Signature": "forged"},
+			"secret":  map[string]any{"name": "sms-relay/destinations/webhook"},
+		},
+	}
+}
+
+// webhookStatuses answers the requests with the given statuses in order, then with 200.
+func webhookStatuses(statuses ...int) http.HandlerFunc {
+	calls := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= len(statuses) {
+			w.WriteHeader(statuses[calls-1])
+		}
+	}
+}
+
+func TestWebhookForwardRetriesServerErrors(t *testing.T) {
+	server := newStubServer(t, webhookStatuses(http.StatusInternalServerError))
+	f := &WebhookForwarder{Secrets: staticSecrets{"sms-relay/destinations/webhook": "webhook-secret"}}
+
+	err := f.Forward(context.Background(), webhookDestination(server.URL+"/sms"), testRelayRequest("+15550199", "hello"))
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	for i, r := range requests {
+		if r.Method != http.MethodPost || r.Path != "/sms" {
+			t.Errorf("request %d: got %s %s, want POST /sms", i, r.Method, r.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer abc" {
+			t.Errorf("request %d: got Authorization %q, want the custom header", i, got)
+		}
+		timestamp := r.Header.Get(common.WebhookTimestampHeader)
+		signature := r.Header.Get(common.WebhookSignatureHeader)
+		if timestamp == "" {
+			t.Errorf("request %d: missing %s", i, common.WebhookTimestampHeader)
+		}
+		err := common.VerifyWebhookSignature([]byte("webhook-secret"), signature, timestamp, []byte(r.Body),
+			common.DefaultWebhookTolerance)
+		if err != nil {
+			t.Errorf("request %d: signature %q doesn't verify: %v", i, signature, err)
+		}
+
+		var payload models.WebhookPayload
+		if err := json.Unmarshal([]byte(r.Body), &payload); err != nil {
+			t.Errorf("request %d: failed to decode payload: %v", i, err)
+		}
+	}
+}
+
+func TestWebhookForwardGivesUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+	}{
+		{name: "client error", statuses: []int{http.StatusBadRequest}, wantRequests: 1},
+		{name: "server errors", statuses: []int{500, 502, 503}, wantRequests: webhookMaxAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubServer(t, webhookStatuses(tt.statuses...))
+			f := &WebhookForwarder{Secrets: staticSecrets{"sms-relay/destinations/webhook": "webhook-secret"}}
+
+			err := f.Forward(context.Background(), webhookDestination(server.URL), testRelayRequest("+15550199", "hello"))
+			if err == nil {
+				t.Error("got no error")
+			}
+			if n := len(server.Requests()); n != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package httpadapter serves handlers written for API Gateway proxy events over net/http.
+package httpadapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+const (
+	maxBodySize = 10 << 20 // 10 MiB, the API Gateway payload limit
+)
+
+var logger = log.Default()
+
+// ProxyHandlerFunc handles an API Gateway proxy request, like the handler of a Lambda behind an
+// AWS_PROXY integration.
+type ProxyHandlerFunc func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// NewProxyRequest converts an HTTP request into the equivalent API Gateway proxy request.
+func NewProxyRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         make(map[string]string, len(r.Header)),
+		MultiValueHeaders:               make(map[string][]string, len(r.Header)),
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: make(map[string][]string),
+		Body:                            string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  uuid.NewString(),
+			DomainName: r.Host,
+			Path:       r.URL.Path,
+			HTTPMethod: r.Method,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  remoteIP(r),
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+	for name, values := range r.Header {
+		// Like API Gateway, the single-value maps hold the last value of repeated entries
+		request.Headers[name] = values[len(values)-1]
+		request.MultiValueHeaders[name] = values
+	}
+	// Go moves the Host header out of the header map, while API Gateway passes it on
+	request.Headers["Host"] = r.Host
+	request.MultiValueHeaders["Host"] = []string{r.Host}
+	for name, values := range r.URL.Query() {
+		request.QueryStringParameters[name] = values[len(values)-1]
+		request.MultiValueQueryStringParameters[name] = values
+	}
+
+	return request, nil
+}
+
+// WriteProxyResponse writes an API Gateway proxy response to an HTTP response.
+func WriteProxyResponse(w http.ResponseWriter, resp events.APIGatewayProxyResponse) {
+	for name, value := range resp.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range resp.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.WriteString(w, resp.Body); err != nil {
+		logger.Printf("failed to write response body: %v", err)
+	}
+}
+
+// Handler adapts a ProxyHandlerFunc to an http.Handler. Like API Gateway, it responds with a 502
+// when the handler returns an error, though with a JSON problem body.
+func Handler(fn ProxyHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := NewProxyRequest(r)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				response.WriteError(w, r, http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge,
+					"Request Entity Too Large")
+				return
+			}
+			logger.Printf("failed to read request: %v", err)
+			response.WriteError(w, r, http.StatusBadRequest, response.CodeInvalidRequest, "Bad Request")
+			return
+		}
+
+		resp, err := fn(r.Context(), request)
+		if err != nil {
+			logger.Printf("handler error for %s %s: %v", r.Method, r.URL.Path, err)
+			ctx := response.WithRequestID(r.Context(), request.RequestContext.RequestID)
+			WriteProxyResponse(w, response.Error(ctx, http.StatusBadGateway, response.CodeBadGateway, "Bad Gateway"))
+			return
+		}
+		WriteProxyResponse(w, resp)
+	})
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
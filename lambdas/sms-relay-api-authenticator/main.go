@@ -2,27 +2,34 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
 	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
 )
 
 const (
 	defaultAWSRegion = "us-west-2"
-	jwtSecretName    = "JWTSecret"
+
+	// Also the AuthorizerResultTtlInSeconds of SmsAuthorizer, so that a revoked token is rejected
+	// within a minute: up to 30s until the cached revocation list sees it, and 30s more until API
+	// Gateway calls the authorizer again
+	revocationCacheTTL = time.Second * 30
+	jwksCacheTTL       = time.Minute * 5
 )
 
 var (
-	logger        = log.Default()
-	secretsClient *secretsmanager.Client
+	logger      = log.Default()
+	validator   auth.TokenValidator  // Shared across warm invocations
+	revocations *auth.RevocationList // Shared across warm invocations
 )
 
 type AuthRequest struct {
@@ -43,41 +50,37 @@ func init() {
 	if err != nil {
 		logger.Fatalf("unable to load SDK config, %v", err)
 	}
-	secretsClient = secretsmanager.NewFromConfig(cfg)
-	logger.Println("Secrets Manager client initialized")
+	// With a JWKS URL, tokens are verified with the public keys of the asymmetric signing keys, and
+	// the JWT secret isn't needed. Otherwise they are verified with the keyset of the secret, which
+	// is required as long as HS256 keys sign tokens.
+	if jwksURL := os.Getenv("JWKS_URL"); jwksURL != "" {
+		validator = auth.NewJWKSValidator(jwksURL, jwksCacheTTL)
+		logger.Printf("validating tokens with the JWKS of %s", jwksURL)
+	} else {
+		secrets, err := common.NewSecretsProviderFromEnv(cfg)
+		if err != nil {
+			logger.Fatalf("unable to initialize secrets provider, %v", err)
+		}
+		validator = &auth.KeySetValidator{Secrets: secrets}
+		logger.Println("secrets provider initialized")
+	}
+	revocations = auth.NewRevocationList(store.NewDynamoDBStore(dynamodb.NewFromConfig(cfg)), revocationCacheTTL)
 }
 
 func handler(ctx context.Context, request events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
 	// Extract the token from the Authorization header
-	const bearerPrefix = "Bearer "
-	if !strings.HasPrefix(request.AuthorizationToken, bearerPrefix) {
-		logger.Println("invalid authorization header format")
-		return events.APIGatewayCustomAuthorizerResponse{
-			PrincipalID:    "unknown",
-			PolicyDocument: generatePolicy("unknown", "Deny", request.MethodArn),
-		}, nil
-	}
-	tokenString := strings.TrimPrefix(request.AuthorizationToken, bearerPrefix)
-
-	// Retrieve the JWT secret using the helper function from the common package
-	jwtSecretKey, err := common.GetSecretValue(ctx, secretsClient, jwtSecretName, "JWTKey")
+	tokenString, err := auth.TokenFromHeader(request.AuthorizationToken)
 	if err != nil {
-		logger.Printf("failed to retrieve JWT secret: %v", err)
+		logger.Println(err)
 		return events.APIGatewayCustomAuthorizerResponse{
 			PrincipalID:    "unknown",
 			PolicyDocument: generatePolicy("unknown", "Deny", request.MethodArn),
 		}, nil
 	}
 
-	// Parse and validate the JWT
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecretKey), nil
-	})
-
-	if err != nil || !token.Valid {
+	// Parse and validate the JWT, and check that it wasn't revoked
+	authCtx, err := auth.Authenticate(ctx, validator, revocations, tokenString)
+	if err != nil {
 		logger.Printf("invalid token: %v", err)
 		return events.APIGatewayCustomAuthorizerResponse{
 			PrincipalID:    "unknown",
@@ -85,30 +88,27 @@ func handler(ctx context.Context, request events.APIGatewayCustomAuthorizerReque
 		}, nil
 	}
 
-	// Extract claims and set PrincipalID
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		logger.Println("failed to parse token claims")
-		return events.APIGatewayCustomAuthorizerResponse{
-			PrincipalID:    "unknown",
-			PolicyDocument: generatePolicy("unknown", "Deny", request.MethodArn),
-		}, nil
-	}
-
-	logger.Printf("user %s authenticated successfully", claims["sub"])
-	principalID, _ := claims["sub"].(string)
+	logger.Printf("user %s authenticated successfully", authCtx.UserID)
 	return events.APIGatewayCustomAuthorizerResponse{
-		PrincipalID:    principalID,
-		PolicyDocument: generatePolicy(principalID, "Allow", request.MethodArn),
-		Context: map[string]any{
-			"user_id":   principalID,
-			"user_type": claims["user_type"],
-			"user_name": claims["user_name"],
-			"device_id": claims["device_id"],
-		},
+		PrincipalID:    authCtx.UserID,
+		PolicyDocument: generatePolicy(authCtx.UserID, "Allow", stageResource(request.MethodArn)),
+		Context:        authCtx.AuthorizerContext(),
 	}, nil
 }
 
+// stageResource returns the resource of all the methods of the stage of a method ARN, e.g.
+// arn:aws:execute-api:us-west-2:123456789012:abcdef1234/prod/*/* for
+// arn:aws:execute-api:us-west-2:123456789012:abcdef1234/prod/GET/sms. API Gateway caches the policy
+// of a token for all the methods it is used with, so allowing only the first one would deny the
+// others until the cached policy expires.
+func stageResource(methodARN string) string {
+	parts := strings.SplitN(methodARN, "/", 3)
+	if len(parts) < 3 {
+		return methodARN
+	}
+	return parts[0] + "/" + parts[1] + "/*/*"
+}
+
 func generatePolicy(principalID, effect, resource string) events.APIGatewayCustomAuthorizerPolicy {
 	return events.APIGatewayCustomAuthorizerPolicy{
 		Version: "2012-10-17",
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/forwarder"
+	"github.com/zhouziqunzzq/sms-relay-server/monitor"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+var checker *monitor.Checker
+
+func init() {
+	// Initialize the secrets provider and the DynamoDB client
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
+	}
+	secrets, err := common.NewSecretsProviderFromEnv(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets provider: %v", err)
+	}
+	dbClient := dynamodb.NewFromConfig(cfg)
+	log.Println("secrets provider and DynamoDB client initialized")
+
+	// Alerts are sent through the same forwarders as SMS
+	registry, err := forwarder.NewRegistryFromEnv(secrets)
+	if err != nil {
+		log.Fatalf("failed to initialize forwarders: %v", err)
+	}
+	checker, err = monitor.NewCheckerFromEnv(store.NewDynamoDBStore(dbClient), registry)
+	if err != nil {
+		log.Fatalf("failed to initialize device checker: %v", err)
+	}
+}
+
+// handler checks the heartbeats of all devices when invoked by the schedule rule. Alerts that
+// failed to be delivered are retried by later invocations.
+func handler(ctx context.Context) error {
+	return checker.Check(ctx)
+}
+
+func main() {
+	lambda.Start(handler)
+}
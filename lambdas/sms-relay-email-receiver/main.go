@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/emailreply"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const sesVerdictPass = "PASS"
+
+var (
+	logger = log.Default()
+
+	receiver *emailreply.Receiver
+)
+
+// sesNotification is an email received by an SES receipt rule with an SNS action, which includes
+// the raw email as content.
+type sesNotification struct {
+	NotificationType string                    `json:"notificationType"`
+	Mail             events.SimpleEmailMessage `json:"mail"`
+	Receipt          events.SimpleEmailReceipt `json:"receipt"`
+	Content          string                    `json:"content"`
+}
+
+func init() {
+	// Initialize the secrets provider and the DynamoDB client
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
+	}
+	secrets, err := common.NewSecretsProviderFromEnv(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets provider: %v", err)
+	}
+	dbClient := dynamodb.NewFromConfig(cfg)
+	log.Println("secrets provider and DynamoDB client initialized")
+
+	receiver = &emailreply.Receiver{
+		Store:   store.NewDynamoDBStore(dbClient),
+		Secrets: secrets,
+	}
+}
+
+// handler turns replies to forwarded SMS emails, received by SES and published to SNS, into
+// outbound SMS. Rejected emails are dropped, while other failures are returned so that the
+// invocation is retried.
+func handler(ctx context.Context, snsEvent events.SNSEvent) error {
+	var errs []error
+	for _, record := range snsEvent.Records {
+		var notification sesNotification
+		if err := json.Unmarshal([]byte(record.SNS.Message), &notification); err != nil {
+			logger.Printf("dropping malformed SES notification %s: %v", record.SNS.MessageID, err)
+			continue
+		}
+		if notification.NotificationType != "Received" {
+			continue
+		}
+
+		// Require the sender to be authenticated, as the reply is trusted based on its From address
+		receipt := notification.Receipt
+		if !isFromAuthenticated(notification) {
+			logger.Printf("dropping email %s whose From domain is not authenticated", notification.Mail.MessageID)
+			continue
+		}
+		if receipt.SpamVerdict.Status == "FAIL" || receipt.VirusVerdict.Status == "FAIL" {
+			logger.Printf("dropping email %s flagged as spam or virus", notification.Mail.MessageID)
+			continue
+		}
+
+		outboundSMS, err := receiver.Receive(ctx, []byte(notification.Content), receipt.Recipients)
+		switch {
+		case errors.Is(err, emailreply.ErrRejected), errors.Is(err, emailreply.ErrDuplicate):
+			logger.Printf("dropping email %s: %v", notification.Mail.MessageID, err)
+		case err != nil:
+			logger.Printf("failed to receive email %s: %v", notification.Mail.MessageID, err)
+			errs = append(errs, err)
+		default:
+			logger.Printf("email %s queued as outbound SMS %s", notification.Mail.MessageID, outboundSMS.ID)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isFromAuthenticated reports whether the domain of the From header of the email is authenticated.
+// SPF and DKIM alone only authenticate the envelope sender and the signing domain, which anyone can
+// set to their own domain while spoofing the From header, so either DMARC must pass or SPF must
+// pass for an envelope sender of the From domain. SES doesn't report the DKIM signing domain, so
+// DKIM is only trusted through DMARC.
+func isFromAuthenticated(notification sesNotification) bool {
+	receipt := notification.Receipt
+	if receipt.DMARCVerdict.Status == sesVerdictPass {
+		return true
+	}
+	if receipt.SPFVerdict.Status != sesVerdictPass {
+		return false
+	}
+	msg, err := emailreply.ParseMessage([]byte(notification.Content))
+	if err != nil {
+		return false
+	}
+	envelopeDomain := addressDomain(notification.Mail.Source)
+	return envelopeDomain != "" && envelopeDomain == addressDomain(msg.From)
+}
+
+// addressDomain returns the lowercase domain of an email address, or "" if it has none.
+func addressDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSuffix(address[at+1:], "."))
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -2,78 +2,60 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
-	"os"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/zhouziqunzzq/sms-relay-server/models"
-)
-
-const (
-	smtpUsernameSecretName = "SMTPUsername"
-	smtpPasswordSecretName = "SMTPPassword"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/zhouziqunzzq/sms-relay-server/common"
+	"github.com/zhouziqunzzq/sms-relay-server/forwarder"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
 )
 
 var (
 	logger = log.Default()
 
-	smtpServer    string
-	smtpPort      string
-	secretsClient *secretsmanager.Client
-	useSSL        bool
+	processor *forwarder.Processor
 )
 
 func init() {
-	// Load SMTP server address and port from environment variables
-	smtpServer = os.Getenv("SMTP_SERVER")
-	if smtpServer == "" {
-		log.Fatalf("SMTP_SERVER environment variable is not set")
-	}
-
-	smtpPort = os.Getenv("SMTP_PORT")
-	if smtpPort == "" {
-		log.Fatalf("SMTP_PORT environment variable is not set")
+	// Initialize the secrets provider and the DynamoDB client
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
 	}
-
-	// Load SSL flag from environment variable
-	sslFlag := os.Getenv("SSL")
-	if sslFlag == "true" {
-		useSSL = true
-	} else {
-		useSSL = false
+	secrets, err := common.NewSecretsProviderFromEnv(cfg)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets provider: %v", err)
 	}
+	dbClient := dynamodb.NewFromConfig(cfg)
+	log.Println("secrets provider and DynamoDB client initialized")
 
-	// Initialize AWS Secrets Manager client
-	cfg, err := config.LoadDefaultConfig(context.Background())
+	// Register the available forwarders
+	registry, err := forwarder.NewRegistryFromEnv(secrets)
 	if err != nil {
-		log.Fatalf("failed to load AWS config: %v", err)
+		log.Fatalf("failed to initialize forwarders: %v", err)
+	}
+	processor = &forwarder.Processor{
+		Registry: registry,
+		Records:  store.NewDynamoDBStore(dbClient),
 	}
-	secretsClient = secretsmanager.NewFromConfig(cfg)
-	log.Println("Secrets Manager client initialized")
 }
 
-func handler(ctx context.Context, sqsEvent events.SQSEvent) error {
+// handler processes a batch of SMS relay requests. Only the records that failed to forward are
+// reported back as batch item failures, so that SQS redelivers them without the rest of the batch.
+func handler(ctx context.Context, sqsEvent events.SQSEvent) (events.SQSEventResponse, error) {
+	var resp events.SQSEventResponse
 	for _, message := range sqsEvent.Records {
-		var smsRelayRequest models.SMSRelayRequest
-		if err := json.Unmarshal([]byte(message.Body), &smsRelayRequest); err != nil {
-			logger.Printf("failed to unmarshal SQS message: %v", err)
-			return err
-		}
-
-		logger.Printf("Processing SMS Relay Request (Device ID: %s, Phone Number: %s)",
-			smsRelayRequest.Device.ID, smsRelayRequest.PhoneNumber.PhoneNumber)
-
-		// Forward SMS by Email
-		if err := forwardSMSByEmail(ctx, smsRelayRequest); err != nil {
-			logger.Printf("failed to forward SMS by email: %v", err)
-			return err
+		if err := processor.ProcessMessage(ctx, message.MessageId, message.Body); err != nil {
+			logger.Printf("failed to process SQS message %s: %v", message.MessageId, err)
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: message.MessageId,
+			})
 		}
 	}
-	return nil
+	return resp, nil
 }
 
 func main() {
@@ -4,6 +4,8 @@ package models
 // - If a user has an ACL entry for a device, they have access to SMS of all phone numbers
 //   associated with that device.
 // - If a user has an ACL entry for a phone number, they have access to SMS of that phone number.
+//
+// Access to the SMS of a phone number includes sending outbound SMS from it.
 type ACL struct {
 	ID string `json:"id"` // UUID of the ACL entry
 
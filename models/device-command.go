@@ -0,0 +1,27 @@
+package models
+
+const (
+	DeviceCommandTypeSendSMS = "send_sms" // DeviceCommandTypeSendSMS asks the device to send an OutboundSMS
+)
+
+// DeviceCommand is an instruction queued for a device. Devices poll their pending commands and
+// delete them by acknowledging them once handled, so a command may be received more than once
+// until it is acknowledged.
+type DeviceCommand struct {
+	ID string `json:"id"` // UUID of the command
+
+	DeviceID string `json:"device_id"` // ID of the device the command is queued for
+	Type     string `json:"type"`      // Type of the command, one of the DeviceCommandType constants
+
+	// SendSMS holds the SMS to send for send_sms commands.
+	SendSMS *SendSMSCommand `json:"send_sms,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"` // Timestamp of when the command was queued
+}
+
+type SendSMSCommand struct {
+	OutboundSMSID string `json:"outbound_sms_id"` // ID of the OutboundSMS to report the status of
+	PhoneNumber   string `json:"phone_number"`    // Phone number (SIM) of the device to send from, in E.164 format
+	To            string `json:"to"`              // Phone number of the recipient, in E.164 format
+	Body          string `json:"body"`            // Content of the SMS message
+}
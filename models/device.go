@@ -1,10 +1,49 @@
 package models
 
+const (
+	SIMStateReady         = "ready"          // SIMStateReady means the SIM is usable
+	SIMStateAbsent        = "absent"         // SIMStateAbsent means no SIM is inserted
+	SIMStatePINRequired   = "pin_required"   // SIMStatePINRequired means the SIM is locked by its PIN
+	SIMStatePUKRequired   = "puk_required"   // SIMStatePUKRequired means the SIM is locked by its PUK
+	SIMStateNetworkLocked = "network_locked" // SIMStateNetworkLocked means the SIM is locked to another network
+	SIMStateNotReady      = "not_ready"      // SIMStateNotReady means the SIM is not ready yet, e.g. while booting
+	SIMStateUnknown       = "unknown"        // SIMStateUnknown means the device can't tell the SIM state
+)
+
 type Device struct {
-	ID string `json:"id"` // UUID of the device
+	ID   string `json:"id"`             // UUID of the device
+	Name string `json:"name,omitempty"` // Displayed name of the device
 
 	PhoneNumberIDs []string `json:"phone_number_ids"` // List of phone number IDs associated with the device
 
+	LastSeenAt string        `json:"last_seen_at,omitempty"` // Timestamp of the device's last heartbeat
+	Status     *DeviceStatus `json:"status,omitempty"`       // Status reported by the device's last heartbeat
+
+	// OfflineAlertedAt is the timestamp of when the device was reported offline for missing
+	// heartbeats, empty while the device is online or after its recovery was reported.
+	OfflineAlertedAt string `json:"offline_alerted_at,omitempty"`
+
 	CreatedAt string `json:"created_at,omitempty"` // Timestamp of when the device was created
 	UpdatedAt string `json:"updated_at,omitempty"` // Timestamp of when the device was last updated
 }
+
+// DeviceStatus is the state of a device reported with its heartbeats. Levels the device can't read
+// are omitted.
+type DeviceStatus struct {
+	BatteryLevel *int   `json:"battery_level,omitempty"` // Battery level in percent, from 0 to 100
+	Charging     bool   `json:"charging,omitempty"`      // Whether the device is charging
+	SignalLevel  *int   `json:"signal_level,omitempty"`  // Cellular signal level in bars, from 0 to 4
+	SIMState     string `json:"sim_state,omitempty"`     // State of the SIM, one of the SIMState constants
+	AppVersion   string `json:"app_version,omitempty"`   // Version of the relay app on the device
+}
+
+// IsValidSIMState reports whether state is one of the SIMState constants.
+func IsValidSIMState(state string) bool {
+	switch state {
+	case SIMStateReady, SIMStateAbsent, SIMStatePINRequired, SIMStatePUKRequired, SIMStateNetworkLocked,
+		SIMStateNotReady, SIMStateUnknown:
+		return true
+	default:
+		return false
+	}
+}
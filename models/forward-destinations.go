@@ -1,7 +1,85 @@
 package models
 
-type ForwardDestinations struct {
-	Email EmailForwardDestination `json:"email"` // Email destination for forwarding messages
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	ForwardDestinationTypeEmail    = "email"    // ForwardDestinationTypeEmail forwards messages by email
+	ForwardDestinationTypeTelegram = "telegram" // ForwardDestinationTypeTelegram forwards messages through a Telegram bot
+	ForwardDestinationTypeWebhook  = "webhook"  // ForwardDestinationTypeWebhook forwards messages to a signed HTTP webhook
+	ForwardDestinationTypeSlack    = "slack"    // ForwardDestinationTypeSlack forwards messages to a Slack incoming webhook
+	ForwardDestinationTypeDiscord  = "discord"  // ForwardDestinationTypeDiscord forwards messages to a Discord webhook
+	ForwardDestinationTypeNtfy     = "ntfy"     // ForwardDestinationTypeNtfy forwards messages as ntfy push notifications
+	ForwardDestinationTypeGotify   = "gotify"   // ForwardDestinationTypeGotify forwards messages as Gotify push notifications
+	ForwardDestinationTypeMatrix   = "matrix"   // ForwardDestinationTypeMatrix forwards messages to a Matrix room
+)
+
+// ForwardDestinations is the list of destinations to which messages are forwarded. It also decodes
+// the legacy shape of a single email destination, {"email": {"email": "..."}}, still held by the
+// phone numbers stored and the messages queued before destinations were typed.
+type ForwardDestinations []ForwardDestination
+
+// legacyForwardDestinations is the shape of ForwardDestinations before destinations were typed.
+type legacyForwardDestinations struct {
+	Email EmailForwardDestination `json:"email"`
+}
+
+// toForwardDestinations returns the typed destinations of the legacy shape: its email destination,
+// if it has an address.
+func (l *legacyForwardDestinations) toForwardDestinations() ForwardDestinations {
+	if l.Email.IsEmpty() {
+		return ForwardDestinations{}
+	}
+	return ForwardDestinations{{
+		Type:   ForwardDestinationTypeEmail,
+		Config: map[string]any{"email": l.Email.Email},
+	}}
+}
+
+func (fd *ForwardDestinations) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var legacy legacyForwardDestinations
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		*fd = legacy.toForwardDestinations()
+		return nil
+	}
+	return json.Unmarshal(data, (*[]ForwardDestination)(fd))
+}
+
+func (fd *ForwardDestinations) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	if _, ok := av.(*types.AttributeValueMemberM); ok {
+		var legacy legacyForwardDestinations
+		if err := attributevalue.Unmarshal(av, &legacy); err != nil {
+			return err
+		}
+		*fd = legacy.toForwardDestinations()
+		return nil
+	}
+	return attributevalue.Unmarshal(av, (*[]ForwardDestination)(fd))
+}
+
+// ForwardDestination is a single destination of a forwarder. Type names the forwarder that handles
+// it and Config holds the forwarder-specific configuration, e.g. an EmailForwardDestination.
+type ForwardDestination struct {
+	Type   string         `json:"type"`   // Type of the forwarder handling this destination, e.g. "email"
+	Config map[string]any `json:"config"` // Forwarder-specific configuration of this destination
+}
+
+// DecodeConfig decodes the destination's Config into v, which must be a pointer to the
+// configuration type of the destination's forwarder.
+func (fd *ForwardDestination) DecodeConfig(v any) error {
+	configJSON, err := json.Marshal(fd.Config)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(configJSON, v)
 }
 
 type EmailForwardDestination struct {
@@ -11,3 +89,51 @@ type EmailForwardDestination struct {
 func (efd *EmailForwardDestination) IsEmpty() bool {
 	return efd.Email == ""
 }
+
+type TelegramForwardDestination struct {
+	ChatID   string          `json:"chat_id"`   // ID of the chat, group or channel to send messages to
+	BotToken SecretReference `json:"bot_token"` // Secret holding the token of the bot sending the messages
+}
+
+type WebhookForwardDestination struct {
+	URL     string            `json:"url"`               // HTTP(S) URL to POST messages to
+	Headers map[string]string `json:"headers,omitempty"` // Additional headers sent with each request
+	Secret  SecretReference   `json:"secret"`            // Secret used to sign the requests with HMAC-SHA256
+}
+
+type SlackForwardDestination struct {
+	WebhookURL SecretReference `json:"webhook_url"` // Secret holding the URL of the incoming webhook
+}
+
+type DiscordForwardDestination struct {
+	WebhookURL SecretReference `json:"webhook_url"` // Secret holding the URL of the webhook
+}
+
+type NtfyForwardDestination struct {
+	ServerURL   string          `json:"server_url"`             // Base URL of the ntfy server, e.g. "https://ntfy.sh"
+	Topic       SecretReference `json:"topic"`                  // Secret holding the topic, which acts as a password on public servers
+	AccessToken SecretReference `json:"access_token,omitempty"` // Optional secret holding an access token of the server
+	Priority    int             `json:"priority,omitempty"`     // Priority from 1 (min) to 5 (max), 0 for the server default
+	Tags        []string        `json:"tags,omitempty"`         // Tags of the notifications, may be emoji short codes
+
+	// VerificationCodePriority replaces Priority when the SMS looks like a verification code, 0 to
+	// keep Priority.
+	VerificationCodePriority int `json:"verification_code_priority,omitempty"`
+}
+
+type GotifyForwardDestination struct {
+	ServerURL string          `json:"server_url"`         // Base URL of the Gotify server
+	AppToken  SecretReference `json:"app_token"`          // Secret holding the token of the application sending the messages
+	Priority  int             `json:"priority,omitempty"` // Priority from 1 to 10, 0 for the application's default
+	Tags      []string        `json:"tags,omitempty"`     // Tags appended to the messages, as Gotify has no native tags
+
+	// VerificationCodePriority replaces Priority when the SMS looks like a verification code, 0 to
+	// keep Priority.
+	VerificationCodePriority int `json:"verification_code_priority,omitempty"`
+}
+
+type MatrixForwardDestination struct {
+	HomeserverURL string          `json:"homeserver_url"` // Base URL of the client-server API, e.g. "https://matrix.example.org"
+	RoomID        string          `json:"room_id"`        // ID of the room to send messages to, e.g. "!abc:example.org"
+	AccessToken   SecretReference `json:"access_token"`   // Secret holding the access token of the sending user
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var legacyEmailDestinations = ForwardDestinations{{
+	Type:   ForwardDestinationTypeEmail,
+	Config: map[string]any{"email": "alice@example.com"},
+}}
+
+func TestForwardDestinationsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want ForwardDestinations
+	}{
+		{
+			name: "list",
+			data: `[{"type":"email","config":{"email":"alice@example.com"}}]`,
+			want: legacyEmailDestinations,
+		},
+		{
+			name: "legacy email",
+			data: `{"email":{"email":"alice@example.com"}}`,
+			want: legacyEmailDestinations,
+		},
+		{
+			name: "legacy without email",
+			data: `{"email":{"email":""}}`,
+			want: ForwardDestinations{},
+		},
+		{
+			name: "null",
+			data: `null`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ForwardDestinations
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardDestinationsUnmarshalDynamoDBAttributeValue(t *testing.T) {
+	// Item of a phone number stored before destinations were typed
+	legacyItem := map[string]types.AttributeValue{
+		"ID":          &types.AttributeValueMemberS{Value: "phone-number-id"},
+		"PhoneNumber": &types.AttributeValueMemberS{Value: "+15555550100"},
+		"ForwardDestinations": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"Email": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"Email": &types.AttributeValueMemberS{Value: "alice@example.com"},
+			}},
+		}},
+	}
+	var legacy PhoneNumber
+	if err := attributevalue.UnmarshalMap(legacyItem, &legacy); err != nil {
+		t.Fatalf("UnmarshalMap() of legacy item error = %v", err)
+	}
+	if !reflect.DeepEqual(legacy.ForwardDestinations, legacyEmailDestinations) {
+		t.Errorf("legacy ForwardDestinations = %#v, want %#v", legacy.ForwardDestinations, legacyEmailDestinations)
+	}
+
+	// Round trip of the current shape
+	item, err := attributevalue.MarshalMap(PhoneNumber{ID: "phone-number-id", ForwardDestinations: legacyEmailDestinations})
+	if err != nil {
+		t.Fatalf("MarshalMap() error = %v", err)
+	}
+	var current PhoneNumber
+	if err := attributevalue.UnmarshalMap(item, &current); err != nil {
+		t.Fatalf("UnmarshalMap() error = %v", err)
+	}
+	if !reflect.DeepEqual(current.ForwardDestinations, legacyEmailDestinations) {
+		t.Errorf("ForwardDestinations = %#v, want %#v", current.ForwardDestinations, legacyEmailDestinations)
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+const (
+	IdempotencyStatusPending   = "PENDING"   // IdempotencyStatusPending marks a request that is still being processed
+	IdempotencyStatusCompleted = "COMPLETED" // IdempotencyStatusCompleted marks a request whose response is recorded
+	IdempotencyStatusFailed    = "FAILED"    // IdempotencyStatusFailed marks a request that failed and may be retried
+)
+
+// IdempotencyRecord deduplicates retried requests and redelivered messages. Records expire through
+// the DynamoDB TTL on ExpiresAt.
+type IdempotencyRecord struct {
+	ID string `json:"id"` // Deduplication key, e.g. "sms#<device ID>#<idempotency key>" or "sqs#<message ID>"
+
+	Status          string            `json:"status,omitempty"`           // Processing status of an API request (PENDING, COMPLETED, FAILED)
+	SMSID           string            `json:"sms_id,omitempty"`           // ID of the SMS created by the original API request, reused by its retries
+	StatusCode      int               `json:"status_code,omitempty"`      // Status code of the original API response
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"` // Headers of the original API response
+	ResponseBody    string            `json:"response_body,omitempty"`    // Body of the original API response
+
+	// ForwardedDestinations holds the indexes of the forward destinations a queued message has
+	// already been forwarded to.
+	ForwardedDestinations []int `json:"forwarded_destinations,omitempty" dynamodbav:",numberset,omitempty"`
+
+	CreatedAt string `json:"created_at,omitempty"` // Timestamp of when the record was created
+	ExpiresAt int64  `json:"expires_at"`           // Unix time after which the record expires
+}
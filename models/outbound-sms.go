@@ -0,0 +1,34 @@
+package models
+
+const (
+	OutboundSMSStatusQueued    = "queued"    // OutboundSMSStatusQueued means the SMS waits for its device to send it
+	OutboundSMSStatusSent      = "sent"      // OutboundSMSStatusSent means the device handed the SMS to the carrier
+	OutboundSMSStatusDelivered = "delivered" // OutboundSMSStatusDelivered means the carrier confirmed delivery
+	OutboundSMSStatusFailed    = "failed"    // OutboundSMSStatusFailed means the device could not send the SMS
+)
+
+// OutboundSMS is an SMS composed by a user to be sent by the device of one of their phone numbers.
+type OutboundSMS struct {
+	ID string `json:"id"` // UUID of the outbound SMS
+
+	PhoneNumberID string `json:"phone_number_id"` // ID of the phone number sending the SMS
+	DeviceID      string `json:"device_id"`       // ID of the device sending the SMS
+	UserID        string `json:"user_id"`         // ID of the user who composed the SMS, empty for email replies
+
+	// EmailFrom is the email address of the reply the SMS was composed from, for reply-by-email.
+	EmailFrom string `json:"email_from,omitempty"`
+
+	To   string `json:"to"`   // Phone number of the recipient, in E.164 format
+	Body string `json:"body"` // Content of the SMS message
+
+	Status string `json:"status"`          // Delivery status, one of the OutboundSMSStatus constants
+	Error  string `json:"error,omitempty"` // Error reported by the device if the status is failed
+
+	CreatedAt string `json:"created_at,omitempty"` // Timestamp of when the SMS was composed
+	UpdatedAt string `json:"updated_at,omitempty"` // Timestamp of when the status was last updated
+}
+
+// IsFinal reports whether the status of the SMS can no longer change.
+func (o *OutboundSMS) IsFinal() bool {
+	return o.Status == OutboundSMSStatusDelivered || o.Status == OutboundSMSStatusFailed
+}
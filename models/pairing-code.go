@@ -0,0 +1,14 @@
+package models
+
+// PairingCode is a one-time code issued by a user to enroll a new device. Only a hash of the code
+// is stored, and codes expire through the DynamoDB TTL on ExpiresAt, although they are rejected as
+// soon as they expire.
+type PairingCode struct {
+	ID string `json:"id"` // Hex SHA-256 hash of the normalized code
+
+	UserID     string `json:"user_id"`     // ID of the user who issued the code and will own the device
+	DeviceName string `json:"device_name"` // Displayed name of the device to enroll
+
+	CreatedAt string `json:"created_at,omitempty"` // Timestamp of when the code was issued
+	ExpiresAt int64  `json:"expires_at"`           // Unix time after which the code can't be used
+}
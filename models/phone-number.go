@@ -1,11 +1,24 @@
 package models
 
+import "regexp"
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// IsE164 reports whether number is a phone number in E.164 format, e.g. "+14155550123".
+func IsE164(number string) bool {
+	return e164Pattern.MatchString(number)
+}
+
 type PhoneNumber struct {
 	ID string `json:"id"` // UUID of the phone number
 
 	PhoneNumber string `json:"phone_number"`   // Full phone number in E.164 format
 	Name        string `json:"name,omitempty"` // Displayed name of the phone number
 
+	// DeviceID is the ID of the device the phone number is attached to, which sends the outbound
+	// SMS of the phone number. The device must also list the phone number in its PhoneNumberIDs.
+	DeviceID string `json:"device_id,omitempty"`
+
 	// ForwardDestinations contains the list of destinations to which SMS messages
 	// sent to this phone number should be forwarded.
 	ForwardDestinations ForwardDestinations `json:"forward_destinations"`
@@ -0,0 +1,22 @@
+package models
+
+// RefreshToken is a refresh token issued along with an access token, which can be exchanged once
+// for a new pair of tokens. Only a hash of the token is stored. The tokens rotated from the same
+// login form a family, and exchanged tokens are kept marked as used, so that presenting one again
+// reveals that the family was stolen. Tokens expire through the DynamoDB TTL on ExpiresAt, although
+// they are rejected as soon as they expire.
+type RefreshToken struct {
+	ID string `json:"id"` // Hex SHA-256 hash of the token
+
+	UserID   string `json:"user_id"`   // ID of the user the token was issued to
+	FamilyID string `json:"family_id"` // ID shared by the tokens rotated from the same login
+
+	// ID (jti claim) and expiry of the access token issued along with the refresh token, so that
+	// it can be revoked with the family
+	AccessTokenID        string `json:"access_token_id"`
+	AccessTokenExpiresAt int64  `json:"access_token_expires_at"`
+
+	CreatedAt string `json:"created_at,omitempty"` // Timestamp of when the token was issued
+	UsedAt    string `json:"used_at,omitempty"`    // Timestamp of when the token was exchanged, empty if it wasn't
+	ExpiresAt int64  `json:"expires_at"`           // Unix time after which the token can't be used
+}
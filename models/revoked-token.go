@@ -0,0 +1,12 @@
+package models
+
+// RevokedToken is an entry of the revocation list of access tokens, which are rejected until they
+// expire. Entries expire with their token through the DynamoDB TTL on ExpiresAt.
+type RevokedToken struct {
+	ID string `json:"id"` // ID (jti claim) of the access token
+
+	UserID string `json:"user_id"` // ID of the user the token was issued to
+
+	RevokedAt string `json:"revoked_at,omitempty"` // Timestamp of when the token was revoked
+	ExpiresAt int64  `json:"expires_at"`           // Unix time after which the token expires
+}
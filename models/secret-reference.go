@@ -0,0 +1,13 @@
+package models
+
+// SecretReference refers to a secret of the secrets provider, so that credentials of forward
+// destinations are not stored in the database. With the CloudFormation template, the forwarder can
+// only read secrets whose names start with "sms-relay/destinations/".
+type SecretReference struct {
+	Name string `json:"name"`          // Name of the secret
+	Key  string `json:"key,omitempty"` // Key of the value if the secret is a JSON object, empty to use the whole secret
+}
+
+func (sr *SecretReference) IsEmpty() bool {
+	return sr.Name == ""
+}
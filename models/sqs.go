@@ -1,8 +1,17 @@
 package models
 
+const (
+	DeviceAlertOffline   = "device_offline"   // DeviceAlertOffline reports a device that stopped sending heartbeats
+	DeviceAlertRecovered = "device_recovered" // DeviceAlertRecovered reports a device sending heartbeats again
+)
+
 type SMSRelayRequest struct {
 	Device      Device      `json:"device"`       // Device details
 	DeviceName  string      `json:"device_name"`  // Name of the device
 	PhoneNumber PhoneNumber `json:"phone_number"` // Phone number details
 	SMS         SMS         `json:"sms"`          // SMS message details
+
+	// Alert is the DeviceAlert constant of requests notifying of the device's state rather than
+	// relaying a received SMS. The SMS of such requests is a notice from the relay itself.
+	Alert string `json:"alert,omitempty"`
 }
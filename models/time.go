@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TimestampFormat is the layout of every timestamp persisted by the relay. It is fixed-width and
+// always UTC so that timestamps sort lexicographically, which DynamoDB range keys rely on.
+const TimestampFormat = "2006-01-02T15:04:05.000Z"
+
+// FormatTimestamp formats t using TimestampFormat.
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(TimestampFormat)
+}
@@ -30,16 +30,26 @@ func (u *User) IsDevice() bool {
 	return u.UserType == UserTypeDevice
 }
 
-func (u *User) GenerateJWT(jwtSecretKey []byte, expireAfter time.Time) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// GenerateJWT returns an access token of the user, identified by tokenID (jti claim) so that it can
+// be revoked. It is signed with the given method and key: the secret of HS256, or the private key
+// of EdDSA (ed25519.PrivateKey) and ES256 (*ecdsa.PrivateKey). The key is named by the kid header
+// unless keyID is empty.
+func (u *User) GenerateJWT(
+	method jwt.SigningMethod, keyID string, signingKey any, tokenID string, expireAfter time.Time,
+) (string, error) {
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
 		"iss":       "sms-relay-server",
 		"sub":       u.ID,
+		"jti":       tokenID,
 		"iat":       time.Now().Unix(),
 		"exp":       expireAfter.Unix(),
-		"alg":       "HS256",
+		"alg":       method.Alg(),
 		"user_type": u.UserType,
 		"user_name": u.Name,
 		"device_id": u.DeviceID,
 	})
-	return token.SignedString(jwtSecretKey)
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+	return token.SignedString(signingKey)
 }
@@ -0,0 +1,67 @@
+package models
+
+// WebhookPayloadVersion is the version of WebhookPayload sent by the webhook forwarder. It is
+// incremented whenever a field is removed or changes meaning; new fields may be added without a
+// version change.
+const WebhookPayloadVersion = 1
+
+// WebhookEventSMS is the event of webhook payloads relaying a received SMS. Payloads of device
+// alerts carry the DeviceAlert constant as their event instead.
+const WebhookEventSMS = "sms"
+
+// WebhookPayload is the JSON body POSTed to webhook forward destinations.
+type WebhookPayload struct {
+	Version     int                `json:"version"` // Version of the payload, see WebhookPayloadVersion
+	Event       string             `json:"event"`   // WebhookEventSMS or a DeviceAlert constant
+	Device      WebhookDevice      `json:"device"`
+	PhoneNumber WebhookPhoneNumber `json:"phone_number"`
+	SMS         WebhookSMS         `json:"sms"`
+}
+
+type WebhookDevice struct {
+	ID   string `json:"id"`   // UUID of the device
+	Name string `json:"name"` // Name of the device
+}
+
+type WebhookPhoneNumber struct {
+	ID          string `json:"id"`             // UUID of the phone number
+	PhoneNumber string `json:"phone_number"`   // Full phone number in E.164 format
+	Name        string `json:"name,omitempty"` // Displayed name of the phone number
+}
+
+type WebhookSMS struct {
+	ID         string `json:"id"`                    // UUID of the SMS message
+	From       string `json:"from"`                  // Phone number of the sender
+	Body       string `json:"body"`                  // Content of the SMS message
+	ReceivedAt string `json:"received_at,omitempty"` // Timestamp of when the SMS was received by the device
+	CreatedAt  string `json:"created_at,omitempty"`  // Timestamp of when the SMS entry was created in the database
+}
+
+// NewWebhookPayload builds the webhook payload of a relay request. Only the fields listed in the
+// payload are included, so that e.g. forward destination configuration is never sent to webhooks.
+func NewWebhookPayload(smsRelayRequest SMSRelayRequest) WebhookPayload {
+	event := WebhookEventSMS
+	if smsRelayRequest.Alert != "" {
+		event = smsRelayRequest.Alert
+	}
+	return WebhookPayload{
+		Version: WebhookPayloadVersion,
+		Event:   event,
+		Device: WebhookDevice{
+			ID:   smsRelayRequest.Device.ID,
+			Name: smsRelayRequest.DeviceName,
+		},
+		PhoneNumber: WebhookPhoneNumber{
+			ID:          smsRelayRequest.PhoneNumber.ID,
+			PhoneNumber: smsRelayRequest.PhoneNumber.PhoneNumber,
+			Name:        smsRelayRequest.PhoneNumber.Name,
+		},
+		SMS: WebhookSMS{
+			ID:         smsRelayRequest.SMS.ID,
+			From:       smsRelayRequest.SMS.From,
+			Body:       smsRelayRequest.SMS.Body,
+			ReceivedAt: smsRelayRequest.SMS.ReceivedAt,
+			CreatedAt:  smsRelayRequest.SMS.CreatedAt,
+		},
+	}
+}
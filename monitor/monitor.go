@@ -0,0 +1,248 @@
+// Package monitor reports devices that stop sending heartbeats to the forward destinations of their
+// phone numbers, so that SMS are not silently lost while a device is offline, and reports their
+// recovery once they send heartbeats again.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/forwarder"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+const (
+	// DefaultOfflineAfter is how long a device may miss heartbeats before it is reported offline.
+	DefaultOfflineAfter = time.Minute * 15
+
+	alertSender = "SMS Relay" // Sender of the alerts, shown by the forwarders in place of a phone number
+)
+
+var logger = log.Default()
+
+// alertSMSIDNamespace is the namespace of the name-based UUIDs of alert SMS.
+var alertSMSIDNamespace = uuid.MustParse("20d4764a-55c3-46f8-bce0-e9319d8bced8")
+
+// Store provides the repositories used to check devices.
+type Store interface {
+	store.DeviceRepository
+	store.PhoneNumberRepository
+}
+
+// Checker checks the heartbeats of all devices. It is meant to be run periodically, at an interval
+// shorter than OfflineAfter.
+type Checker struct {
+	Store        Store
+	Registry     *forwarder.Registry
+	OfflineAfter time.Duration // Defaults to DefaultOfflineAfter
+}
+
+// NewCheckerFromEnv returns a checker whose OfflineAfter is read from the DEVICE_OFFLINE_AFTER
+// environment variable, a duration such as "30m", defaulting to DefaultOfflineAfter.
+func NewCheckerFromEnv(st Store, registry *forwarder.Registry) (*Checker, error) {
+	offlineAfter := DefaultOfflineAfter
+	if value := os.Getenv("DEVICE_OFFLINE_AFTER"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid DEVICE_OFFLINE_AFTER %q", value)
+		}
+		offlineAfter = parsed
+	}
+	return &Checker{
+		Store:        st,
+		Registry:     registry,
+		OfflineAfter: offlineAfter,
+	}, nil
+}
+
+// Run checks the devices every interval until the context is done.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Check(ctx); err != nil {
+				logger.Printf("failed to check devices: %v", err)
+			}
+		}
+	}
+}
+
+// Check alerts of the devices that went offline or recovered since the previous check. Devices
+// that never sent a heartbeat are not monitored.
+func (c *Checker) Check(ctx context.Context) error {
+	devices, err := c.Store.ListDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, device := range devices {
+		if err := c.checkDevice(ctx, device, now); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", device.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Checker) checkDevice(ctx context.Context, device models.Device, now time.Time) error {
+	if device.LastSeenAt == "" {
+		return nil
+	}
+	lastSeen, err := time.Parse(models.TimestampFormat, device.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("invalid last seen timestamp: %w", err)
+	}
+	offlineAfter := c.OfflineAfter
+	if offlineAfter <= 0 {
+		offlineAfter = DefaultOfflineAfter
+	}
+	offline := now.Sub(lastSeen) > offlineAfter
+
+	// Claim each transition before alerting, so that overlapping checks alert only once, and revert
+	// it if the alert could not be delivered so that the next check retries it. The episode
+	// identifies the outage across those retries: a retried offline alert claims a new
+	// OfflineAlertedAt, but the device was still last seen at the same time.
+	var expected, alertedAt, alert, episode string
+	switch {
+	case offline && device.OfflineAlertedAt == "":
+		expected, alertedAt, alert, episode = "", models.FormatTimestamp(now), models.DeviceAlertOffline, device.LastSeenAt
+		logger.Printf("device %s is offline, last seen at %s", device.ID, device.LastSeenAt)
+	case !offline && device.OfflineAlertedAt != "":
+		expected, alertedAt, alert, episode = device.OfflineAlertedAt, "", models.DeviceAlertRecovered, device.OfflineAlertedAt
+		logger.Printf("device %s is back online, last seen at %s", device.ID, device.LastSeenAt)
+	default:
+		return nil
+	}
+	claimed, err := c.Store.SetDeviceOfflineAlertedAt(ctx, device.ID, expected, alertedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update offline alert: %w", err)
+	}
+	if !claimed {
+		return nil // Another check got there first
+	}
+	if err := c.alert(ctx, device, alert, episode, lastSeen, now); err != nil {
+		if _, revertErr := c.Store.SetDeviceOfflineAlertedAt(ctx, device.ID, alertedAt, expected); revertErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to revert offline alert: %w", revertErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// alert forwards the alert to the destinations of every phone number of the device. It fails only
+// if no destination received it, as retrying would duplicate the alert elsewhere.
+func (c *Checker) alert(
+	ctx context.Context, device models.Device, alert string, episode string, lastSeen time.Time, now time.Time,
+) error {
+	var errs []error
+	delivered := false
+	for _, phoneNumberID := range device.PhoneNumberIDs {
+		phoneNumber, err := c.Store.GetPhoneNumberByID(ctx, phoneNumberID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get phone number %s: %w", phoneNumberID, err))
+			continue
+		}
+		if phoneNumber == nil {
+			continue
+		}
+
+		smsRelayRequest := models.SMSRelayRequest{
+			Device:      device,
+			DeviceName:  deviceName(device),
+			PhoneNumber: *phoneNumber,
+			SMS: models.SMS{
+				ID:            alertSMSID(device.ID, phoneNumber.ID, alert, episode),
+				From:          alertSender,
+				Body:          alertBody(device, *phoneNumber, alert, lastSeen, now),
+				PhoneNumberID: phoneNumber.ID,
+				ReceivedAt:    models.FormatTimestamp(now),
+				CreatedAt:     models.FormatTimestamp(now),
+			},
+			Alert: alert,
+		}
+		results := c.Registry.ForwardSMS(ctx, smsRelayRequest, nil)
+		for _, result := range results {
+			if result.Err == nil {
+				delivered = true
+			}
+		}
+		if err := forwarder.JoinErrors(results); err != nil {
+			logger.Printf("failed to forward %s alert of device %s for phone number %s: %v",
+				alert, device.ID, phoneNumber.ID, err)
+			errs = append(errs, err)
+		}
+	}
+	if delivered {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// alertSMSID derives the ID of the SMS of an alert, so that an alert retried by a later check keeps
+// its ID and forwarders deduplicating by SMS ID, such as Matrix and email, deliver it only once.
+func alertSMSID(deviceID string, phoneNumberID string, alert string, episode string) string {
+	name := strings.Join([]string{deviceID, phoneNumberID, alert, episode}, "\n")
+	return uuid.NewSHA1(alertSMSIDNamespace, []byte(name)).String()
+}
+
+// deviceName returns the displayed name of the device, falling back to its ID for unnamed devices.
+func deviceName(device models.Device) string {
+	if device.Name != "" {
+		return device.Name
+	}
+	return device.ID
+}
+
+// alertBody returns the text of an alert about the device for one of its phone numbers.
+func alertBody(device models.Device, phoneNumber models.PhoneNumber, alert string, lastSeen time.Time, now time.Time) string {
+	var body strings.Builder
+	if alert == models.DeviceAlertOffline {
+		fmt.Fprintf(&body, "Device %s has not sent a heartbeat for %s and may be offline. "+
+			"SMS to %s are not relayed until it is back online.",
+			deviceName(device), now.Sub(lastSeen).Round(time.Minute), phoneNumber.PhoneNumber)
+	} else {
+		fmt.Fprintf(&body, "Device %s is back online and relays SMS to %s again.",
+			deviceName(device), phoneNumber.PhoneNumber)
+	}
+	if status := formatStatus(device.Status); status != "" {
+		body.WriteString("\nLast status: " + status)
+	}
+	return body.String()
+}
+
+// formatStatus describes the reported status of a device, e.g. "battery 80% (charging), signal 3/4".
+func formatStatus(status *models.DeviceStatus) string {
+	if status == nil {
+		return ""
+	}
+	var parts []string
+	if status.BatteryLevel != nil {
+		battery := fmt.Sprintf("battery %d%%", *status.BatteryLevel)
+		if status.Charging {
+			battery += " (charging)"
+		}
+		parts = append(parts, battery)
+	}
+	if status.SignalLevel != nil {
+		parts = append(parts, fmt.Sprintf("signal %d/4", *status.SignalLevel))
+	}
+	if status.SIMState != "" {
+		parts = append(parts, "SIM "+strings.ReplaceAll(status.SIMState, "_", " "))
+	}
+	if status.AppVersion != "" {
+		parts = append(parts, "app "+status.AppVersion)
+	}
+	return strings.Join(parts, ", ")
+}
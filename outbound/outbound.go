@@ -0,0 +1,127 @@
+// Package outbound queues SMS composed by users on the command queues of the devices sending them.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	"github.com/zhouziqunzzq/sms-relay-server/store"
+)
+
+// MaxBodyLength is the longest SMS body that can be sent, 10 concatenated SMS segments.
+const MaxBodyLength = 1530
+
+var (
+	ErrNotAttached = errors.New("phone number is not attached to a device")
+	ErrInvalidTo   = errors.New("recipient must be a phone number in E.164 format")
+	ErrInvalidBody = errors.New("body must not be empty")
+	ErrBodyTooLong = errors.New("body is too long")
+)
+
+// Store provides the repositories used to queue outbound SMS.
+type Store interface {
+	store.DeviceRepository
+	store.OutboundSMSRepository
+	store.DeviceCommandRepository
+}
+
+// Request describes an SMS to send from a phone number.
+type Request struct {
+	PhoneNumber *models.PhoneNumber // Phone number to send from
+	To          string              // Phone number of the recipient, in E.164 format
+	Body        string              // Content of the SMS message
+	UserID      string              // ID of the user composing the SMS, if any
+	EmailFrom   string              // Email address of the reply the SMS was composed from, if any
+}
+
+// Validate checks the recipient and body of the request.
+func (r *Request) Validate() error {
+	if !models.IsE164(r.To) {
+		return ErrInvalidTo
+	}
+	if r.Body == "" {
+		return ErrInvalidBody
+	}
+	if utf8.RuneCountInString(r.Body) > MaxBodyLength {
+		return ErrBodyTooLong
+	}
+	return nil
+}
+
+// QueueSMS stores an outbound SMS and queues the command sending it on the device the phone number
+// is attached to. It returns ErrNotAttached if there is no such device.
+func QueueSMS(ctx context.Context, st Store, req Request) (*models.OutboundSMS, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	device, err := getAttachedDevice(ctx, st, req.PhoneNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device of phone number: %w", err)
+	}
+	if device == nil {
+		return nil, ErrNotAttached
+	}
+
+	// Persist the SMS, then queue the command sending it
+	now := models.FormatTimestamp(time.Now())
+	outboundSMS := models.OutboundSMS{
+		ID:            uuid.NewString(),
+		PhoneNumberID: req.PhoneNumber.ID,
+		DeviceID:      device.ID,
+		UserID:        req.UserID,
+		EmailFrom:     req.EmailFrom,
+		To:            req.To,
+		Body:          req.Body,
+		Status:        models.OutboundSMSStatusQueued,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := st.PutOutboundSMS(ctx, &outboundSMS); err != nil {
+		return nil, fmt.Errorf("failed to save outbound SMS: %w", err)
+	}
+	command := models.DeviceCommand{
+		ID:       uuid.NewString(),
+		DeviceID: device.ID,
+		Type:     models.DeviceCommandTypeSendSMS,
+		SendSMS: &models.SendSMSCommand{
+			OutboundSMSID: outboundSMS.ID,
+			PhoneNumber:   req.PhoneNumber.PhoneNumber,
+			To:            outboundSMS.To,
+			Body:          outboundSMS.Body,
+		},
+		CreatedAt: now,
+	}
+	if err := st.PutDeviceCommand(ctx, &command); err != nil {
+		// Don't leave the SMS queued forever
+		if updateErr := st.UpdateOutboundSMSStatus(ctx, outboundSMS.ID,
+			models.OutboundSMSStatusFailed, "failed to queue the SMS for the device"); updateErr != nil {
+			err = errors.Join(err, updateErr)
+		}
+		return nil, fmt.Errorf("failed to queue command for device %s: %w", device.ID, err)
+	}
+
+	return &outboundSMS, nil
+}
+
+// getAttachedDevice returns the device the phone number is attached to, or nil if it is not attached
+// to an existing device that lists it among its phone numbers.
+func getAttachedDevice(ctx context.Context, st Store, phoneNumber *models.PhoneNumber) (*models.Device, error) {
+	if phoneNumber.DeviceID == "" {
+		return nil, nil
+	}
+	device, err := st.GetDeviceByID(ctx, phoneNumber.DeviceID)
+	if err != nil || device == nil {
+		return nil, err
+	}
+	for _, id := range device.PhoneNumberIDs {
+		if id == phoneNumber.ID {
+			return device, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryMessage struct {
+	id           string
+	body         string
+	receiveCount int
+	visibleAt    time.Time
+}
+
+// MemoryQueue is an in-process queue. Its messages are lost when the process exits.
+type MemoryQueue struct {
+	VisibilityTimeout time.Duration
+	WaitTime          time.Duration
+	RedrivePolicy     *RedrivePolicy // Optional, messages are redelivered forever without one
+
+	mu       sync.Mutex
+	messages []*memoryMessage // In publishing order
+	notify   chan struct{}    // Signaled when a message is published
+}
+
+var (
+	_ Publisher = (*MemoryQueue)(nil)
+	_ Consumer  = (*MemoryQueue)(nil)
+)
+
+func NewMemoryQueue(redrivePolicy *RedrivePolicy) *MemoryQueue {
+	return &MemoryQueue{
+		VisibilityTimeout: DefaultVisibilityTimeout,
+		WaitTime:          DefaultWaitTime,
+		RedrivePolicy:     redrivePolicy,
+		notify:            make(chan struct{}, 1),
+	}
+}
+
+func (q *MemoryQueue) Publish(ctx context.Context, body string) error {
+	q.mu.Lock()
+	q.messages = append(q.messages, &memoryMessage{
+		id:        uuid.NewString(),
+		body:      body,
+		visibleAt: time.Now(),
+	})
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len returns the number of messages in the queue, including the ones currently received.
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+func (q *MemoryQueue) Receive(ctx context.Context, maxMessages int) ([]Message, error) {
+	deadline := time.NewTimer(q.WaitTime)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pollInterval) // Wakes up to pick up messages whose visibility timeout expired
+	defer ticker.Stop()
+
+	for {
+		messages, err := q.receiveVisible(ctx, maxMessages)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, nil
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// receiveVisible receives up to maxMessages visible messages without waiting, dead-lettering the
+// messages that exceeded the maximum receive count on the way.
+func (q *MemoryQueue) receiveVisible(ctx context.Context, maxMessages int) ([]Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var received []Message
+	kept := q.messages[:0]
+	for _, m := range q.messages {
+		if len(received) >= maxMessages || m.visibleAt.After(now) {
+			kept = append(kept, m)
+			continue
+		}
+		if q.RedrivePolicy != nil && m.receiveCount >= q.RedrivePolicy.MaxReceiveCount {
+			if err := q.RedrivePolicy.deadLetter(ctx, m.id, m.body); err != nil {
+				logger.Printf("failed to move message %s to the dead-letter queue: %v", m.id, err)
+				kept = append(kept, m)
+			}
+			continue
+		}
+
+		m.receiveCount++
+		m.visibleAt = now.Add(q.VisibilityTimeout)
+		received = append(received, Message{
+			ID:            m.id,
+			Body:          m.body,
+			ReceiveCount:  m.receiveCount,
+			ReceiptHandle: m.id + ":" + strconv.Itoa(m.receiveCount),
+		})
+		kept = append(kept, m)
+	}
+	clear(q.messages[len(kept):])
+	q.messages = kept
+
+	return received, nil
+}
+
+func (q *MemoryQueue) Delete(ctx context.Context, message Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, m := range q.messages {
+		if m.id+":"+strconv.Itoa(m.receiveCount) == message.ReceiptHandle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("message not found or received again since")
+}
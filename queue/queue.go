@@ -0,0 +1,100 @@
+// Package queue carries SMS relay requests from the API to the forwarder. Publishers enqueue
+// messages and consumers receive them with at-least-once semantics modeled on SQS: a received
+// message is hidden for a visibility timeout and redelivered unless it is deleted, and a message
+// received too many times is moved to a dead-letter queue.
+package queue
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultVisibilityTimeout = time.Second * 30 // Same as the SQS default
+	DefaultMaxReceiveCount   = 5                // Same as the redrive policy of SMSRelayRequestQueue
+	DefaultWaitTime          = time.Second * 20 // Same as the SQS maximum long polling wait time
+	DefaultMaxMessages       = 10               // Same as the BatchSize of the forwarder event source mapping
+
+	pollInterval = time.Millisecond * 500
+)
+
+var logger = log.Default()
+
+// Message is a received message.
+type Message struct {
+	ID            string // ID assigned to the message when it was published
+	Body          string // Body of the message
+	ReceiveCount  int    // Number of times the message has been received, including this time
+	ReceiptHandle string // Handle identifying this receive of the message, used to delete it
+}
+
+type Publisher interface {
+	// Publish enqueues a message with the given body.
+	Publish(ctx context.Context, body string) error
+}
+
+type Consumer interface {
+	// Receive waits up to the consumer's wait time for messages, and returns at most maxMessages
+	// of them. It returns no messages if none became available in time.
+	Receive(ctx context.Context, maxMessages int) ([]Message, error)
+	// Delete removes a received message from the queue so that it is not redelivered.
+	Delete(ctx context.Context, message Message) error
+}
+
+// RedrivePolicy moves messages that were received MaxReceiveCount times without being deleted to
+// a dead-letter queue, like the redrive policy of an SQS queue.
+type RedrivePolicy struct {
+	MaxReceiveCount int
+	// DeadLetterQueue receives the bodies of the moved messages. If nil, they are logged and dropped.
+	DeadLetterQueue Publisher
+}
+
+// deadLetter moves the body of a message out of its queue according to the policy.
+func (p *RedrivePolicy) deadLetter(ctx context.Context, id string, body string) error {
+	if p.DeadLetterQueue == nil {
+		logger.Printf("dropping message %s after %d receives: %s", id, p.MaxReceiveCount, body)
+		return nil
+	}
+	logger.Printf("moving message %s to the dead-letter queue after %d receives", id, p.MaxReceiveCount)
+	return p.DeadLetterQueue.Publish(ctx, body)
+}
+
+// HandlerFunc processes a received message. If it returns an error, the message is left in the
+// queue to be redelivered after its visibility timeout.
+type HandlerFunc func(ctx context.Context, message Message) error
+
+// Consume receives messages from the consumer and handles each batch concurrently until ctx is
+// done. Successfully handled messages are deleted. Handlers are not canceled on shutdown, so
+// that a message is never interrupted midway.
+func Consume(ctx context.Context, consumer Consumer, handler HandlerFunc) {
+	handlerCtx := context.WithoutCancel(ctx)
+	for ctx.Err() == nil {
+		messages, err := consumer.Receive(ctx, DefaultMaxMessages)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Printf("failed to receive messages: %v", err)
+				time.Sleep(pollInterval)
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, message := range messages {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := handler(handlerCtx, message); err != nil {
+					logger.Printf("failed to handle message %s (receive count %d): %v",
+						message.ID, message.ReceiveCount, err)
+					return
+				}
+				if err := consumer.Delete(handlerCtx, message); err != nil {
+					logger.Printf("failed to delete message %s: %v", message.ID, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
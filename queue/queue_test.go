@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testVisibilityTimeout = time.Millisecond * 50
+
+type testQueue interface {
+	Publisher
+	Consumer
+}
+
+// queueFactories create the queue implementations under test, with a short visibility timeout and
+// no wait time, so that Receive returns right away.
+var queueFactories = []struct {
+	name string
+	open func(t *testing.T, redrivePolicy *RedrivePolicy) testQueue
+}{
+	{
+		name: "memory",
+		open: func(t *testing.T, redrivePolicy *RedrivePolicy) testQueue {
+			q := NewMemoryQueue(redrivePolicy)
+			q.VisibilityTimeout = testVisibilityTimeout
+			q.WaitTime = 0
+			return q
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(t *testing.T, redrivePolicy *RedrivePolicy) testQueue {
+			q, err := OpenSQLiteQueue(filepath.Join(t.TempDir(), "queue.db"), "test", redrivePolicy)
+			if err != nil {
+				t.Fatalf("OpenSQLiteQueue: %v", err)
+			}
+			t.Cleanup(func() { q.Close() })
+			q.VisibilityTimeout = testVisibilityTimeout
+			q.WaitTime = 0
+			return q
+		},
+	},
+}
+
+// receiveOne receives from the queue and checks that it returned exactly one message.
+func receiveOne(t *testing.T, q Consumer) Message {
+	t.Helper()
+	messages, err := q.Receive(context.Background(), DefaultMaxMessages)
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	return messages[0]
+}
+
+// receiveNone receives from the queue and checks that it returned no message.
+func receiveNone(t *testing.T, q Consumer) {
+	t.Helper()
+	messages, err := q.Receive(context.Background(), DefaultMaxMessages)
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("got %d messages, want none", len(messages))
+	}
+}
+
+func TestRedeliveryAfterVisibilityTimeout(t *testing.T) {
+	for _, factory := range queueFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			q := factory.open(t, nil)
+			if err := q.Publish(context.Background(), "hello"); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			first := receiveOne(t, q)
+			if first.Body != "hello" || first.ReceiveCount != 1 {
+				t.Errorf("got body %q, receive count %d, want %q, 1", first.Body, first.ReceiveCount, "hello")
+			}
+			receiveNone(t, q) // Hidden until the visibility timeout expires
+
+			time.Sleep(testVisibilityTimeout * 2)
+			second := receiveOne(t, q)
+			if second.ID != first.ID || second.ReceiveCount != 2 {
+				t.Errorf("got message %s, receive count %d, want %s, 2", second.ID, second.ReceiveCount, first.ID)
+			}
+			if err := q.Delete(context.Background(), first); err == nil {
+				t.Error("deleted the message with the receipt handle of an earlier receive")
+			}
+		})
+	}
+}
+
+func TestNoRedeliveryAfterDelete(t *testing.T) {
+	for _, factory := range queueFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			q := factory.open(t, nil)
+			if err := q.Publish(context.Background(), "hello"); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			message := receiveOne(t, q)
+			if err := q.Delete(context.Background(), message); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			time.Sleep(testVisibilityTimeout * 2)
+			receiveNone(t, q)
+		})
+	}
+}
+
+func TestDeadLetterAfterMaxReceiveCount(t *testing.T) {
+	const maxReceiveCount = 2
+	for _, factory := range queueFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			deadLetterQueue := NewMemoryQueue(nil)
+			q := factory.open(t, &RedrivePolicy{MaxReceiveCount: maxReceiveCount, DeadLetterQueue: deadLetterQueue})
+			if err := q.Publish(context.Background(), "poison"); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			for i := 1; i <= maxReceiveCount; i++ {
+				if message := receiveOne(t, q); message.ReceiveCount != i {
+					t.Errorf("got receive count %d, want %d", message.ReceiveCount, i)
+				}
+				time.Sleep(testVisibilityTimeout * 2)
+			}
+			receiveNone(t, q)
+
+			if n := deadLetterQueue.Len(); n != 1 {
+				t.Fatalf("got %d dead-lettered messages, want 1", n)
+			}
+			deadLetterQueue.WaitTime = 0
+			if message := receiveOne(t, deadLetterQueue); message.Body != "poison" {
+				t.Errorf("got dead-lettered body %q, want %q", message.Body, "poison")
+			}
+		})
+	}
+}
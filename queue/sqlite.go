@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite" // Registers the pure Go "sqlite" driver
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS queue_messages (
+	id            TEXT PRIMARY KEY,
+	queue         TEXT NOT NULL,
+	body          TEXT NOT NULL,
+	receive_count INTEGER NOT NULL DEFAULT 0,
+	visible_at    INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS queue_messages_queue_index ON queue_messages (queue, visible_at);
+`
+
+// SQLiteQueue is a durable queue stored in a SQLite database, so that queued messages survive
+// restarts of the standalone server. Several named queues can share a database, e.g. a queue and
+// its dead-letter queue.
+type SQLiteQueue struct {
+	Name              string
+	VisibilityTimeout time.Duration
+	WaitTime          time.Duration
+	RedrivePolicy     *RedrivePolicy // Optional, messages are redelivered forever without one
+
+	db *sql.DB
+}
+
+var (
+	_ Publisher = (*SQLiteQueue)(nil)
+	_ Consumer  = (*SQLiteQueue)(nil)
+)
+
+// OpenSQLiteQueue opens the queue with the given name in the SQLite database at path, creating
+// the database if needed.
+func OpenSQLiteQueue(path string, name string, redrivePolicy *RedrivePolicy) (*SQLiteQueue, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time, so serialize all access through one connection
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set %q: %w", pragma, err)
+		}
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &SQLiteQueue{
+		Name:              name,
+		VisibilityTimeout: DefaultVisibilityTimeout,
+		WaitTime:          DefaultWaitTime,
+		RedrivePolicy:     redrivePolicy,
+		db:                db,
+	}, nil
+}
+
+func (q *SQLiteQueue) Close() error {
+	return q.db.Close()
+}
+
+func (q *SQLiteQueue) Publish(ctx context.Context, body string) error {
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO queue_messages (id, queue, body, visible_at) VALUES (?, ?, ?, ?)`,
+		uuid.NewString(), q.Name, body, time.Now().UnixMilli())
+	return err
+}
+
+func (q *SQLiteQueue) Receive(ctx context.Context, maxMessages int) ([]Message, error) {
+	deadline := time.Now().Add(q.WaitTime)
+	for {
+		messages, err := q.receiveVisible(ctx, maxMessages)
+		if err != nil || len(messages) > 0 || !time.Now().Before(deadline) {
+			return messages, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// receiveVisible receives up to maxMessages visible messages without waiting, dead-lettering the
+// messages that exceeded the maximum receive count on the way.
+func (q *SQLiteQueue) receiveVisible(ctx context.Context, maxMessages int) ([]Message, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, body, receive_count FROM queue_messages
+		WHERE queue = ? AND visible_at <= ? ORDER BY rowid LIMIT ?`,
+		q.Name, now.UnixMilli(), maxMessages)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.Body, &m.ReceiveCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var received, deadLettered []Message
+	for _, m := range candidates {
+		if q.RedrivePolicy != nil && m.ReceiveCount >= q.RedrivePolicy.MaxReceiveCount {
+			deadLettered = append(deadLettered, m)
+			continue
+		}
+		m.ReceiveCount++
+		m.ReceiptHandle = m.ID + ":" + strconv.Itoa(m.ReceiveCount)
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE queue_messages SET receive_count = ?, visible_at = ? WHERE id = ?`,
+			m.ReceiveCount, now.Add(q.VisibilityTimeout).UnixMilli(), m.ID,
+		); err != nil {
+			return nil, err
+		}
+		received = append(received, m)
+	}
+	for _, m := range deadLettered {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM queue_messages WHERE id = ?`, m.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Move the messages to the dead-letter queue once they are out of this queue. Should that
+	// fail, the message is lost rather than redelivered forever.
+	for _, m := range deadLettered {
+		if err := q.RedrivePolicy.deadLetter(ctx, m.ID, m.Body); err != nil {
+			logger.Printf("failed to move message %s to the dead-letter queue: %v", m.ID, err)
+		}
+	}
+
+	return received, nil
+}
+
+func (q *SQLiteQueue) Delete(ctx context.Context, message Message) error {
+	id, receiveCount, ok := strings.Cut(message.ReceiptHandle, ":")
+	if !ok {
+		return fmt.Errorf("invalid receipt handle %q", message.ReceiptHandle)
+	}
+	result, err := q.db.ExecContext(ctx,
+		`DELETE FROM queue_messages WHERE id = ? AND queue = ? AND receive_count = ?`, id, q.Name, receiveCount)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("message %s not found or received again since", id)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQSQueue publishes to and consumes from an SQS queue. Visibility timeouts and dead-lettering
+// are configured on the queue itself.
+type SQSQueue struct {
+	Client   *sqs.Client
+	QueueURL string
+}
+
+var (
+	_ Publisher = (*SQSQueue)(nil)
+	_ Consumer  = (*SQSQueue)(nil)
+)
+
+func NewSQSQueue(client *sqs.Client, queueURL string) *SQSQueue {
+	return &SQSQueue{Client: client, QueueURL: queueURL}
+}
+
+func (q *SQSQueue) Publish(ctx context.Context, body string) error {
+	_, err := q.Client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.QueueURL),
+		MessageBody: aws.String(body),
+	})
+	return err
+}
+
+func (q *SQSQueue) Receive(ctx context.Context, maxMessages int) ([]Message, error) {
+	result, err := q.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.QueueURL),
+		MaxNumberOfMessages: int32(maxMessages),
+		WaitTimeSeconds:     int32(DefaultWaitTime.Seconds()),
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+			types.MessageSystemAttributeNameApproximateReceiveCount,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(result.Messages))
+	for i, m := range result.Messages {
+		receiveCount, _ := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+		messages[i] = Message{
+			ID:            aws.ToString(m.MessageId),
+			Body:          aws.ToString(m.Body),
+			ReceiveCount:  receiveCount,
+			ReceiptHandle: aws.ToString(m.ReceiptHandle),
+		}
+	}
+	return messages, nil
+}
+
+func (q *SQSQueue) Delete(ctx context.Context, message Message) error {
+	_, err := q.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.QueueURL),
+		ReceiptHandle: aws.String(message.ReceiptHandle),
+	})
+	return err
+}
@@ -0,0 +1,34 @@
+package response
+
+// Error codes of the problem responses. They are part of the API: existing codes must not be
+// renamed, and clients should treat unknown codes like the generic code of the status.
+const (
+	// Generic codes, one per status code
+	CodeInvalidRequest     = "invalid_request"     // 400, e.g. a missing or out of range parameter
+	CodeUnauthorized       = "unauthorized"        // 401, missing or invalid credentials
+	CodeForbidden          = "forbidden"           // 403, e.g. a device calling a user route
+	CodeNotFound           = "not_found"           // 404
+	CodeMethodNotAllowed   = "method_not_allowed"  // 405
+	CodeConflict           = "conflict"            // 409
+	CodePayloadTooLarge    = "payload_too_large"   // 413
+	CodeUnprocessable      = "unprocessable"       // 422
+	CodeInternalError      = "internal_error"      // 500
+	CodeBadGateway         = "bad_gateway"         // 502
+	CodeServiceUnavailable = "service_unavailable" // 503
+
+	// Specific codes
+	CodeInvalidBody             = "invalid_body"              // 400, the body isn't valid JSON of the expected shape
+	CodeInvalidCursor           = "invalid_cursor"            // 400, the pagination cursor is malformed
+	CodeInvalidCredentials      = "invalid_credentials"       // 401, wrong username or password
+	CodeInvalidPairingCode      = "invalid_pairing_code"      // 404, the pairing code is unknown, used or expired
+	CodeInvalidToken            = "invalid_token"             // 403, the bearer token is malformed, expired or revoked
+	CodeInvalidRefreshToken     = "invalid_refresh_token"     // 401, the refresh token is unknown, expired or revoked
+	CodeRefreshTokenReused      = "refresh_token_reused"      // 401, the refresh token was already used, its sessions are revoked
+	CodePhoneNumberTaken        = "phone_number_taken"        // 409, the phone number is already registered
+	CodePhoneNumberAttached     = "phone_number_attached"     // 409, the phone number is attached to a device
+	CodePhoneNumberNotAttached  = "phone_number_not_attached" // 409, the phone number isn't attached to a device
+	CodeDeviceHasPhoneNumbers   = "device_has_phone_numbers"  // 409, the device still has phone numbers attached
+	CodeConcurrentModification  = "concurrent_modification"   // 409, the resource changed meanwhile, retry
+	CodeRequestInProgress       = "request_in_progress"       // 409, a request with the same idempotency key is in progress
+	CodeInvalidStatusTransition = "invalid_status_transition" // 409, e.g. reporting an outbound SMS that is already final
+)
@@ -0,0 +1,110 @@
+// Package response builds the API Gateway proxy responses of the API. Errors are JSON problem
+// details in the style of RFC 7807, carrying a stable machine-readable code that clients can branch
+// on, a human-readable detail and the ID of the request to correlate with the logs, e.g.
+//
+//	{"title":"Not Found","status":404,"code":"not_found","detail":"Device not found","request_id":"..."}
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	contentTypeHeader = "Content-Type"
+	contentTypeJSON   = "application/json"
+)
+
+var logger = log.Default()
+
+// Problem is the body of error responses.
+type Problem struct {
+	Title     string `json:"title"`  // Text of the status code, e.g. "Not Found"
+	Status    int    `json:"status"` // HTTP status code
+	Code      string `json:"code"`   // Stable error code, one of the Code constants
+	Detail    string `json:"detail"` // Human-readable explanation, which may change between versions
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request, which is included in the error
+// responses built with it.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the ID of the request carried by the context, or an empty string if none is.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// NewProblem returns the problem details of an error response, with the request ID of the context.
+func NewProblem(ctx context.Context, statusCode int, code string, detail string) Problem {
+	return Problem{
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Code:      code,
+		Detail:    detail,
+		RequestID: RequestID(ctx),
+	}
+}
+
+// Error returns an error response with the given status code, error code and detail.
+func Error(ctx context.Context, statusCode int, code string, detail string) events.APIGatewayProxyResponse {
+	problem := NewProblem(ctx, statusCode, code, detail)
+	body, err := json.Marshal(problem)
+	if err != nil {
+		// Unreachable as the problem only holds strings and an int
+		logger.Printf("failed to marshal problem: %v", err)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{contentTypeHeader: contentTypeJSON},
+		Body:       string(body),
+	}
+}
+
+// InternalServerError returns a 500 response, for unexpected errors whose details are only logged.
+func InternalServerError(ctx context.Context) events.APIGatewayProxyResponse {
+	return Error(ctx, http.StatusInternalServerError, CodeInternalError, "Internal Server Error")
+}
+
+// InvalidBody returns a 400 response for request bodies that aren't valid JSON of the expected
+// shape.
+func InvalidBody(ctx context.Context) events.APIGatewayProxyResponse {
+	return Error(ctx, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+}
+
+// JSON returns a response with the given status code and v marshalled as its body, or a 500 if v
+// can't be marshalled.
+func JSON(ctx context.Context, statusCode int, v any) events.APIGatewayProxyResponse {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logger.Printf("failed to marshal response: %v", err)
+		return InternalServerError(ctx)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{contentTypeHeader: contentTypeJSON},
+		Body:       string(body),
+	}
+}
+
+// WriteError writes an error response to an HTTP response, for the routes served over net/http
+// directly.
+func WriteError(w http.ResponseWriter, r *http.Request, statusCode int, code string, detail string) {
+	resp := Error(r.Context(), statusCode, code, detail)
+	for name, value := range resp.Headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write([]byte(resp.Body)); err != nil {
+		logger.Printf("failed to write response body: %v", err)
+	}
+}
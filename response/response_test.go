@@ -0,0 +1,94 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorWireFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		resp     func(ctx context.Context) string
+		wantBody string
+	}{
+		{
+			name:     "error",
+			ctx:      WithRequestID(context.Background(), "request-1"),
+			resp:     func(ctx context.Context) string { return Error(ctx, 404, CodeNotFound, "Device not found").Body },
+			wantBody: `{"title":"Not Found","status":404,"code":"not_found","detail":"Device not found","request_id":"request-1"}`,
+		},
+		{
+			name: "without request ID",
+			ctx:  context.Background(),
+			resp: func(ctx context.Context) string {
+				return Error(ctx, 409, CodePhoneNumberTaken, "Phone number already exists").Body
+			},
+			wantBody: `{"title":"Conflict","status":409,"code":"phone_number_taken","detail":"Phone number already exists"}`,
+		},
+		{
+			name:     "internal server error",
+			ctx:      WithRequestID(context.Background(), "request-2"),
+			resp:     func(ctx context.Context) string { return InternalServerError(ctx).Body },
+			wantBody: `{"title":"Internal Server Error","status":500,"code":"internal_error","detail":"Internal Server Error","request_id":"request-2"}`,
+		},
+		{
+			name:     "invalid body",
+			ctx:      WithRequestID(context.Background(), "request-3"),
+			resp:     func(ctx context.Context) string { return InvalidBody(ctx).Body },
+			wantBody: `{"title":"Bad Request","status":400,"code":"invalid_body","detail":"Invalid request body","request_id":"request-3"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if body := tt.resp(tt.ctx); body != tt.wantBody {
+				t.Errorf("got %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+
+	resp := Error(context.Background(), 405, CodeMethodNotAllowed, "Method Not Allowed")
+	if resp.StatusCode != 405 || resp.Headers["Content-Type"] != "application/json" {
+		t.Errorf("got %d with Content-Type %q, want 405 application/json", resp.StatusCode, resp.Headers["Content-Type"])
+	}
+}
+
+func TestJSON(t *testing.T) {
+	resp := JSON(context.Background(), 201, map[string]string{"id": "device-1"})
+	if resp.StatusCode != 201 || resp.Headers["Content-Type"] != "application/json" || resp.Body != `{"id":"device-1"}` {
+		t.Errorf("got %d %v %s", resp.StatusCode, resp.Headers, resp.Body)
+	}
+
+	// Values that can't be marshalled are internal errors rather than empty bodies
+	resp = JSON(WithRequestID(context.Background(), "request-1"), 200, func() {})
+	if resp.StatusCode != 500 || resp.Body != `{"title":"Internal Server Error","status":500,"code":"internal_error",`+
+		`"detail":"Internal Server Error","request_id":"request-1"}` {
+		t.Errorf("got %d %s, want the internal error problem", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/devices/device-1", nil)
+	r = r.WithContext(WithRequestID(r.Context(), "request-1"))
+
+	WriteError(w, r, http.StatusForbidden, CodeForbidden, "Only users can manage devices")
+	if w.Code != 403 || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d with Content-Type %q, want 403 application/json", w.Code, w.Header().Get("Content-Type"))
+	}
+	want := `{"title":"Forbidden","status":403,"code":"forbidden","detail":"Only users can manage devices","request_id":"request-1"}`
+	if w.Body.String() != want {
+		t.Errorf("got %s, want %s", w.Body.String(), want)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("got request ID %q from an empty context", id)
+	}
+	if id := RequestID(WithRequestID(context.Background(), "request-1")); id != "request-1" {
+		t.Errorf("got request ID %q, want request-1", id)
+	}
+}
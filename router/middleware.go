@@ -0,0 +1,43 @@
+package router
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+// Recoverer responds with a 500 to requests whose handler panics, logging the panic, instead of
+// crashing the Lambda or the server.
+func Recoverer(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (
+		resp events.APIGatewayProxyResponse, err error,
+	) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.Printf("panic serving %s %s: %v\n%s", request.HTTPMethod, request.Path, recovered, debug.Stack())
+				resp = response.InternalServerError(ctx)
+				err = nil
+			}
+		}()
+		return next(ctx, request)
+	}
+}
+
+// RequestLogger logs the method, path, status and duration of each request along with its request
+// ID, after the request is served.
+func RequestLogger(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		resp, err := next(ctx, request)
+		status := resp.StatusCode
+		if err != nil {
+			status = 502 // As API Gateway responds to handler errors
+		}
+		logger.Printf("%s %s %d %s request_id=%s", request.HTTPMethod, request.Path, status,
+			time.Since(start).Round(time.Millisecond), request.RequestContext.RequestID)
+		return resp, err
+	}
+}
@@ -0,0 +1,178 @@
+// Package router routes API Gateway proxy requests to handlers by method and path pattern, so that
+// the same routes can be served by a Lambda behind an AWS_PROXY integration and over net/http.
+package router
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/httpadapter"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+var logger = log.Default()
+
+// HandlerFunc handles an API Gateway proxy request. It has the signature of Lambda handlers and of
+// httpadapter.ProxyHandlerFunc.
+type HandlerFunc = httpadapter.ProxyHandlerFunc
+
+// Middleware wraps a handler, e.g. to reject unauthenticated requests before calling it.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps handler with the middlewares, the first one being the outermost.
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for _, middleware := range slices.Backward(middlewares) {
+		handler = middleware(handler)
+	}
+	return handler
+}
+
+type route struct {
+	method   string
+	segments []string // Segments of the pattern, parameters being enclosed in braces
+	handler  HandlerFunc
+}
+
+// Router routes requests to the handler of the route matching their method and path. Patterns are
+// paths whose segments may be parameters like "{id}", matching any non-empty segment, e.g.
+// "/devices/{id}". The values of the parameters are set in the request's PathParameters.
+//
+// When several patterns match a path, literal segments take precedence over parameters, from left
+// to right, so "/devices/pair" is preferred over "/devices/{id}".
+type Router struct {
+	routes      []route
+	middlewares []Middleware
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Use adds middlewares wrapping every request, including those that match no route.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Handle registers the handler of requests with the given method and path pattern, wrapped with the
+// route-specific middlewares. It panics if the route is already registered. Routes must all be
+// registered before requests are served.
+func (r *Router) Handle(method string, pattern string, handler HandlerFunc, middlewares ...Middleware) {
+	segments := splitPath(pattern)
+	for _, existing := range r.routes {
+		if existing.method == method && slices.Equal(existing.segments, segments) {
+			panic("route " + method + " " + pattern + " registered twice")
+		}
+	}
+	r.routes = append(r.routes, route{
+		method:   method,
+		segments: segments,
+		handler:  Chain(handler, middlewares...),
+	})
+}
+
+// Serve routes the request. It responds with a 404 if no pattern matches the path, and with a 405
+// listing the allowed methods in the Allow header if the path matches but not the method. The
+// context passed to the handlers carries the request ID for the error responses.
+func (r *Router) Serve(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx = response.WithRequestID(ctx, request.RequestContext.RequestID)
+	return Chain(r.dispatch, r.middlewares...)(ctx, request)
+}
+
+// ServeHTTP serves the routes over net/http through httpadapter.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	httpadapter.Handler(r.Serve).ServeHTTP(w, req)
+}
+
+func (r *Router) dispatch(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pathSegments := splitPath(request.Path)
+
+	// Find the most specific pattern matching the path, and the routes with that pattern
+	var best []string
+	var candidates []route
+	for _, rt := range r.routes {
+		if !match(rt.segments, pathSegments) {
+			continue
+		}
+		switch {
+		case best == nil || moreSpecific(rt.segments, best):
+			best = rt.segments
+			candidates = []route{rt}
+		case slices.Equal(rt.segments, best):
+			candidates = append(candidates, rt)
+		}
+	}
+	if len(candidates) == 0 {
+		return response.Error(ctx, 404, response.CodeNotFound, "Not Found"), nil
+	}
+
+	var allowed []string
+	for _, rt := range candidates {
+		if rt.method == request.HTTPMethod {
+			request.PathParameters = pathParameters(request.PathParameters, rt.segments, pathSegments)
+			return rt.handler(ctx, request)
+		}
+		allowed = append(allowed, rt.method)
+	}
+	slices.Sort(allowed)
+	resp := response.Error(ctx, 405, response.CodeMethodNotAllowed, "Method Not Allowed")
+	resp.Headers["Allow"] = strings.Join(allowed, ", ")
+	return resp, nil
+}
+
+// splitPath splits a path or pattern into its segments, ignoring a trailing slash.
+func splitPath(path string) []string {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func isParameter(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+func match(patternSegments []string, pathSegments []string) bool {
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if isParameter(segment) {
+			if pathSegments[i] == "" {
+				return false
+			}
+		} else if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moreSpecific reports whether pattern a is preferred over pattern b, both matching the same path.
+func moreSpecific(a []string, b []string) bool {
+	for i := range a {
+		if aParam, bParam := isParameter(a[i]), isParameter(b[i]); aParam != bParam {
+			return !aParam
+		}
+	}
+	return false
+}
+
+// pathParameters adds the values of the pattern's parameters to the existing parameters, e.g. the
+// "proxy" parameter of API Gateway proxy resources.
+func pathParameters(existing map[string]string, patternSegments []string, pathSegments []string) map[string]string {
+	params := make(map[string]string, len(existing)+len(patternSegments))
+	for name, value := range existing {
+		params[name] = value
+	}
+	for i, segment := range patternSegments {
+		if isParameter(segment) {
+			params[segment[1:len(segment)-1]] = pathSegments[i]
+		}
+	}
+	return params
+}
@@ -0,0 +1,238 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/zhouziqunzzq/sms-relay-server/response"
+)
+
+// named returns a handler responding with its name and the path parameters of the request.
+func named(name string) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return response.JSON(ctx, 200, map[string]any{"handler": name, "params": request.PathParameters}), nil
+	}
+}
+
+// decodeProblem decodes the problem of an error response.
+func decodeProblem(t *testing.T, resp events.APIGatewayProxyResponse) response.Problem {
+	t.Helper()
+	var problem response.Problem
+	if err := json.Unmarshal([]byte(resp.Body), &problem); err != nil {
+		t.Fatalf("failed to unmarshal problem %q: %v", resp.Body, err)
+	}
+	return problem
+}
+
+func TestRouterServe(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/devices", named("list devices"))
+	r.Handle("POST", "/devices", named("create device"))
+	r.Handle("GET", "/devices/{id}", named("get device"))
+	r.Handle("DELETE", "/devices/{id}", named("delete device"))
+	r.Handle("POST", "/devices/pair", named("pair device"))
+	r.Handle("PUT", "/devices/{id}/phone-numbers/{phoneNumberID}", named("attach phone number"))
+	r.Handle("GET", "/", named("root"))
+
+	tests := []struct {
+		method      string
+		path        string
+		wantStatus  int
+		wantHandler string
+		wantParams  map[string]string
+		wantAllow   string
+	}{
+		{method: "GET", path: "/devices", wantStatus: 200, wantHandler: "list devices"},
+		{method: "GET", path: "/devices/", wantStatus: 200, wantHandler: "list devices"},
+		{method: "POST", path: "/devices", wantStatus: 200, wantHandler: "create device"},
+		{method: "GET", path: "/", wantStatus: 200, wantHandler: "root"},
+		{method: "GET", path: "/devices/device-1", wantStatus: 200, wantHandler: "get device",
+			wantParams: map[string]string{"id": "device-1"}},
+		{method: "PUT", path: "/devices/device-1/phone-numbers/phone-number-1", wantStatus: 200,
+			wantHandler: "attach phone number", wantParams: map[string]string{"id": "device-1", "phoneNumberID": "phone-number-1"}},
+		// Literal segments take precedence over parameters
+		{method: "POST", path: "/devices/pair", wantStatus: 200, wantHandler: "pair device"},
+		{method: "GET", path: "/devices/pair", wantStatus: 405, wantAllow: "POST"},
+		{method: "PATCH", path: "/devices", wantStatus: 405, wantAllow: "GET, POST"},
+		{method: "PUT", path: "/devices/device-1", wantStatus: 405, wantAllow: "DELETE, GET"},
+		{method: "GET", path: "/phone-numbers", wantStatus: 404},
+		{method: "GET", path: "/devices/device-1/phone-numbers", wantStatus: 404},
+		{method: "GET", path: "/devices//phone-numbers/phone-number-1", wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: tt.path})
+			if err != nil {
+				t.Fatalf("Serve: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("got %d %s, want %d", resp.StatusCode, resp.Body, tt.wantStatus)
+			}
+
+			switch tt.wantStatus {
+			case 200:
+				var body struct {
+					Handler string            `json:"handler"`
+					Params  map[string]string `json:"params"`
+				}
+				if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+					t.Fatalf("failed to unmarshal body: %v", err)
+				}
+				if body.Handler != tt.wantHandler {
+					t.Errorf("got handler %q, want %q", body.Handler, tt.wantHandler)
+				}
+				if len(body.Params) != len(tt.wantParams) || !maps.Equal(body.Params, tt.wantParams) {
+					t.Errorf("got params %v, want %v", body.Params, tt.wantParams)
+				}
+			case 404:
+				if code := decodeProblem(t, resp).Code; code != response.CodeNotFound {
+					t.Errorf("got code %s, want %s", code, response.CodeNotFound)
+				}
+			case 405:
+				if code := decodeProblem(t, resp).Code; code != response.CodeMethodNotAllowed {
+					t.Errorf("got code %s, want %s", code, response.CodeMethodNotAllowed)
+				}
+				if allow := resp.Headers["Allow"]; allow != tt.wantAllow {
+					t.Errorf("got Allow %q, want %q", allow, tt.wantAllow)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterKeepsExistingPathParameters(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/devices/{id}", named("get device"))
+
+	resp, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		Path:           "/devices/device-1",
+		PathParameters: map[string]string{"proxy": "devices/device-1"},
+	})
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	want := `{"handler":"get device","params":{"id":"device-1","proxy":"devices/device-1"}}`
+	if resp.Body != want {
+		t.Errorf("got %s, want %s", resp.Body, want)
+	}
+}
+
+func TestRouterHandlePanicsOnDuplicateRoute(t *testing.T) {
+	r := New()
+	r.Handle("GET", "/devices/{id}", named("get device"))
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a route twice didn't panic")
+		}
+	}()
+	r.Handle("GET", "/devices/{id}/", named("get device again"))
+}
+
+// recording returns a middleware appending its name to calls when a request enters and leaves it.
+func recording(name string, calls *[]string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			*calls = append(*calls, "enter "+name)
+			resp, err := next(ctx, request)
+			*calls = append(*calls, "leave "+name)
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	r := New()
+	r.Use(recording("first", &calls), recording("second", &calls))
+	r.Handle("GET", "/devices", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		calls = append(calls, "handler")
+		return response.JSON(ctx, 200, nil), nil
+	}, recording("route", &calls))
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/devices", []string{"enter first", "enter second", "enter route", "handler", "leave route", "leave second", "leave first"}},
+		// Router middlewares wrap unmatched requests too, unlike route middlewares
+		{"/phone-numbers", []string{"enter first", "enter second", "leave second", "leave first"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		if _, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: tt.path}); err != nil {
+			t.Fatalf("Serve: %v", err)
+		}
+		if !slices.Equal(calls, tt.want) {
+			t.Errorf("%s: got calls %v, want %v", tt.path, calls, tt.want)
+		}
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	r := New()
+	r.Use(Recoverer)
+	r.Handle("GET", "/panic", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		panic("boom")
+	})
+
+	resp, err := r.Serve(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		Path:           "/panic",
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "request-1"},
+	})
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if resp.StatusCode != 500 || resp.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("got %d with Content-Type %q, want a JSON 500", resp.StatusCode, resp.Headers["Content-Type"])
+	}
+	problem := decodeProblem(t, resp)
+	if problem.Code != response.CodeInternalError || problem.RequestID != "request-1" {
+		t.Errorf("got code %s, request ID %q, want %s, request-1", problem.Code, problem.RequestID, response.CodeInternalError)
+	}
+	if strings.Contains(resp.Body, "boom") {
+		t.Errorf("response %s leaks the panic", resp.Body)
+	}
+}
+
+func TestRouterServeHTTP(t *testing.T) {
+	r := New()
+	r.Use(Recoverer)
+	r.Handle("GET", "/devices/{id}", named("get device"))
+	r.Handle("GET", "/panic", func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		panic("boom")
+	})
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"GET", "/devices/device-1", http.StatusOK, ""},
+		{"POST", "/devices/device-1", http.StatusMethodNotAllowed, "GET"},
+		{"GET", "/missing", http.StatusNotFound, ""},
+		{"GET", "/panic", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, w.Code, tt.wantStatus)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.wantAllow {
+			t.Errorf("%s %s: got Allow %q, want %q", tt.method, tt.path, allow, tt.wantAllow)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("%s %s: got Content-Type %q, want application/json", tt.method, tt.path, contentType)
+		}
+	}
+}
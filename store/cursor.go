@@ -0,0 +1,45 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// smsKeysetCursor is the pagination cursor of the SQLite and memory stores. It holds the sort key
+// of the last SMS of a page, so that the next page starts right after it even if newer SMS were
+// added in the meantime.
+type smsKeysetCursor struct {
+	CreatedAt string `json:"created_at"`
+	ID        string `json:"id"`
+}
+
+func encodeSMSKeysetCursor(last models.SMS) string {
+	cursorJSON, _ := json.Marshal(smsKeysetCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+// decodeSMSKeysetCursor is the inverse of encodeSMSKeysetCursor. An empty cursor decodes to nil.
+func decodeSMSKeysetCursor(cursor string) (*smsKeysetCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c smsKeysetCursor
+	if err := json.Unmarshal(cursorJSON, &c); err != nil || c.CreatedAt == "" || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// before reports whether the SMS sorts after the cursor in newest-first order.
+func (c *smsKeysetCursor) before(sms models.SMS) bool {
+	if sms.CreatedAt != c.CreatedAt {
+		return sms.CreatedAt < c.CreatedAt
+	}
+	return sms.ID < c.ID
+}
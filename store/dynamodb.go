@@ -0,0 +1,1436 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+const (
+	userTableName        = "UserTable"
+	usernameIndexName    = "UsernameIndex"
+	deviceTableName      = "DeviceTable"
+	phoneNumberTableName = "PhoneNumberTable"
+	phoneNumberIndexName = "PhoneNumberIndex"
+
+	phoneNumberReservationTableName = "PhoneNumberReservationTable"
+
+	smsTableName              = "SMSTable"
+	smsPhoneNumberIDIndexName = "PhoneNumberIDIndex"
+
+	aclTableName       = "ACLTable"
+	aclUserIDIndexName = "UserIDIndex"
+
+	idempotencyTableName = "IdempotencyTable"
+
+	outboundSMSTableName = "OutboundSMSTable"
+
+	deviceCommandTableName         = "DeviceCommandTable"
+	deviceCommandDeviceIDIndexName = "DeviceIDIndex"
+
+	pairingCodeTableName = "PairingCodeTable"
+
+	refreshTokenTableName       = "RefreshTokenTable"
+	refreshTokenUserIDIndexName = "UserIDIndex"
+	revokedTokenTableName       = "RevokedTokenTable"
+)
+
+// DynamoDBStore stores models in the DynamoDB tables defined in the CloudFormation template.
+type DynamoDBStore struct {
+	Client *dynamodb.Client
+}
+
+var _ Store = (*DynamoDBStore)(nil)
+
+func NewDynamoDBStore(client *dynamodb.Client) *DynamoDBStore {
+	return &DynamoDBStore{Client: client}
+}
+
+func (s *DynamoDBStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(userTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: userID},
+		},
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // User not found
+	}
+
+	var user models.User
+	if err := attributevalue.UnmarshalMap(result.Item, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *DynamoDBStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(userTableName),
+		IndexName:              aws.String(usernameIndexName),
+		KeyConditionExpression: aws.String("Username = :username"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":username": &types.AttributeValueMemberS{Value: username},
+		},
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, nil // User not found
+	}
+
+	var user models.User
+	if err := attributevalue.UnmarshalMap(result.Items[0], &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *DynamoDBStore) GetDeviceByID(ctx context.Context, deviceID string) (*models.Device, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(deviceTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Device not found
+	}
+
+	var device models.Device
+	if err := attributevalue.UnmarshalMap(result.Item, &device); err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+func (s *DynamoDBStore) ListDevices(ctx context.Context) ([]models.Device, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(deviceTableName),
+	}
+
+	var devices []models.Device
+	paginator := dynamodb.NewScanPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.Device
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, err
+		}
+		devices = append(devices, page...)
+	}
+
+	return devices, nil
+}
+
+func (s *DynamoDBStore) UpdateDeviceHeartbeat(
+	ctx context.Context, deviceID string, status models.DeviceStatus, lastSeenAt string,
+) error {
+	statusValue, err := attributevalue.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(deviceTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+		UpdateExpression:    aws.String("SET #status = :status, LastSeenAt = :lastSeenAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status", // Status is a DynamoDB reserved word
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":     statusValue,
+			":lastSeenAt": &types.AttributeValueMemberS{Value: lastSeenAt},
+		},
+	}
+
+	_, err = s.Client.UpdateItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) CreateDevice(
+	ctx context.Context, device *models.Device, user *models.User, acl *models.ACL,
+) error {
+	deviceItem, err := attributevalue.MarshalMap(device)
+	if err != nil {
+		return err
+	}
+	userItem, err := attributevalue.MarshalMap(user)
+	if err != nil {
+		return err
+	}
+	aclItem, err := attributevalue.MarshalMap(acl)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(deviceTableName),
+					Item:                deviceItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(userTableName),
+					Item:                userItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(aclTableName),
+					Item:                aclItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+		},
+	}
+
+	_, err = s.Client.TransactWriteItems(ctx, input)
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) UpdateDeviceName(ctx context.Context, deviceID string, name string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(deviceTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+		UpdateExpression:    aws.String("SET #name = :name, UpdatedAt = :updatedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#name": "Name", // Name is a DynamoDB reserved word
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name":      &types.AttributeValueMemberS{Value: name},
+			":updatedAt": &types.AttributeValueMemberS{Value: models.FormatTimestamp(time.Now())},
+		},
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) DeleteDevice(ctx context.Context, deviceID string) error {
+	// Find the device users and ACL entries to delete along with the device
+	userDeletes, err := s.scanDeletes(ctx, userTableName, "DeviceID", deviceID)
+	if err != nil {
+		return err
+	}
+	aclDeletes, err := s.scanDeletes(ctx, aclTableName, "DeviceID", deviceID)
+	if err != nil {
+		return err
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Delete: &types.Delete{
+				TableName: aws.String(deviceTableName),
+				Key: map[string]types.AttributeValue{
+					"ID": &types.AttributeValueMemberS{Value: deviceID},
+				},
+				ConditionExpression: aws.String("attribute_exists(ID) AND (attribute_not_exists(PhoneNumberIDs) OR " +
+					"attribute_type(PhoneNumberIDs, :null) OR size(PhoneNumberIDs) = :zero)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":null": &types.AttributeValueMemberS{Value: "NULL"},
+					":zero": &types.AttributeValueMemberN{Value: "0"},
+				},
+			},
+		},
+	}
+	transactItems = append(transactItems, userDeletes...)
+	transactItems = append(transactItems, aclDeletes...)
+
+	_, err = s.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	return conflictError(err)
+}
+
+// scanDeletes returns the transaction items deleting the items of the table whose attribute has
+// the given value. Tables are scanned as they have no index on the attributes referencing devices
+// and phone numbers, which is fine for the few items they hold.
+func (s *DynamoDBStore) scanDeletes(
+	ctx context.Context, tableName string, attribute string, value string,
+) ([]types.TransactWriteItem, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(tableName),
+		FilterExpression:     aws.String("#attribute = :value"),
+		ProjectionExpression: aws.String("ID"),
+		ExpressionAttributeNames: map[string]string{
+			"#attribute": attribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberS{Value: value},
+		},
+	}
+
+	var deletes []types.TransactWriteItem
+	paginator := dynamodb.NewScanPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range result.Items {
+			deletes = append(deletes, types.TransactWriteItem{
+				Delete: &types.Delete{
+					TableName: aws.String(tableName),
+					Key:       key,
+				},
+			})
+		}
+	}
+
+	return deletes, nil
+}
+
+// getDeviceAndPhoneNumber reads a device and a phone number for linking them, returning
+// ErrConflict if either doesn't exist.
+func (s *DynamoDBStore) getDeviceAndPhoneNumber(
+	ctx context.Context, deviceID string, phoneNumberID string,
+) (*models.Device, *models.PhoneNumber, error) {
+	device, err := s.GetDeviceByID(ctx, deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	phoneNumber, err := s.GetPhoneNumberByID(ctx, phoneNumberID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if device == nil || phoneNumber == nil {
+		return nil, nil, ErrConflict
+	}
+	return device, phoneNumber, nil
+}
+
+// updateDevicePhoneNumberIDs returns the transaction item setting the PhoneNumberIDs of a device,
+// provided that it wasn't updated since it was read.
+func updateDevicePhoneNumberIDs(device *models.Device, phoneNumberIDs []string, updatedAt string) (*types.Update, error) {
+	if phoneNumberIDs == nil {
+		phoneNumberIDs = []string{} // Not NULL, which DeleteDevice would have to tell apart
+	}
+	phoneNumberIDsValue, err := attributevalue.Marshal(phoneNumberIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Update{
+		TableName: aws.String(deviceTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: device.ID},
+		},
+		ConditionExpression: aws.String(
+			"attribute_exists(ID) AND (attribute_not_exists(UpdatedAt) OR UpdatedAt = :expected)"),
+		UpdateExpression: aws.String("SET PhoneNumberIDs = :phoneNumberIDs, UpdatedAt = :updatedAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expected":       &types.AttributeValueMemberS{Value: device.UpdatedAt},
+			":phoneNumberIDs": phoneNumberIDsValue,
+			":updatedAt":      &types.AttributeValueMemberS{Value: updatedAt},
+		},
+	}, nil
+}
+
+func (s *DynamoDBStore) AttachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error {
+	device, phoneNumber, err := s.getDeviceAndPhoneNumber(ctx, deviceID, phoneNumberID)
+	if err != nil {
+		return err
+	}
+	if phoneNumber.DeviceID != "" && phoneNumber.DeviceID != deviceID {
+		return ErrConflict
+	}
+
+	updatedAt := models.FormatTimestamp(time.Now())
+	phoneNumberIDs := device.PhoneNumberIDs
+	if !slices.Contains(phoneNumberIDs, phoneNumberID) {
+		phoneNumberIDs = append(phoneNumberIDs, phoneNumberID)
+	}
+	deviceUpdate, err := updateDevicePhoneNumberIDs(device, phoneNumberIDs, updatedAt)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: deviceUpdate},
+			{
+				Update: &types.Update{
+					TableName: aws.String(phoneNumberTableName),
+					Key: map[string]types.AttributeValue{
+						"ID": &types.AttributeValueMemberS{Value: phoneNumberID},
+					},
+					ConditionExpression: aws.String("attribute_exists(ID) AND " +
+						"(attribute_not_exists(DeviceID) OR DeviceID = :empty OR DeviceID = :deviceID)"),
+					UpdateExpression: aws.String("SET DeviceID = :deviceID, UpdatedAt = :updatedAt"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":empty":     &types.AttributeValueMemberS{Value: ""},
+						":deviceID":  &types.AttributeValueMemberS{Value: deviceID},
+						":updatedAt": &types.AttributeValueMemberS{Value: updatedAt},
+					},
+				},
+			},
+		},
+	}
+
+	_, err = s.Client.TransactWriteItems(ctx, input)
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) DetachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error {
+	device, phoneNumber, err := s.getDeviceAndPhoneNumber(ctx, deviceID, phoneNumberID)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := models.FormatTimestamp(time.Now())
+	phoneNumberIDs := slices.DeleteFunc(slices.Clone(device.PhoneNumberIDs), func(id string) bool {
+		return id == phoneNumberID
+	})
+	deviceUpdate, err := updateDevicePhoneNumberIDs(device, phoneNumberIDs, updatedAt)
+	if err != nil {
+		return err
+	}
+	transactItems := []types.TransactWriteItem{{Update: deviceUpdate}}
+	if phoneNumber.DeviceID == deviceID {
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(phoneNumberTableName),
+				Key: map[string]types.AttributeValue{
+					"ID": &types.AttributeValueMemberS{Value: phoneNumberID},
+				},
+				ConditionExpression: aws.String("DeviceID = :deviceID"),
+				UpdateExpression:    aws.String("SET DeviceID = :empty, UpdatedAt = :updatedAt"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":empty":     &types.AttributeValueMemberS{Value: ""},
+					":deviceID":  &types.AttributeValueMemberS{Value: deviceID},
+					":updatedAt": &types.AttributeValueMemberS{Value: updatedAt},
+				},
+			},
+		})
+	}
+
+	_, err = s.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) SetDeviceOfflineAlertedAt(
+	ctx context.Context, deviceID string, expected string, alertedAt string,
+) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(deviceTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{},
+	}
+	if expected == "" {
+		input.ConditionExpression = aws.String(
+			"attribute_exists(ID) AND (attribute_not_exists(OfflineAlertedAt) OR OfflineAlertedAt = :expected)")
+	} else {
+		input.ConditionExpression = aws.String("OfflineAlertedAt = :expected")
+	}
+	input.ExpressionAttributeValues[":expected"] = &types.AttributeValueMemberS{Value: expected}
+	if alertedAt == "" {
+		input.UpdateExpression = aws.String("REMOVE OfflineAlertedAt")
+	} else {
+		input.UpdateExpression = aws.String("SET OfflineAlertedAt = :alertedAt")
+		input.ExpressionAttributeValues[":alertedAt"] = &types.AttributeValueMemberS{Value: alertedAt}
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *DynamoDBStore) GetPhoneNumberByID(ctx context.Context, phoneNumberID string) (*models.PhoneNumber, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(phoneNumberTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: phoneNumberID},
+		},
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Phone number not found
+	}
+
+	var phoneNumber models.PhoneNumber
+	if err := attributevalue.UnmarshalMap(result.Item, &phoneNumber); err != nil {
+		return nil, err
+	}
+
+	return &phoneNumber, nil
+}
+
+func (s *DynamoDBStore) GetPhoneNumberByPhoneNumber(ctx context.Context, number string) (*models.PhoneNumber, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(phoneNumberTableName),
+		IndexName:              aws.String(phoneNumberIndexName),
+		KeyConditionExpression: aws.String("PhoneNumber = :phoneNumber"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":phoneNumber": &types.AttributeValueMemberS{Value: number},
+		},
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, nil // Phone number not found
+	}
+
+	var phoneNumber models.PhoneNumber
+	if err := attributevalue.UnmarshalMap(result.Items[0], &phoneNumber); err != nil {
+		return nil, err
+	}
+
+	return &phoneNumber, nil
+}
+
+// phoneNumberTaken reports whether a phone number other than the one with the given ID has the
+// number. As PhoneNumberIndex is eventually consistent, this only catches the numbers of phone
+// numbers written before they were reserved with putPhoneNumberReservation; the reservations
+// enforce uniqueness within the transactions of concurrent writes.
+func (s *DynamoDBStore) phoneNumberTaken(ctx context.Context, phoneNumberID string, number string) (bool, error) {
+	existing, err := s.GetPhoneNumberByPhoneNumber(ctx, number)
+	if err != nil {
+		return false, err
+	}
+	return existing != nil && existing.ID != phoneNumberID, nil
+}
+
+// phoneNumberReservationKey returns the key of the item of PhoneNumberReservationTable reserving a
+// number for a phone number. Reservations are kept out of PhoneNumberTable, so that reading or
+// scanning phone numbers never returns them.
+func phoneNumberReservationKey(number string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PhoneNumber": &types.AttributeValueMemberS{Value: number},
+	}
+}
+
+// putPhoneNumberReservation returns the transaction item reserving the number for the phone
+// number, which fails if another phone number reserved it.
+func putPhoneNumberReservation(phoneNumberID string, number string) types.TransactWriteItem {
+	item := phoneNumberReservationKey(number)
+	item["PhoneNumberID"] = &types.AttributeValueMemberS{Value: phoneNumberID}
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(phoneNumberReservationTableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(PhoneNumber) OR PhoneNumberID = :phoneNumberID"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":phoneNumberID": &types.AttributeValueMemberS{Value: phoneNumberID},
+			},
+		},
+	}
+}
+
+// deletePhoneNumberReservation returns the transaction item releasing the number reserved for the
+// phone number, leaving the reservation of another phone number, if any, in place.
+func deletePhoneNumberReservation(phoneNumberID string, number string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName:           aws.String(phoneNumberReservationTableName),
+			Key:                 phoneNumberReservationKey(number),
+			ConditionExpression: aws.String("attribute_not_exists(PhoneNumber) OR PhoneNumberID = :phoneNumberID"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":phoneNumberID": &types.AttributeValueMemberS{Value: phoneNumberID},
+			},
+		},
+	}
+}
+
+// phoneNumberConflictError returns ErrPhoneNumberTaken if err is a transaction canceled because the
+// reservation at index reservationIndex of its items failed its condition, or conflictError(err).
+func phoneNumberConflictError(err error, reservationIndex int) error {
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) && reservationIndex < len(canceled.CancellationReasons) &&
+		aws.ToString(canceled.CancellationReasons[reservationIndex].Code) == "ConditionalCheckFailed" {
+		return ErrPhoneNumberTaken
+	}
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) CreatePhoneNumber(ctx context.Context, phoneNumber *models.PhoneNumber, acl *models.ACL) error {
+	if taken, err := s.phoneNumberTaken(ctx, phoneNumber.ID, phoneNumber.PhoneNumber); err != nil {
+		return err
+	} else if taken {
+		return ErrPhoneNumberTaken
+	}
+
+	phoneNumberItem, err := attributevalue.MarshalMap(phoneNumber)
+	if err != nil {
+		return err
+	}
+	aclItem, err := attributevalue.MarshalMap(acl)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(phoneNumberTableName),
+					Item:                phoneNumberItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+			putPhoneNumberReservation(phoneNumber.ID, phoneNumber.PhoneNumber),
+			{
+				Put: &types.Put{
+					TableName:           aws.String(aclTableName),
+					Item:                aclItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+		},
+	}
+
+	_, err = s.Client.TransactWriteItems(ctx, input)
+	return phoneNumberConflictError(err, 1)
+}
+
+func (s *DynamoDBStore) UpdatePhoneNumber(
+	ctx context.Context, phoneNumber *models.PhoneNumber, expectedUpdatedAt string,
+) error {
+	if taken, err := s.phoneNumberTaken(ctx, phoneNumber.ID, phoneNumber.PhoneNumber); err != nil {
+		return err
+	} else if taken {
+		return ErrPhoneNumberTaken
+	}
+	// The number to release if it changes. Should it have changed since it was read, UpdatedAt no
+	// longer equals expectedUpdatedAt and the transaction fails.
+	existing, err := s.GetPhoneNumberByID(ctx, phoneNumber.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrConflict
+	}
+
+	forwardDestinations := phoneNumber.ForwardDestinations
+	if forwardDestinations == nil {
+		forwardDestinations = models.ForwardDestinations{}
+	}
+	forwardDestinationsValue, err := attributevalue.Marshal(forwardDestinations)
+	if err != nil {
+		return err
+	}
+	updatedAt := models.FormatTimestamp(time.Now())
+	transactItems := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String(phoneNumberTableName),
+				Key: map[string]types.AttributeValue{
+					"ID": &types.AttributeValueMemberS{Value: phoneNumber.ID},
+				},
+				ConditionExpression: aws.String(
+					"attribute_exists(ID) AND (attribute_not_exists(UpdatedAt) OR UpdatedAt = :expected)"),
+				UpdateExpression: aws.String("SET PhoneNumber = :phoneNumber, #name = :name, " +
+					"ForwardDestinations = :forwardDestinations, UpdatedAt = :updatedAt"),
+				ExpressionAttributeNames: map[string]string{
+					"#name": "Name", // Name is a DynamoDB reserved word
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":expected":            &types.AttributeValueMemberS{Value: expectedUpdatedAt},
+					":phoneNumber":         &types.AttributeValueMemberS{Value: phoneNumber.PhoneNumber},
+					":name":                &types.AttributeValueMemberS{Value: phoneNumber.Name},
+					":forwardDestinations": forwardDestinationsValue,
+					":updatedAt":           &types.AttributeValueMemberS{Value: updatedAt},
+				},
+			},
+		},
+		// Also reserves the number of a phone number created before numbers were reserved
+		putPhoneNumberReservation(phoneNumber.ID, phoneNumber.PhoneNumber),
+	}
+	if existing.PhoneNumber != phoneNumber.PhoneNumber {
+		transactItems = append(transactItems, deletePhoneNumberReservation(phoneNumber.ID, existing.PhoneNumber))
+	}
+
+	_, err = s.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	if err != nil {
+		return phoneNumberConflictError(err, 1)
+	}
+	phoneNumber.UpdatedAt = updatedAt
+	return nil
+}
+
+func (s *DynamoDBStore) DeletePhoneNumber(ctx context.Context, phoneNumberID string) error {
+	phoneNumber, err := s.GetPhoneNumberByID(ctx, phoneNumberID)
+	if err != nil {
+		return err
+	}
+	if phoneNumber == nil {
+		return ErrConflict
+	}
+	aclDeletes, err := s.scanDeletes(ctx, aclTableName, "PhoneNumberID", phoneNumberID)
+	if err != nil {
+		return err
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Delete: &types.Delete{
+				TableName: aws.String(phoneNumberTableName),
+				Key: map[string]types.AttributeValue{
+					"ID": &types.AttributeValueMemberS{Value: phoneNumberID},
+				},
+				// Also fails if the number changed since it was read, leaving its reservation
+				ConditionExpression: aws.String("attribute_exists(ID) AND PhoneNumber = :phoneNumber AND " +
+					"(attribute_not_exists(DeviceID) OR DeviceID = :empty)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":phoneNumber": &types.AttributeValueMemberS{Value: phoneNumber.PhoneNumber},
+					":empty":       &types.AttributeValueMemberS{Value: ""},
+				},
+			},
+		},
+		deletePhoneNumberReservation(phoneNumberID, phoneNumber.PhoneNumber),
+	}
+	transactItems = append(transactItems, aclDeletes...)
+
+	_, err = s.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) PutSMS(ctx context.Context, sms *models.SMS) error {
+	item, err := attributevalue.MarshalMap(sms)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(smsTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) ListSMSByPhoneNumberID(
+	ctx context.Context, phoneNumberID string, limit int, cursor string,
+) ([]models.SMS, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(smsTableName),
+		IndexName:              aws.String(smsPhoneNumberIDIndexName),
+		KeyConditionExpression: aws.String("PhoneNumberID = :phoneNumberID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":phoneNumberID": &types.AttributeValueMemberS{Value: phoneNumberID},
+		},
+		ScanIndexForward:  aws.Bool(false), // Newest first
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	smsList := make([]models.SMS, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &smsList); err != nil {
+		return nil, "", err
+	}
+	nextCursor, err := encodeCursor(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return smsList, nextCursor, nil
+}
+
+func (s *DynamoDBStore) PutOutboundSMS(ctx context.Context, outboundSMS *models.OutboundSMS) error {
+	item, err := attributevalue.MarshalMap(outboundSMS)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(outboundSMSTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) GetOutboundSMSByID(ctx context.Context, outboundSMSID string) (*models.OutboundSMS, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(outboundSMSTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: outboundSMSID},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Outbound SMS not found
+	}
+
+	var outboundSMS models.OutboundSMS
+	if err := attributevalue.UnmarshalMap(result.Item, &outboundSMS); err != nil {
+		return nil, err
+	}
+
+	return &outboundSMS, nil
+}
+
+func (s *DynamoDBStore) UpdateOutboundSMSStatus(
+	ctx context.Context, outboundSMSID string, status string, errorMessage string,
+) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(outboundSMSTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: outboundSMSID},
+		},
+		// Final statuses are never overwritten, even by concurrent reports
+		ConditionExpression: aws.String("attribute_exists(ID) AND NOT #status IN (:delivered, :failed)"),
+		UpdateExpression:    aws.String("SET #status = :status, #error = :error, UpdatedAt = :updatedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status", // Status is a DynamoDB reserved word
+			"#error":  "Error",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":    &types.AttributeValueMemberS{Value: status},
+			":error":     &types.AttributeValueMemberS{Value: errorMessage},
+			":updatedAt": &types.AttributeValueMemberS{Value: models.FormatTimestamp(time.Now())},
+			":delivered": &types.AttributeValueMemberS{Value: models.OutboundSMSStatusDelivered},
+			":failed":    &types.AttributeValueMemberS{Value: models.OutboundSMSStatusFailed},
+		},
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) PutDeviceCommand(ctx context.Context, command *models.DeviceCommand) error {
+	item, err := attributevalue.MarshalMap(command)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(deviceCommandTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]models.DeviceCommand, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(deviceCommandTableName),
+		IndexName:              aws.String(deviceCommandDeviceIDIndexName),
+		KeyConditionExpression: aws.String("DeviceID = :deviceID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":deviceID": &types.AttributeValueMemberS{Value: deviceID},
+		},
+		ScanIndexForward: aws.Bool(true), // Oldest first
+		Limit:            aws.Int32(int32(limit)),
+	}
+
+	result, err := s.Client.Query(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make([]models.DeviceCommand, 0, len(result.Items))
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &commands); err != nil {
+		return nil, err
+	}
+
+	return commands, nil
+}
+
+func (s *DynamoDBStore) DeleteDeviceCommand(ctx context.Context, deviceID string, commandID string) (bool, error) {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(deviceCommandTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: commandID},
+		},
+		ConditionExpression: aws.String("DeviceID = :deviceID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":deviceID": &types.AttributeValueMemberS{Value: deviceID},
+		},
+	}
+
+	_, err := s.Client.DeleteItem(ctx, input)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil // Command not found or queued for another device
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *DynamoDBStore) PutPairingCode(ctx context.Context, code *models.PairingCode) error {
+	item, err := attributevalue.MarshalMap(code)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(pairingCodeTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) GetPairingCode(ctx context.Context, id string) (*models.PairingCode, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(pairingCodeTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Pairing code not found
+	}
+
+	var code models.PairingCode
+	if err := attributevalue.UnmarshalMap(result.Item, &code); err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func (s *DynamoDBStore) CompletePairing(
+	ctx context.Context, codeID string, device *models.Device, user *models.User, acl *models.ACL,
+) (bool, error) {
+	deviceItem, err := attributevalue.MarshalMap(device)
+	if err != nil {
+		return false, err
+	}
+	userItem, err := attributevalue.MarshalMap(user)
+	if err != nil {
+		return false, err
+	}
+	aclItem, err := attributevalue.MarshalMap(acl)
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				// Consuming the code comes first, so that its cancellation reason tells invalid codes apart
+				Delete: &types.Delete{
+					TableName: aws.String(pairingCodeTableName),
+					Key: map[string]types.AttributeValue{
+						"ID": &types.AttributeValueMemberS{Value: codeID},
+					},
+					ConditionExpression: aws.String("attribute_exists(ID) AND ExpiresAt > :now"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+					},
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(deviceTableName),
+					Item:                deviceItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(userTableName),
+					Item:                userItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+			{
+				Put: &types.Put{
+					TableName:           aws.String(aclTableName),
+					Item:                aclItem,
+					ConditionExpression: aws.String("attribute_not_exists(ID)"),
+				},
+			},
+		},
+	}
+
+	_, err = s.Client.TransactWriteItems(ctx, input)
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *DynamoDBStore) ListACLsByUserID(ctx context.Context, userID string) ([]models.ACL, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(aclTableName),
+		IndexName:              aws.String(aclUserIDIndexName),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	}
+
+	var acls []models.ACL
+	paginator := dynamodb.NewQueryPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.ACL
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, err
+		}
+		acls = append(acls, page...)
+	}
+
+	return acls, nil
+}
+
+func (s *DynamoDBStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(idempotencyTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *DynamoDBStore) GetIdempotencyRecord(ctx context.Context, id string) (*models.IdempotencyRecord, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(idempotencyTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Record not found
+	}
+
+	var record models.IdempotencyRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (s *DynamoDBStore) CompleteIdempotencyRecord(
+	ctx context.Context, id string, statusCode int, responseHeaders map[string]string, responseBody string,
+) error {
+	headers, err := attributevalue.Marshal(responseHeaders)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(idempotencyTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression: aws.String("SET #status = :status, StatusCode = :statusCode, " +
+			"ResponseHeaders = :responseHeaders, ResponseBody = :responseBody"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status", // Status is a DynamoDB reserved word
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":          &types.AttributeValueMemberS{Value: models.IdempotencyStatusCompleted},
+			":statusCode":      &types.AttributeValueMemberN{Value: strconv.Itoa(statusCode)},
+			":responseHeaders": headers,
+			":responseBody":    &types.AttributeValueMemberS{Value: responseBody},
+		},
+	}
+
+	_, err = s.Client.UpdateItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) FailIdempotencyRecord(ctx context.Context, id string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(idempotencyTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("SET #status = :status"),
+		ConditionExpression: aws.String("attribute_exists(ID)"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: models.IdempotencyStatusFailed},
+		},
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	return conflictError(err)
+}
+
+func (s *DynamoDBStore) RetryIdempotencyRecord(ctx context.Context, id string) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(idempotencyTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression:    aws.String("SET #status = :pending"),
+		ConditionExpression: aws.String("#status = :failed"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: models.IdempotencyStatusPending},
+			":failed":  &types.AttributeValueMemberS{Value: models.IdempotencyStatusFailed},
+		},
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *DynamoDBStore) DeleteIdempotencyRecord(ctx context.Context, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(idempotencyTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+	}
+
+	_, err := s.Client.DeleteItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) AddForwardedDestinations(ctx context.Context, id string, indexes []int, expiresAt int64) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	indexSet := make([]string, len(indexes))
+	for i, index := range indexes {
+		indexSet[i] = strconv.Itoa(index)
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(idempotencyTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression: aws.String("ADD ForwardedDestinations :indexes " +
+			"SET CreatedAt = if_not_exists(CreatedAt, :createdAt), ExpiresAt = :expiresAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":indexes":   &types.AttributeValueMemberNS{Value: indexSet},
+			":createdAt": &types.AttributeValueMemberS{Value: models.FormatTimestamp(time.Now())},
+			":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)},
+		},
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) PutRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	item, err := attributevalue.MarshalMap(token)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(refreshTokenTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ID)"),
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(refreshTokenTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Refresh token not found
+	}
+
+	var token models.RefreshToken
+	if err := attributevalue.UnmarshalMap(result.Item, &token); err != nil {
+		return nil, err
+	}
+	// The TTL deletes expired items lazily
+	if token.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+
+	return &token, nil
+}
+
+func (s *DynamoDBStore) UseRefreshToken(ctx context.Context, id string, usedAt string) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(refreshTokenTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+		UpdateExpression: aws.String("SET UsedAt = :usedAt"),
+		ConditionExpression: aws.String(
+			"attribute_exists(ID) AND (attribute_not_exists(UsedAt) OR UsedAt = :empty) AND ExpiresAt > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":usedAt": &types.AttributeValueMemberS{Value: usedAt},
+			":empty":  &types.AttributeValueMemberS{Value: ""},
+			":now":    &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	}
+
+	_, err := s.Client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *DynamoDBStore) ListRefreshTokensByUserID(ctx context.Context, userID string) ([]models.RefreshToken, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(refreshTokenTableName),
+		IndexName:              aws.String(refreshTokenUserIDIndexName),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		FilterExpression:       aws.String("ExpiresAt > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+			":now":    &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	}
+
+	var tokens []models.RefreshToken
+	paginator := dynamodb.NewQueryPaginator(s.Client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.RefreshToken
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, page...)
+	}
+
+	return tokens, nil
+}
+
+func (s *DynamoDBStore) DeleteRefreshToken(ctx context.Context, id string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(refreshTokenTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+	}
+
+	_, err := s.Client.DeleteItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) PutRevokedToken(ctx context.Context, token *models.RevokedToken) error {
+	item, err := attributevalue.MarshalMap(token)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(revokedTokenTableName),
+		Item:      item,
+	}
+
+	_, err = s.Client.PutItem(ctx, input)
+	return err
+}
+
+func (s *DynamoDBStore) GetRevokedToken(ctx context.Context, id string) (*models.RevokedToken, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(revokedTokenTableName),
+		Key: map[string]types.AttributeValue{
+			"ID": &types.AttributeValueMemberS{Value: id},
+		},
+	}
+
+	result, err := s.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil // Token not revoked
+	}
+
+	var token models.RevokedToken
+	if err := attributevalue.UnmarshalMap(result.Item, &token); err != nil {
+		return nil, err
+	}
+	if token.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+
+	return &token, nil
+}
+
+// conflictError returns ErrConflict if err is a failed condition of a write, or of a transaction
+// which may also have conflicted with another one, or err otherwise.
+func conflictError(err error) error {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return ErrConflict
+	}
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			switch aws.ToString(reason.Code) {
+			case "ConditionalCheckFailed", "TransactionConflict":
+				return ErrConflict
+			}
+		}
+	}
+	return err
+}
+
+// encodeCursor encodes a DynamoDB LastEvaluatedKey as an opaque pagination cursor.
+// All key attributes of the tables and their indexes are strings.
+func encodeCursor(lastKey map[string]types.AttributeValue) (string, error) {
+	if len(lastKey) == 0 {
+		return "", nil
+	}
+	var keyMap map[string]string
+	if err := attributevalue.UnmarshalMap(lastKey, &keyMap); err != nil {
+		return "", err
+	}
+	keyJSON, err := json.Marshal(keyMap)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(keyJSON), nil
+}
+
+// decodeCursor is the inverse of encodeCursor. An empty cursor decodes to a nil key.
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	keyJSON, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var keyMap map[string]string
+	if err := json.Unmarshal(keyJSON, &keyMap); err != nil {
+		return nil, err
+	}
+	return attributevalue.MarshalMap(keyMap)
+}
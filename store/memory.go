@@ -0,0 +1,707 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// MemoryStore keeps models in memory. It is meant for tests and for trying out the standalone
+// server, as nothing is persisted.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users              map[string]models.User
+	devices            map[string]models.Device
+	phoneNumbers       map[string]models.PhoneNumber
+	sms                map[string]models.SMS
+	outboundSMS        map[string]models.OutboundSMS
+	deviceCommands     map[string]models.DeviceCommand
+	pairingCodes       map[string]models.PairingCode
+	acls               map[string]models.ACL
+	idempotencyRecords map[string]models.IdempotencyRecord
+	refreshTokens      map[string]models.RefreshToken
+	revokedTokens      map[string]models.RevokedToken
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:              make(map[string]models.User),
+		devices:            make(map[string]models.Device),
+		phoneNumbers:       make(map[string]models.PhoneNumber),
+		sms:                make(map[string]models.SMS),
+		outboundSMS:        make(map[string]models.OutboundSMS),
+		deviceCommands:     make(map[string]models.DeviceCommand),
+		pairingCodes:       make(map[string]models.PairingCode),
+		acls:               make(map[string]models.ACL),
+		idempotencyRecords: make(map[string]models.IdempotencyRecord),
+		refreshTokens:      make(map[string]models.RefreshToken),
+		revokedTokens:      make(map[string]models.RevokedToken),
+	}
+}
+
+// PutUser creates or replaces a user.
+func (s *MemoryStore) PutUser(user models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = user
+}
+
+// PutDevice creates or replaces a device.
+func (s *MemoryStore) PutDevice(device models.Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device.PhoneNumberIDs = slices.Clone(device.PhoneNumberIDs)
+	s.devices[device.ID] = device
+}
+
+// PutPhoneNumber creates or replaces a phone number.
+func (s *MemoryStore) PutPhoneNumber(phoneNumber models.PhoneNumber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	phoneNumber.ForwardDestinations = slices.Clone(phoneNumber.ForwardDestinations)
+	s.phoneNumbers[phoneNumber.ID] = phoneNumber
+}
+
+// PutACL creates or replaces an ACL entry.
+func (s *MemoryStore) PutACL(acl models.ACL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acls[acl.ID] = acl
+}
+
+func (s *MemoryStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, nil // User not found
+	}
+	return &user, nil
+}
+
+func (s *MemoryStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, nil // User not found
+}
+
+func (s *MemoryStore) GetDeviceByID(ctx context.Context, deviceID string) (*models.Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return nil, nil // Device not found
+	}
+	device.PhoneNumberIDs = slices.Clone(device.PhoneNumberIDs)
+	return &device, nil
+}
+
+func (s *MemoryStore) ListDevices(ctx context.Context) ([]models.Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	devices := make([]models.Device, 0, len(s.devices))
+	for _, device := range s.devices {
+		device.PhoneNumberIDs = slices.Clone(device.PhoneNumberIDs)
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+func (s *MemoryStore) UpdateDeviceHeartbeat(
+	ctx context.Context, deviceID string, status models.DeviceStatus, lastSeenAt string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return errors.New("device not found")
+	}
+	device.Status = &status
+	device.LastSeenAt = lastSeenAt
+	s.devices[deviceID] = device
+	return nil
+}
+
+func (s *MemoryStore) CreateDevice(
+	ctx context.Context, device *models.Device, user *models.User, acl *models.ACL,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.devices[device.ID]; exists {
+		return ErrConflict
+	}
+	if _, exists := s.users[user.ID]; exists {
+		return ErrConflict
+	}
+	if _, exists := s.acls[acl.ID]; exists {
+		return ErrConflict
+	}
+	stored := *device
+	stored.PhoneNumberIDs = slices.Clone(device.PhoneNumberIDs)
+	s.devices[device.ID] = stored
+	s.users[user.ID] = *user
+	s.acls[acl.ID] = *acl
+	return nil
+}
+
+func (s *MemoryStore) UpdateDeviceName(ctx context.Context, deviceID string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return ErrConflict
+	}
+	device.Name = name
+	device.UpdatedAt = models.FormatTimestamp(time.Now())
+	s.devices[deviceID] = device
+	return nil
+}
+
+func (s *MemoryStore) DeleteDevice(ctx context.Context, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok || len(device.PhoneNumberIDs) > 0 {
+		return ErrConflict
+	}
+	delete(s.devices, deviceID)
+	for id, user := range s.users {
+		if user.DeviceID == deviceID {
+			delete(s.users, id)
+		}
+	}
+	for id, acl := range s.acls {
+		if acl.DeviceID == deviceID {
+			delete(s.acls, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AttachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return ErrConflict
+	}
+	phoneNumber, ok := s.phoneNumbers[phoneNumberID]
+	if !ok || (phoneNumber.DeviceID != "" && phoneNumber.DeviceID != deviceID) {
+		return ErrConflict
+	}
+
+	updatedAt := models.FormatTimestamp(time.Now())
+	if !slices.Contains(device.PhoneNumberIDs, phoneNumberID) {
+		device.PhoneNumberIDs = append(slices.Clone(device.PhoneNumberIDs), phoneNumberID)
+	}
+	device.UpdatedAt = updatedAt
+	phoneNumber.DeviceID = deviceID
+	phoneNumber.UpdatedAt = updatedAt
+	s.devices[deviceID] = device
+	s.phoneNumbers[phoneNumberID] = phoneNumber
+	return nil
+}
+
+func (s *MemoryStore) DetachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok {
+		return ErrConflict
+	}
+	phoneNumber, ok := s.phoneNumbers[phoneNumberID]
+	if !ok {
+		return ErrConflict
+	}
+
+	updatedAt := models.FormatTimestamp(time.Now())
+	device.PhoneNumberIDs = slices.DeleteFunc(slices.Clone(device.PhoneNumberIDs), func(id string) bool {
+		return id == phoneNumberID
+	})
+	device.UpdatedAt = updatedAt
+	s.devices[deviceID] = device
+	if phoneNumber.DeviceID == deviceID {
+		phoneNumber.DeviceID = ""
+		phoneNumber.UpdatedAt = updatedAt
+		s.phoneNumbers[phoneNumberID] = phoneNumber
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetDeviceOfflineAlertedAt(
+	ctx context.Context, deviceID string, expected string, alertedAt string,
+) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.devices[deviceID]
+	if !ok || device.OfflineAlertedAt != expected {
+		return false, nil
+	}
+	device.OfflineAlertedAt = alertedAt
+	s.devices[deviceID] = device
+	return true, nil
+}
+
+func (s *MemoryStore) GetPhoneNumberByID(ctx context.Context, phoneNumberID string) (*models.PhoneNumber, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	phoneNumber, ok := s.phoneNumbers[phoneNumberID]
+	if !ok {
+		return nil, nil // Phone number not found
+	}
+	phoneNumber.ForwardDestinations = slices.Clone(phoneNumber.ForwardDestinations)
+	return &phoneNumber, nil
+}
+
+func (s *MemoryStore) GetPhoneNumberByPhoneNumber(ctx context.Context, number string) (*models.PhoneNumber, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, phoneNumber := range s.phoneNumbers {
+		if phoneNumber.PhoneNumber == number {
+			phoneNumber.ForwardDestinations = slices.Clone(phoneNumber.ForwardDestinations)
+			return &phoneNumber, nil
+		}
+	}
+	return nil, nil // Phone number not found
+}
+
+func (s *MemoryStore) PutSMS(ctx context.Context, sms *models.SMS) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.sms[sms.ID]; exists {
+		return ErrConflict
+	}
+	s.sms[sms.ID] = *sms
+	return nil
+}
+
+// phoneNumberTaken reports whether a phone number other than the one with the given ID has the
+// number. The caller must hold the lock.
+func (s *MemoryStore) phoneNumberTaken(phoneNumberID string, number string) bool {
+	for _, phoneNumber := range s.phoneNumbers {
+		if phoneNumber.PhoneNumber == number && phoneNumber.ID != phoneNumberID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) CreatePhoneNumber(ctx context.Context, phoneNumber *models.PhoneNumber, acl *models.ACL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.phoneNumbers[phoneNumber.ID]; exists {
+		return ErrConflict
+	}
+	if _, exists := s.acls[acl.ID]; exists {
+		return ErrConflict
+	}
+	if s.phoneNumberTaken(phoneNumber.ID, phoneNumber.PhoneNumber) {
+		return ErrPhoneNumberTaken
+	}
+	stored := *phoneNumber
+	stored.ForwardDestinations = slices.Clone(phoneNumber.ForwardDestinations)
+	s.phoneNumbers[phoneNumber.ID] = stored
+	s.acls[acl.ID] = *acl
+	return nil
+}
+
+func (s *MemoryStore) UpdatePhoneNumber(
+	ctx context.Context, phoneNumber *models.PhoneNumber, expectedUpdatedAt string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.phoneNumbers[phoneNumber.ID]
+	if !ok || stored.UpdatedAt != expectedUpdatedAt {
+		return ErrConflict
+	}
+	if s.phoneNumberTaken(phoneNumber.ID, phoneNumber.PhoneNumber) {
+		return ErrPhoneNumberTaken
+	}
+	phoneNumber.UpdatedAt = models.FormatTimestamp(time.Now())
+	stored.PhoneNumber = phoneNumber.PhoneNumber
+	stored.Name = phoneNumber.Name
+	stored.ForwardDestinations = slices.Clone(phoneNumber.ForwardDestinations)
+	stored.UpdatedAt = phoneNumber.UpdatedAt
+	s.phoneNumbers[phoneNumber.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) DeletePhoneNumber(ctx context.Context, phoneNumberID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	phoneNumber, ok := s.phoneNumbers[phoneNumberID]
+	if !ok || phoneNumber.DeviceID != "" {
+		return ErrConflict
+	}
+	delete(s.phoneNumbers, phoneNumberID)
+	for id, acl := range s.acls {
+		if acl.PhoneNumberID == phoneNumberID {
+			delete(s.acls, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListSMSByPhoneNumberID(
+	ctx context.Context, phoneNumberID string, limit int, cursor string,
+) ([]models.SMS, string, error) {
+	after, err := decodeSMSKeysetCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.RLock()
+	var smsList []models.SMS
+	for _, sms := range s.sms {
+		if sms.PhoneNumberID == phoneNumberID && (after == nil || after.before(sms)) {
+			smsList = append(smsList, sms)
+		}
+	}
+	s.mu.RUnlock()
+
+	// Sort newest first
+	slices.SortFunc(smsList, func(a, b models.SMS) int {
+		if c := strings.Compare(b.CreatedAt, a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	if len(smsList) <= limit {
+		return smsList, "", nil
+	}
+	smsList = smsList[:limit]
+	return smsList, encodeSMSKeysetCursor(smsList[limit-1]), nil
+}
+
+func (s *MemoryStore) PutOutboundSMS(ctx context.Context, outboundSMS *models.OutboundSMS) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.outboundSMS[outboundSMS.ID]; exists {
+		return errors.New("outbound SMS already exists")
+	}
+	s.outboundSMS[outboundSMS.ID] = *outboundSMS
+	return nil
+}
+
+func (s *MemoryStore) GetOutboundSMSByID(ctx context.Context, outboundSMSID string) (*models.OutboundSMS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	outboundSMS, ok := s.outboundSMS[outboundSMSID]
+	if !ok {
+		return nil, nil // Outbound SMS not found
+	}
+	return &outboundSMS, nil
+}
+
+func (s *MemoryStore) UpdateOutboundSMSStatus(
+	ctx context.Context, outboundSMSID string, status string, errorMessage string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	outboundSMS, ok := s.outboundSMS[outboundSMSID]
+	if !ok || outboundSMS.IsFinal() {
+		return ErrConflict
+	}
+	outboundSMS.Status = status
+	outboundSMS.Error = errorMessage
+	outboundSMS.UpdatedAt = models.FormatTimestamp(time.Now())
+	s.outboundSMS[outboundSMSID] = outboundSMS
+	return nil
+}
+
+func (s *MemoryStore) PutDeviceCommand(ctx context.Context, command *models.DeviceCommand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.deviceCommands[command.ID]; exists {
+		return errors.New("device command already exists")
+	}
+	s.deviceCommands[command.ID] = *command
+	return nil
+}
+
+func (s *MemoryStore) ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]models.DeviceCommand, error) {
+	s.mu.RLock()
+	commands := make([]models.DeviceCommand, 0)
+	for _, command := range s.deviceCommands {
+		if command.DeviceID == deviceID {
+			commands = append(commands, command)
+		}
+	}
+	s.mu.RUnlock()
+
+	// Sort oldest first
+	slices.SortFunc(commands, func(a, b models.DeviceCommand) int {
+		if c := strings.Compare(a.CreatedAt, b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(commands) > limit {
+		commands = commands[:limit]
+	}
+	return commands, nil
+}
+
+func (s *MemoryStore) DeleteDeviceCommand(ctx context.Context, deviceID string, commandID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	command, ok := s.deviceCommands[commandID]
+	if !ok || command.DeviceID != deviceID {
+		return false, nil
+	}
+	delete(s.deviceCommands, commandID)
+	return true, nil
+}
+
+func (s *MemoryStore) PutPairingCode(ctx context.Context, code *models.PairingCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.pairingCodes[code.ID]; exists {
+		return errors.New("pairing code already exists")
+	}
+	s.pairingCodes[code.ID] = *code
+	return nil
+}
+
+func (s *MemoryStore) GetPairingCode(ctx context.Context, id string) (*models.PairingCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	code, ok := s.pairingCodes[id]
+	if !ok {
+		return nil, nil // Pairing code not found
+	}
+	return &code, nil
+}
+
+func (s *MemoryStore) CompletePairing(
+	ctx context.Context, codeID string, device *models.Device, user *models.User, acl *models.ACL,
+) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.pairingCodes[codeID]
+	if !ok || code.ExpiresAt <= time.Now().Unix() {
+		return false, nil
+	}
+	if _, exists := s.devices[device.ID]; exists {
+		return false, errors.New("device already exists")
+	}
+	if _, exists := s.users[user.ID]; exists {
+		return false, errors.New("user already exists")
+	}
+	if _, exists := s.acls[acl.ID]; exists {
+		return false, errors.New("ACL entry already exists")
+	}
+
+	delete(s.pairingCodes, codeID)
+	stored := *device
+	stored.PhoneNumberIDs = slices.Clone(device.PhoneNumberIDs)
+	s.devices[device.ID] = stored
+	s.users[user.ID] = *user
+	s.acls[acl.ID] = *acl
+	return true, nil
+}
+
+func (s *MemoryStore) ListACLsByUserID(ctx context.Context, userID string) ([]models.ACL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var acls []models.ACL
+	for _, acl := range s.acls {
+		if acl.UserID == userID {
+			acls = append(acls, acl)
+		}
+	}
+	return acls, nil
+}
+
+// getIdempotencyRecord returns the record if it exists and has not expired. s.mu must be held.
+func (s *MemoryStore) getIdempotencyRecord(id string) (models.IdempotencyRecord, bool) {
+	record, ok := s.idempotencyRecords[id]
+	if !ok || record.ExpiresAt <= time.Now().Unix() {
+		return models.IdempotencyRecord{}, false
+	}
+	record.ForwardedDestinations = slices.Clone(record.ForwardedDestinations)
+	record.ResponseHeaders = maps.Clone(record.ResponseHeaders)
+	return record, true
+}
+
+func (s *MemoryStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.getIdempotencyRecord(record.ID); exists {
+		return false, nil
+	}
+	created := *record
+	created.ForwardedDestinations = slices.Clone(created.ForwardedDestinations)
+	s.idempotencyRecords[record.ID] = created
+	return true, nil
+}
+
+func (s *MemoryStore) GetIdempotencyRecord(ctx context.Context, id string) (*models.IdempotencyRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.getIdempotencyRecord(id)
+	if !ok {
+		return nil, nil // Record not found
+	}
+	return &record, nil
+}
+
+func (s *MemoryStore) CompleteIdempotencyRecord(
+	ctx context.Context, id string, statusCode int, responseHeaders map[string]string, responseBody string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.getIdempotencyRecord(id)
+	if !ok {
+		return errors.New("idempotency record not found")
+	}
+	record.Status = models.IdempotencyStatusCompleted
+	record.StatusCode = statusCode
+	record.ResponseHeaders = maps.Clone(responseHeaders)
+	record.ResponseBody = responseBody
+	s.idempotencyRecords[id] = record
+	return nil
+}
+
+func (s *MemoryStore) FailIdempotencyRecord(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.getIdempotencyRecord(id)
+	if !ok {
+		return ErrConflict
+	}
+	record.Status = models.IdempotencyStatusFailed
+	s.idempotencyRecords[id] = record
+	return nil
+}
+
+func (s *MemoryStore) RetryIdempotencyRecord(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.getIdempotencyRecord(id)
+	if !ok || record.Status != models.IdempotencyStatusFailed {
+		return false, nil
+	}
+	record.Status = models.IdempotencyStatusPending
+	s.idempotencyRecords[id] = record
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteIdempotencyRecord(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.idempotencyRecords, id)
+	return nil
+}
+
+func (s *MemoryStore) AddForwardedDestinations(ctx context.Context, id string, indexes []int, expiresAt int64) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.getIdempotencyRecord(id)
+	if !ok {
+		record = models.IdempotencyRecord{ID: id, CreatedAt: models.FormatTimestamp(time.Now())}
+	}
+	for _, index := range indexes {
+		if !slices.Contains(record.ForwardedDestinations, index) {
+			record.ForwardedDestinations = append(record.ForwardedDestinations, index)
+		}
+	}
+	record.ExpiresAt = expiresAt
+	s.idempotencyRecords[id] = record
+	return nil
+}
+
+func (s *MemoryStore) PutRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.refreshTokens[token.ID]; exists {
+		return errors.New("refresh token already exists")
+	}
+	s.refreshTokens[token.ID] = *token
+	return nil
+}
+
+// getRefreshToken returns the refresh token if it exists and has not expired. s.mu must be held.
+func (s *MemoryStore) getRefreshToken(id string) (models.RefreshToken, bool) {
+	token, ok := s.refreshTokens[id]
+	if !ok || token.ExpiresAt <= time.Now().Unix() {
+		return models.RefreshToken{}, false
+	}
+	return token, true
+}
+
+func (s *MemoryStore) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.getRefreshToken(id)
+	if !ok {
+		return nil, nil // Refresh token not found
+	}
+	return &token, nil
+}
+
+func (s *MemoryStore) UseRefreshToken(ctx context.Context, id string, usedAt string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.getRefreshToken(id)
+	if !ok || token.UsedAt != "" {
+		return false, nil
+	}
+	token.UsedAt = usedAt
+	s.refreshTokens[id] = token
+	return true, nil
+}
+
+func (s *MemoryStore) ListRefreshTokensByUserID(ctx context.Context, userID string) ([]models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var tokens []models.RefreshToken
+	for id := range s.refreshTokens {
+		if token, ok := s.getRefreshToken(id); ok && token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *MemoryStore) DeleteRefreshToken(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.refreshTokens, id)
+	return nil
+}
+
+func (s *MemoryStore) PutRevokedToken(ctx context.Context, token *models.RevokedToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokedTokens[token.ID] = *token
+	return nil
+}
+
+func (s *MemoryStore) GetRevokedToken(ctx context.Context, id string) (*models.RevokedToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.revokedTokens[id]
+	if !ok || token.ExpiresAt <= time.Now().Unix() {
+		return nil, nil // Token not revoked
+	}
+	return &token, nil
+}
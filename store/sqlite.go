@@ -0,0 +1,1064 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+	_ "modernc.org/sqlite" // Registers the pure Go "sqlite" driver
+)
+
+// sqliteSchema mirrors the DynamoDB tables. Each table has a column for every attribute the
+// DynamoDB table is keyed or indexed by, and keeps the full model as JSON in its data column.
+// As User.Password is not serialized to JSON, users keep it in a column of its own.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id       TEXT PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	data     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS devices (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS phone_numbers (
+	id           TEXT PRIMARY KEY,
+	phone_number TEXT NOT NULL UNIQUE,
+	data         TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS sms (
+	id              TEXT PRIMARY KEY,
+	phone_number_id TEXT NOT NULL,
+	created_at      TEXT NOT NULL,
+	data            TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sms_phone_number_id_index ON sms (phone_number_id, created_at, id);
+CREATE TABLE IF NOT EXISTS acls (
+	id      TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	data    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS acls_user_id_index ON acls (user_id);
+CREATE TABLE IF NOT EXISTS outbound_sms (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS device_commands (
+	id         TEXT PRIMARY KEY,
+	device_id  TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS device_commands_device_id_index ON device_commands (device_id, created_at);
+CREATE TABLE IF NOT EXISTS pairing_codes (
+	id         TEXT PRIMARY KEY,
+	expires_at INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS idempotency_records (
+	id         TEXT PRIMARY KEY,
+	expires_at INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	expires_at INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_index ON refresh_tokens (user_id);
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+	id         TEXT PRIMARY KEY,
+	expires_at INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+`
+
+// SQLiteStore stores models in a SQLite database, for self-hosted deployments.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// OpenSQLiteStore opens the SQLite database at path, creating it and its tables if needed.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time, so serialize all access through one connection
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set %q: %w", pragma, err)
+		}
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// PutUser creates or replaces a user.
+func (s *SQLiteStore) PutUser(ctx context.Context, user *models.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO users (id, username, password, data) VALUES (?, ?, ?, ?)`,
+		user.ID, user.Username, user.Password, data)
+	return err
+}
+
+// PutDevice creates or replaces a device.
+func (s *SQLiteStore) PutDevice(ctx context.Context, device *models.Device) error {
+	data, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT OR REPLACE INTO devices (id, data) VALUES (?, ?)`, device.ID, data)
+	return err
+}
+
+// PutPhoneNumber creates or replaces a phone number.
+func (s *SQLiteStore) PutPhoneNumber(ctx context.Context, phoneNumber *models.PhoneNumber) error {
+	data, err := json.Marshal(phoneNumber)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO phone_numbers (id, phone_number, data) VALUES (?, ?, ?)`,
+		phoneNumber.ID, phoneNumber.PhoneNumber, data)
+	return err
+}
+
+// PutACL creates or replaces an ACL entry.
+func (s *SQLiteStore) PutACL(ctx context.Context, acl *models.ACL) error {
+	data, err := json.Marshal(acl)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO acls (id, user_id, data) VALUES (?, ?, ?)`, acl.ID, acl.UserID, data)
+	return err
+}
+
+func (s *SQLiteStore) getUser(ctx context.Context, query string, arg string) (*models.User, error) {
+	var password, data string
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(&password, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // User not found
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := json.Unmarshal([]byte(data), &user); err != nil {
+		return nil, err
+	}
+	user.Password = password
+	return &user, nil
+}
+
+func (s *SQLiteStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	return s.getUser(ctx, `SELECT password, data FROM users WHERE id = ?`, userID)
+}
+
+func (s *SQLiteStore) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.getUser(ctx, `SELECT password, data FROM users WHERE username = ?`, username)
+}
+
+// getData unmarshals the data column of the single row selected by query into v. It reports
+// whether the row exists.
+func (s *SQLiteStore) getData(ctx context.Context, v any, query string, args ...any) (bool, error) {
+	return getDataFrom(ctx, s.db, v, query, args...)
+}
+
+// getDataFrom is getData for a database or a transaction.
+func getDataFrom(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}, v any, query string, args ...any) (bool, error) {
+	var data string
+	err := db.QueryRowContext(ctx, query, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(data), v)
+}
+
+func (s *SQLiteStore) GetDeviceByID(ctx context.Context, deviceID string) (*models.Device, error) {
+	var device models.Device
+	found, err := s.getData(ctx, &device, `SELECT data FROM devices WHERE id = ?`, deviceID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func (s *SQLiteStore) ListDevices(ctx context.Context) ([]models.Device, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM devices ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []models.Device
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var device models.Device
+		if err := json.Unmarshal([]byte(data), &device); err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// errDeviceNotFound is returned by updateDevice for missing devices.
+var errDeviceNotFound = errors.New("device not found")
+
+// updateDevice applies update to the device in a transaction, saving it if update returns true. It
+// reports whether the device was saved.
+func (s *SQLiteStore) updateDevice(ctx context.Context, deviceID string, update func(*models.Device) bool) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var data string
+	err = tx.QueryRowContext(ctx, `SELECT data FROM devices WHERE id = ?`, deviceID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errDeviceNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	var device models.Device
+	if err := json.Unmarshal([]byte(data), &device); err != nil {
+		return false, err
+	}
+	if !update(&device) {
+		return false, nil
+	}
+	updated, err := json.Marshal(device)
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE devices SET data = ? WHERE id = ?`, updated, deviceID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *SQLiteStore) UpdateDeviceHeartbeat(
+	ctx context.Context, deviceID string, status models.DeviceStatus, lastSeenAt string,
+) error {
+	_, err := s.updateDevice(ctx, deviceID, func(device *models.Device) bool {
+		device.Status = &status
+		device.LastSeenAt = lastSeenAt
+		return true
+	})
+	return err
+}
+
+// execWithData executes query with v marshalled to JSON as its first argument, followed by args.
+func execWithData(ctx context.Context, tx *sql.Tx, query string, v any, args ...any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query, append([]any{data}, args...)...)
+	return err
+}
+
+func (s *SQLiteStore) CreateDevice(
+	ctx context.Context, device *models.Device, user *models.User, acl *models.ACL,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing models.Device
+	if exists, err := getDataFrom(ctx, tx, &existing, `SELECT data FROM devices WHERE id = ?`, device.ID); err != nil {
+		return err
+	} else if exists {
+		return ErrConflict
+	}
+	if err := execWithData(ctx, tx, `INSERT INTO devices (data, id) VALUES (?, ?)`, device, device.ID); err != nil {
+		return err
+	}
+	if err := execWithData(ctx, tx, `INSERT INTO users (data, id, username, password) VALUES (?, ?, ?, ?)`,
+		user, user.ID, user.Username, user.Password); err != nil {
+		return err
+	}
+	if err := execWithData(ctx, tx, `INSERT INTO acls (data, id, user_id) VALUES (?, ?, ?)`, acl, acl.ID, acl.UserID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) UpdateDeviceName(ctx context.Context, deviceID string, name string) error {
+	_, err := s.updateDevice(ctx, deviceID, func(device *models.Device) bool {
+		device.Name = name
+		device.UpdatedAt = models.FormatTimestamp(time.Now())
+		return true
+	})
+	if errors.Is(err, errDeviceNotFound) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *SQLiteStore) DeleteDevice(ctx context.Context, deviceID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var device models.Device
+	found, err := getDataFrom(ctx, tx, &device, `SELECT data FROM devices WHERE id = ?`, deviceID)
+	if err != nil {
+		return err
+	}
+	if !found || len(device.PhoneNumberIDs) > 0 {
+		return ErrConflict
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM devices WHERE id = ?`, deviceID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE json_extract(data, '$.device_id') = ?`, deviceID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM acls WHERE json_extract(data, '$.device_id') = ?`, deviceID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getDeviceAndPhoneNumberTx reads a device and a phone number for linking them, returning
+// ErrConflict if either doesn't exist.
+func getDeviceAndPhoneNumberTx(
+	ctx context.Context, tx *sql.Tx, deviceID string, phoneNumberID string,
+) (*models.Device, *models.PhoneNumber, error) {
+	var device models.Device
+	found, err := getDataFrom(ctx, tx, &device, `SELECT data FROM devices WHERE id = ?`, deviceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, ErrConflict
+	}
+	var phoneNumber models.PhoneNumber
+	found, err = getDataFrom(ctx, tx, &phoneNumber, `SELECT data FROM phone_numbers WHERE id = ?`, phoneNumberID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, ErrConflict
+	}
+	return &device, &phoneNumber, nil
+}
+
+func (s *SQLiteStore) AttachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	device, phoneNumber, err := getDeviceAndPhoneNumberTx(ctx, tx, deviceID, phoneNumberID)
+	if err != nil {
+		return err
+	}
+	if phoneNumber.DeviceID != "" && phoneNumber.DeviceID != deviceID {
+		return ErrConflict
+	}
+
+	updatedAt := models.FormatTimestamp(time.Now())
+	if !slices.Contains(device.PhoneNumberIDs, phoneNumberID) {
+		device.PhoneNumberIDs = append(device.PhoneNumberIDs, phoneNumberID)
+	}
+	device.UpdatedAt = updatedAt
+	phoneNumber.DeviceID = deviceID
+	phoneNumber.UpdatedAt = updatedAt
+	if err := execWithData(ctx, tx, `UPDATE devices SET data = ? WHERE id = ?`, device, deviceID); err != nil {
+		return err
+	}
+	if err := execWithData(ctx, tx, `UPDATE phone_numbers SET data = ? WHERE id = ?`, phoneNumber, phoneNumberID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DetachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	device, phoneNumber, err := getDeviceAndPhoneNumberTx(ctx, tx, deviceID, phoneNumberID)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := models.FormatTimestamp(time.Now())
+	device.PhoneNumberIDs = slices.DeleteFunc(device.PhoneNumberIDs, func(id string) bool {
+		return id == phoneNumberID
+	})
+	device.UpdatedAt = updatedAt
+	if err := execWithData(ctx, tx, `UPDATE devices SET data = ? WHERE id = ?`, device, deviceID); err != nil {
+		return err
+	}
+	if phoneNumber.DeviceID == deviceID {
+		phoneNumber.DeviceID = ""
+		phoneNumber.UpdatedAt = updatedAt
+		if err := execWithData(ctx, tx, `UPDATE phone_numbers SET data = ? WHERE id = ?`, phoneNumber, phoneNumberID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SetDeviceOfflineAlertedAt(
+	ctx context.Context, deviceID string, expected string, alertedAt string,
+) (bool, error) {
+	set, err := s.updateDevice(ctx, deviceID, func(device *models.Device) bool {
+		if device.OfflineAlertedAt != expected {
+			return false
+		}
+		device.OfflineAlertedAt = alertedAt
+		return true
+	})
+	if errors.Is(err, errDeviceNotFound) {
+		return false, nil
+	}
+	return set, err
+}
+
+func (s *SQLiteStore) GetPhoneNumberByID(ctx context.Context, phoneNumberID string) (*models.PhoneNumber, error) {
+	var phoneNumber models.PhoneNumber
+	found, err := s.getData(ctx, &phoneNumber, `SELECT data FROM phone_numbers WHERE id = ?`, phoneNumberID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &phoneNumber, nil
+}
+
+func (s *SQLiteStore) GetPhoneNumberByPhoneNumber(ctx context.Context, number string) (*models.PhoneNumber, error) {
+	var phoneNumber models.PhoneNumber
+	found, err := s.getData(ctx, &phoneNumber, `SELECT data FROM phone_numbers WHERE phone_number = ?`, number)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &phoneNumber, nil
+}
+
+func (s *SQLiteStore) PutSMS(ctx context.Context, sms *models.SMS) error {
+	data, err := json.Marshal(sms)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO sms (id, phone_number_id, created_at, data) VALUES (?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		sms.ID, sms.PhoneNumberID, sms.CreatedAt, data)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// phoneNumberTakenTx reports whether a phone number other than the one with the given ID has the
+// number.
+func phoneNumberTakenTx(ctx context.Context, tx *sql.Tx, phoneNumberID string, number string) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM phone_numbers WHERE phone_number = ? AND id != ?`,
+		number, phoneNumberID).Scan(&count)
+	return count > 0, err
+}
+
+func (s *SQLiteStore) CreatePhoneNumber(ctx context.Context, phoneNumber *models.PhoneNumber, acl *models.ACL) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing models.PhoneNumber
+	if exists, err := getDataFrom(ctx, tx, &existing, `SELECT data FROM phone_numbers WHERE id = ?`, phoneNumber.ID); err != nil {
+		return err
+	} else if exists {
+		return ErrConflict
+	}
+	if taken, err := phoneNumberTakenTx(ctx, tx, phoneNumber.ID, phoneNumber.PhoneNumber); err != nil {
+		return err
+	} else if taken {
+		return ErrPhoneNumberTaken
+	}
+	if err := execWithData(ctx, tx, `INSERT INTO phone_numbers (data, id, phone_number) VALUES (?, ?, ?)`, phoneNumber,
+		phoneNumber.ID, phoneNumber.PhoneNumber); err != nil {
+		return err
+	}
+	if err := execWithData(ctx, tx, `INSERT INTO acls (data, id, user_id) VALUES (?, ?, ?)`, acl, acl.ID, acl.UserID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) UpdatePhoneNumber(
+	ctx context.Context, phoneNumber *models.PhoneNumber, expectedUpdatedAt string,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stored models.PhoneNumber
+	found, err := getDataFrom(ctx, tx, &stored, `SELECT data FROM phone_numbers WHERE id = ?`, phoneNumber.ID)
+	if err != nil {
+		return err
+	}
+	if !found || stored.UpdatedAt != expectedUpdatedAt {
+		return ErrConflict
+	}
+	if taken, err := phoneNumberTakenTx(ctx, tx, phoneNumber.ID, phoneNumber.PhoneNumber); err != nil {
+		return err
+	} else if taken {
+		return ErrPhoneNumberTaken
+	}
+
+	phoneNumber.UpdatedAt = models.FormatTimestamp(time.Now())
+	stored.PhoneNumber = phoneNumber.PhoneNumber
+	stored.Name = phoneNumber.Name
+	stored.ForwardDestinations = phoneNumber.ForwardDestinations
+	stored.UpdatedAt = phoneNumber.UpdatedAt
+	if err := execWithData(ctx, tx, `UPDATE phone_numbers SET data = ?, phone_number = ? WHERE id = ?`, &stored,
+		stored.PhoneNumber, stored.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeletePhoneNumber(ctx context.Context, phoneNumberID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var phoneNumber models.PhoneNumber
+	found, err := getDataFrom(ctx, tx, &phoneNumber, `SELECT data FROM phone_numbers WHERE id = ?`, phoneNumberID)
+	if err != nil {
+		return err
+	}
+	if !found || phoneNumber.DeviceID != "" {
+		return ErrConflict
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM phone_numbers WHERE id = ?`, phoneNumberID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM acls WHERE json_extract(data, '$.phone_number_id') = ?`,
+		phoneNumberID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ListSMSByPhoneNumberID(
+	ctx context.Context, phoneNumberID string, limit int, cursor string,
+) ([]models.SMS, string, error) {
+	after, err := decodeSMSKeysetCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fetch one extra row to find out whether there is a next page
+	var rows *sql.Rows
+	if after == nil {
+		rows, err = s.db.QueryContext(ctx,
+			`SELECT data FROM sms WHERE phone_number_id = ?
+			ORDER BY created_at DESC, id DESC LIMIT ?`,
+			phoneNumberID, limit+1)
+	} else {
+		rows, err = s.db.QueryContext(ctx,
+			`SELECT data FROM sms WHERE phone_number_id = ? AND (created_at, id) < (?, ?)
+			ORDER BY created_at DESC, id DESC LIMIT ?`,
+			phoneNumberID, after.CreatedAt, after.ID, limit+1)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	smsList := make([]models.SMS, 0, limit+1)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, "", err
+		}
+		var sms models.SMS
+		if err := json.Unmarshal([]byte(data), &sms); err != nil {
+			return nil, "", err
+		}
+		smsList = append(smsList, sms)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(smsList) <= limit {
+		return smsList, "", nil
+	}
+	smsList = smsList[:limit]
+	return smsList, encodeSMSKeysetCursor(smsList[limit-1]), nil
+}
+
+func (s *SQLiteStore) PutOutboundSMS(ctx context.Context, outboundSMS *models.OutboundSMS) error {
+	data, err := json.Marshal(outboundSMS)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO outbound_sms (id, data) VALUES (?, ?)`, outboundSMS.ID, data)
+	return err
+}
+
+func (s *SQLiteStore) GetOutboundSMSByID(ctx context.Context, outboundSMSID string) (*models.OutboundSMS, error) {
+	var outboundSMS models.OutboundSMS
+	found, err := s.getData(ctx, &outboundSMS, `SELECT data FROM outbound_sms WHERE id = ?`, outboundSMSID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &outboundSMS, nil
+}
+
+func (s *SQLiteStore) UpdateOutboundSMSStatus(
+	ctx context.Context, outboundSMSID string, status string, errorMessage string,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data string
+	err = tx.QueryRowContext(ctx, `SELECT data FROM outbound_sms WHERE id = ?`, outboundSMSID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	var outboundSMS models.OutboundSMS
+	if err := json.Unmarshal([]byte(data), &outboundSMS); err != nil {
+		return err
+	}
+	if outboundSMS.IsFinal() {
+		return ErrConflict
+	}
+	outboundSMS.Status = status
+	outboundSMS.Error = errorMessage
+	outboundSMS.UpdatedAt = models.FormatTimestamp(time.Now())
+	updated, err := json.Marshal(outboundSMS)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE outbound_sms SET data = ? WHERE id = ?`, updated, outboundSMSID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) PutDeviceCommand(ctx context.Context, command *models.DeviceCommand) error {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO device_commands (id, device_id, created_at, data) VALUES (?, ?, ?, ?)`,
+		command.ID, command.DeviceID, command.CreatedAt, data)
+	return err
+}
+
+func (s *SQLiteStore) ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]models.DeviceCommand, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT data FROM device_commands WHERE device_id = ? ORDER BY created_at, id LIMIT ?`, deviceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commands := make([]models.DeviceCommand, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var command models.DeviceCommand
+		if err := json.Unmarshal([]byte(data), &command); err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+	return commands, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteDeviceCommand(ctx context.Context, deviceID string, commandID string) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM device_commands WHERE id = ? AND device_id = ?`, commandID, deviceID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+func (s *SQLiteStore) PutPairingCode(ctx context.Context, code *models.PairingCode) error {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO pairing_codes (id, expires_at, data) VALUES (?, ?, ?)`,
+		code.ID, code.ExpiresAt, data)
+	return err
+}
+
+func (s *SQLiteStore) GetPairingCode(ctx context.Context, id string) (*models.PairingCode, error) {
+	var code models.PairingCode
+	found, err := s.getData(ctx, &code, `SELECT data FROM pairing_codes WHERE id = ?`, id)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (s *SQLiteStore) CompletePairing(
+	ctx context.Context, codeID string, device *models.Device, user *models.User, acl *models.ACL,
+) (bool, error) {
+	deviceData, err := json.Marshal(device)
+	if err != nil {
+		return false, err
+	}
+	userData, err := json.Marshal(user)
+	if err != nil {
+		return false, err
+	}
+	aclData, err := json.Marshal(acl)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM pairing_codes WHERE id = ? AND expires_at > ?`,
+		codeID, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	if consumed, err := result.RowsAffected(); err != nil || consumed == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO devices (id, data) VALUES (?, ?)`, device.ID, deviceData); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO users (id, username, password, data) VALUES (?, ?, ?, ?)`,
+		user.ID, user.Username, user.Password, userData); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO acls (id, user_id, data) VALUES (?, ?, ?)`,
+		acl.ID, acl.UserID, aclData); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *SQLiteStore) ListACLsByUserID(ctx context.Context, userID string) ([]models.ACL, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM acls WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var acls []models.ACL
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var acl models.ACL
+		if err := json.Unmarshal([]byte(data), &acl); err != nil {
+			return nil, err
+		}
+		acls = append(acls, acl)
+	}
+	return acls, rows.Err()
+}
+
+// getIdempotencyRecord returns the record if it exists and has not expired.
+func getIdempotencyRecord(ctx context.Context, tx *sql.Tx, id string) (*models.IdempotencyRecord, error) {
+	var data string
+	err := tx.QueryRowContext(ctx,
+		`SELECT data FROM idempotency_records WHERE id = ? AND expires_at > ?`, id, time.Now().Unix(),
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil // Record not found
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record models.IdempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func putIdempotencyRecord(ctx context.Context, tx *sql.Tx, record *models.IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO idempotency_records (id, expires_at, data) VALUES (?, ?, ?)`,
+		record.ID, record.ExpiresAt, data)
+	return err
+}
+
+// updateIdempotencyRecord runs fn on the record with the given ID, or nil if there is none, within
+// a transaction and stores the record fn returns.
+func (s *SQLiteStore) updateIdempotencyRecord(
+	ctx context.Context, id string, fn func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error),
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	record, err := getIdempotencyRecord(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	record, err = fn(record)
+	if err != nil {
+		return err
+	}
+	if err := putIdempotencyRecord(ctx, tx, record); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	existing, err := getIdempotencyRecord(ctx, tx, record.ID)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+	if err := putIdempotencyRecord(ctx, tx, record); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *SQLiteStore) GetIdempotencyRecord(ctx context.Context, id string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	found, err := s.getData(ctx, &record,
+		`SELECT data FROM idempotency_records WHERE id = ? AND expires_at > ?`, id, time.Now().Unix())
+	if err != nil || !found {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *SQLiteStore) CompleteIdempotencyRecord(
+	ctx context.Context, id string, statusCode int, responseHeaders map[string]string, responseBody string,
+) error {
+	return s.updateIdempotencyRecord(ctx, id, func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		if record == nil {
+			return nil, errors.New("idempotency record not found")
+		}
+		record.Status = models.IdempotencyStatusCompleted
+		record.StatusCode = statusCode
+		record.ResponseHeaders = responseHeaders
+		record.ResponseBody = responseBody
+		return record, nil
+	})
+}
+
+func (s *SQLiteStore) FailIdempotencyRecord(ctx context.Context, id string) error {
+	return s.updateIdempotencyRecord(ctx, id, func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		if record == nil {
+			return nil, ErrConflict
+		}
+		record.Status = models.IdempotencyStatusFailed
+		return record, nil
+	})
+}
+
+func (s *SQLiteStore) RetryIdempotencyRecord(ctx context.Context, id string) (bool, error) {
+	err := s.updateIdempotencyRecord(ctx, id, func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		if record == nil || record.Status != models.IdempotencyStatusFailed {
+			return nil, ErrConflict
+		}
+		record.Status = models.IdempotencyStatusPending
+		return record, nil
+	})
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLiteStore) DeleteIdempotencyRecord(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_records WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) AddForwardedDestinations(ctx context.Context, id string, indexes []int, expiresAt int64) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	return s.updateIdempotencyRecord(ctx, id, func(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+		if record == nil {
+			record = &models.IdempotencyRecord{ID: id, CreatedAt: models.FormatTimestamp(time.Now())}
+		}
+		for _, index := range indexes {
+			if !slices.Contains(record.ForwardedDestinations, index) {
+				record.ForwardedDestinations = append(record.ForwardedDestinations, index)
+			}
+		}
+		record.ExpiresAt = expiresAt
+		return record, nil
+	})
+}
+
+func (s *SQLiteStore) PutRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, user_id, expires_at, data) VALUES (?, ?, ?, ?)`,
+		token.ID, token.UserID, token.ExpiresAt, data)
+	return err
+}
+
+func (s *SQLiteStore) GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	found, err := s.getData(ctx, &token,
+		`SELECT data FROM refresh_tokens WHERE id = ? AND expires_at > ?`, id, time.Now().Unix())
+	if err != nil || !found {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (s *SQLiteStore) UseRefreshToken(ctx context.Context, id string, usedAt string) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET data = json_set(data, '$.used_at', ?)
+		WHERE id = ? AND expires_at > ? AND coalesce(json_extract(data, '$.used_at'), '') = ''`,
+		usedAt, id, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+	used, err := result.RowsAffected()
+	return used > 0, err
+}
+
+func (s *SQLiteStore) ListRefreshTokensByUserID(ctx context.Context, userID string) ([]models.RefreshToken, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT data FROM refresh_tokens WHERE user_id = ? AND expires_at > ?`, userID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.RefreshToken
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var token models.RefreshToken
+		if err := json.Unmarshal([]byte(data), &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteRefreshToken(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) PutRevokedToken(ctx context.Context, token *models.RevokedToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO revoked_tokens (id, expires_at, data) VALUES (?, ?, ?)`,
+		token.ID, token.ExpiresAt, data)
+	return err
+}
+
+func (s *SQLiteStore) GetRevokedToken(ctx context.Context, id string) (*models.RevokedToken, error) {
+	var token models.RevokedToken
+	found, err := s.getData(ctx, &token,
+		`SELECT data FROM revoked_tokens WHERE id = ? AND expires_at > ?`, id, time.Now().Unix())
+	if err != nil || !found {
+		return nil, err
+	}
+	return &token, nil
+}
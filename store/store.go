@@ -0,0 +1,164 @@
+// Package store persists the relay's models. Each kind of model has a repository interface, and a
+// Store bundles all of them. Implementations are provided for DynamoDB, SQLite and memory.
+//
+// Getters return a nil model and a nil error when the model does not exist.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor was not issued by the store.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrConflict is returned when a write's preconditions don't hold, e.g. because the model was
+	// modified or deleted concurrently.
+	ErrConflict = errors.New("conflicting write")
+	// ErrPhoneNumberTaken is returned when writing a phone number whose number another one has.
+	ErrPhoneNumberTaken = errors.New("phone number already exists")
+)
+
+type UserRepository interface {
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+}
+
+type DeviceRepository interface {
+	GetDeviceByID(ctx context.Context, deviceID string) (*models.Device, error)
+	ListDevices(ctx context.Context) ([]models.Device, error)
+	// UpdateDeviceHeartbeat sets the status and last seen timestamp of an existing device.
+	UpdateDeviceHeartbeat(ctx context.Context, deviceID string, status models.DeviceStatus, lastSeenAt string) error
+	// CreateDevice creates a new device along with its device user and the ACL entry of its owner.
+	CreateDevice(ctx context.Context, device *models.Device, user *models.User, acl *models.ACL) error
+	// UpdateDeviceName renames an existing device.
+	UpdateDeviceName(ctx context.Context, deviceID string, name string) error
+	// DeleteDevice deletes a device without phone numbers along with its device users and ACL
+	// entries. It returns ErrConflict if the device doesn't exist or still has phone numbers.
+	DeleteDevice(ctx context.Context, deviceID string) error
+	// AttachPhoneNumber adds the phone number to Device.PhoneNumberIDs and sets its
+	// PhoneNumber.DeviceID in one write. It returns ErrConflict if either doesn't exist, if the
+	// phone number is attached to another device, or if either was modified concurrently.
+	AttachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error
+	// DetachPhoneNumber reverts AttachPhoneNumber, also repairing a link that only one side has.
+	DetachPhoneNumber(ctx context.Context, deviceID string, phoneNumberID string) error
+	// SetDeviceOfflineAlertedAt sets the OfflineAlertedAt of an existing device to alertedAt, or
+	// clears it if alertedAt is empty, provided that it currently equals expected. It reports
+	// whether it was set, so that concurrent checkers alert only once.
+	SetDeviceOfflineAlertedAt(ctx context.Context, deviceID string, expected string, alertedAt string) (bool, error)
+}
+
+type PhoneNumberRepository interface {
+	GetPhoneNumberByID(ctx context.Context, phoneNumberID string) (*models.PhoneNumber, error)
+	GetPhoneNumberByPhoneNumber(ctx context.Context, number string) (*models.PhoneNumber, error)
+	// CreatePhoneNumber creates a new phone number along with the ACL entry of its owner. It
+	// returns ErrPhoneNumberTaken if another phone number has the same number.
+	CreatePhoneNumber(ctx context.Context, phoneNumber *models.PhoneNumber, acl *models.ACL) error
+	// UpdatePhoneNumber saves the number, name and forward destinations of an existing phone
+	// number, provided that its UpdatedAt still equals expectedUpdatedAt, and sets its UpdatedAt.
+	// It returns ErrPhoneNumberTaken if another phone number has the same number.
+	UpdatePhoneNumber(ctx context.Context, phoneNumber *models.PhoneNumber, expectedUpdatedAt string) error
+	// DeletePhoneNumber deletes a phone number that is not attached to a device along with its ACL
+	// entries. It returns ErrConflict if the phone number doesn't exist or is attached.
+	DeletePhoneNumber(ctx context.Context, phoneNumberID string) error
+}
+
+type SMSRepository interface {
+	// PutSMS stores a new SMS. It returns ErrConflict if an SMS with the same ID already exists.
+	PutSMS(ctx context.Context, sms *models.SMS) error
+	// ListSMSByPhoneNumberID returns up to limit SMS messages of the given phone number, newest
+	// first. cursor is the cursor returned with the previous page, or empty for the first page. The
+	// returned cursor is empty when there are no more pages.
+	ListSMSByPhoneNumberID(ctx context.Context, phoneNumberID string, limit int, cursor string) (
+		smsList []models.SMS, nextCursor string, err error)
+}
+
+type OutboundSMSRepository interface {
+	// PutOutboundSMS stores a new outbound SMS. It fails if one with the same ID already exists.
+	PutOutboundSMS(ctx context.Context, outboundSMS *models.OutboundSMS) error
+	GetOutboundSMSByID(ctx context.Context, outboundSMSID string) (*models.OutboundSMS, error)
+	// UpdateOutboundSMSStatus sets the status and error of an existing outbound SMS. It returns
+	// ErrConflict if the outbound SMS doesn't exist or its status is already final, so that
+	// concurrent reports can't overwrite a delivered or failed status.
+	UpdateOutboundSMSStatus(ctx context.Context, outboundSMSID string, status string, errorMessage string) error
+}
+
+type DeviceCommandRepository interface {
+	PutDeviceCommand(ctx context.Context, command *models.DeviceCommand) error
+	// ListDeviceCommands returns up to limit pending commands of the device, oldest first.
+	ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]models.DeviceCommand, error)
+	// DeleteDeviceCommand deletes a command of the device. It reports whether the command existed
+	// and belonged to the device.
+	DeleteDeviceCommand(ctx context.Context, deviceID string, commandID string) (bool, error)
+}
+
+type PairingCodeRepository interface {
+	PutPairingCode(ctx context.Context, code *models.PairingCode) error
+	GetPairingCode(ctx context.Context, id string) (*models.PairingCode, error)
+	// CompletePairing atomically consumes the unexpired pairing code and creates the device, its
+	// device user and the ACL entry granting the code's user access to the device. It reports
+	// whether the code was still valid; nothing is created if it wasn't.
+	CompletePairing(ctx context.Context, codeID string, device *models.Device, user *models.User, acl *models.ACL) (bool, error)
+}
+
+type ACLRepository interface {
+	ListACLsByUserID(ctx context.Context, userID string) ([]models.ACL, error)
+}
+
+type IdempotencyRepository interface {
+	// CreateIdempotencyRecord stores the record unless a record with the same ID already exists.
+	// It reports whether the record was created.
+	CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) (bool, error)
+	GetIdempotencyRecord(ctx context.Context, id string) (*models.IdempotencyRecord, error)
+	// CompleteIdempotencyRecord marks the record as completed with the given response.
+	CompleteIdempotencyRecord(
+		ctx context.Context, id string, statusCode int, responseHeaders map[string]string, responseBody string,
+	) error
+	// FailIdempotencyRecord marks the record as failed, keeping it so that a retry of the request can
+	// claim it again with RetryIdempotencyRecord.
+	FailIdempotencyRecord(ctx context.Context, id string) error
+	// RetryIdempotencyRecord marks a failed record as pending again. It returns false if the record
+	// isn't failed, e.g. because a concurrent retry already claimed it.
+	RetryIdempotencyRecord(ctx context.Context, id string) (bool, error)
+	DeleteIdempotencyRecord(ctx context.Context, id string) error
+	// AddForwardedDestinations adds the destination indexes to the record, creating the record if
+	// it does not exist, and sets its expiry.
+	AddForwardedDestinations(ctx context.Context, id string, indexes []int, expiresAt int64) error
+}
+
+type RefreshTokenRepository interface {
+	// PutRefreshToken stores a new refresh token. It fails if one with the same ID already exists.
+	PutRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// GetRefreshToken returns the refresh token with the given ID if it has not expired.
+	GetRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error)
+	// UseRefreshToken marks an unexpired and unused refresh token as used at usedAt. It reports
+	// whether it was, so that a token is only exchanged once even by concurrent requests.
+	UseRefreshToken(ctx context.Context, id string, usedAt string) (bool, error)
+	// ListRefreshTokensByUserID returns the unexpired refresh tokens of the user, used or not.
+	ListRefreshTokensByUserID(ctx context.Context, userID string) ([]models.RefreshToken, error)
+	DeleteRefreshToken(ctx context.Context, id string) error
+}
+
+type RevokedTokenRepository interface {
+	// PutRevokedToken adds an access token to the revocation list, replacing its existing entry.
+	PutRevokedToken(ctx context.Context, token *models.RevokedToken) error
+	// GetRevokedToken returns the entry of the access token with the given ID if it has not expired.
+	GetRevokedToken(ctx context.Context, id string) (*models.RevokedToken, error)
+}
+
+// Store provides all repositories.
+type Store interface {
+	UserRepository
+	DeviceRepository
+	PhoneNumberRepository
+	SMSRepository
+	OutboundSMSRepository
+	DeviceCommandRepository
+	PairingCodeRepository
+	ACLRepository
+	IdempotencyRepository
+	RefreshTokenRepository
+	RevokedTokenRepository
+}
@@ -0,0 +1,436 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zhouziqunzzq/sms-relay-server/models"
+)
+
+// storeFactories create the stores under test, which must all honor the contract of the Store
+// interface. Users have no repository method creating them, so each store seeds them its own way.
+var storeFactories = []struct {
+	name string
+	open func(t *testing.T) (s Store, putUser func(user models.User))
+}{
+	{
+		name: "memory",
+		open: func(t *testing.T) (Store, func(models.User)) {
+			s := NewMemoryStore()
+			return s, s.PutUser
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(t *testing.T) (Store, func(models.User)) {
+			s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "store.db"))
+			if err != nil {
+				t.Fatalf("OpenSQLiteStore: %v", err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s, func(user models.User) {
+				if err := s.PutUser(context.Background(), &user); err != nil {
+					t.Fatalf("PutUser: %v", err)
+				}
+			}
+		},
+	},
+}
+
+// forEachStore runs the test against each store.
+func forEachStore(t *testing.T, test func(t *testing.T, s Store, putUser func(models.User))) {
+	for _, factory := range storeFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			s, putUser := factory.open(t)
+			test(t, s, putUser)
+		})
+	}
+}
+
+// createDevice creates a device with its device user and the ACL entry of user-1.
+func createDevice(t *testing.T, s Store, deviceID string) {
+	t.Helper()
+	err := s.CreateDevice(context.Background(),
+		&models.Device{ID: deviceID, Name: "Phone", PhoneNumberIDs: []string{}},
+		&models.User{ID: "user-" + deviceID, Username: deviceID, UserType: models.UserTypeDevice, DeviceID: deviceID},
+		&models.ACL{ID: "acl-" + deviceID, UserID: "user-1", DeviceID: deviceID})
+	if err != nil {
+		t.Fatalf("CreateDevice %s: %v", deviceID, err)
+	}
+}
+
+// createPhoneNumber creates a phone number with the ACL entry of user-1.
+func createPhoneNumber(t *testing.T, s Store, phoneNumberID string, number string) *models.PhoneNumber {
+	t.Helper()
+	phoneNumber := &models.PhoneNumber{ID: phoneNumberID, PhoneNumber: number, ForwardDestinations: models.ForwardDestinations{}}
+	err := s.CreatePhoneNumber(context.Background(), phoneNumber,
+		&models.ACL{ID: "acl-" + phoneNumberID, UserID: "user-1", PhoneNumberID: phoneNumberID})
+	if err != nil {
+		t.Fatalf("CreatePhoneNumber %s: %v", phoneNumberID, err)
+	}
+	return phoneNumber
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		putUser(models.User{ID: "user-1", Username: "alice", UserType: models.UserTypeUser, Name: "Alice"})
+
+		user, err := s.GetUserByID(ctx, "user-1")
+		if err != nil || user == nil || user.Username != "alice" || user.Name != "Alice" {
+			t.Errorf("GetUserByID: got %+v, %v", user, err)
+		}
+		user, err = s.GetUserByUsername(ctx, "alice")
+		if err != nil || user == nil || user.ID != "user-1" {
+			t.Errorf("GetUserByUsername: got %+v, %v", user, err)
+		}
+		if user, err := s.GetUserByID(ctx, "missing"); user != nil || err != nil {
+			t.Errorf("GetUserByID of a missing user: got %+v, %v, want nil, nil", user, err)
+		}
+		if user, err := s.GetUserByUsername(ctx, "missing"); user != nil || err != nil {
+			t.Errorf("GetUserByUsername of a missing user: got %+v, %v, want nil, nil", user, err)
+		}
+	})
+}
+
+func TestDevices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		createDevice(t, s, "device-1")
+
+		device, err := s.GetDeviceByID(ctx, "device-1")
+		if err != nil || device == nil || device.Name != "Phone" {
+			t.Fatalf("GetDeviceByID: got %+v, %v", device, err)
+		}
+		if user, err := s.GetUserByID(ctx, "user-device-1"); err != nil || user == nil || user.DeviceID != "device-1" {
+			t.Errorf("device user: got %+v, %v", user, err)
+		}
+		err = s.CreateDevice(ctx, &models.Device{ID: "device-1"},
+			&models.User{ID: "user-other", Username: "other", UserType: models.UserTypeDevice, DeviceID: "device-1"},
+			&models.ACL{ID: "acl-other", UserID: "user-1", DeviceID: "device-1"})
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("CreateDevice of an existing device: got %v, want ErrConflict", err)
+		}
+
+		if err := s.UpdateDeviceName(ctx, "device-1", "Work phone"); err != nil {
+			t.Fatalf("UpdateDeviceName: %v", err)
+		}
+		if err := s.UpdateDeviceName(ctx, "missing", "Work phone"); !errors.Is(err, ErrConflict) {
+			t.Errorf("UpdateDeviceName of a missing device: got %v, want ErrConflict", err)
+		}
+		batteryLevel := 80
+		err = s.UpdateDeviceHeartbeat(ctx, "device-1", models.DeviceStatus{BatteryLevel: &batteryLevel}, "2026-01-02T03:04:05Z")
+		if err != nil {
+			t.Fatalf("UpdateDeviceHeartbeat: %v", err)
+		}
+		device, err = s.GetDeviceByID(ctx, "device-1")
+		if err != nil || device == nil || device.Name != "Work phone" || device.LastSeenAt != "2026-01-02T03:04:05Z" ||
+			device.Status == nil || device.Status.BatteryLevel == nil || *device.Status.BatteryLevel != 80 {
+			t.Errorf("updated device: got %+v, %v", device, err)
+		}
+
+		createDevice(t, s, "device-2")
+		devices, err := s.ListDevices(ctx)
+		if err != nil || len(devices) != 2 {
+			t.Errorf("ListDevices: got %d devices, %v, want 2", len(devices), err)
+		}
+
+		if err := s.DeleteDevice(ctx, "device-1"); err != nil {
+			t.Fatalf("DeleteDevice: %v", err)
+		}
+		if device, err := s.GetDeviceByID(ctx, "device-1"); device != nil || err != nil {
+			t.Errorf("deleted device: got %+v, %v, want nil, nil", device, err)
+		}
+		if user, err := s.GetUserByID(ctx, "user-device-1"); user != nil || err != nil {
+			t.Errorf("device user of a deleted device: got %+v, %v, want nil, nil", user, err)
+		}
+		acls, err := s.ListACLsByUserID(ctx, "user-1")
+		if err != nil || len(acls) != 1 || acls[0].DeviceID != "device-2" {
+			t.Errorf("ACL entries after deleting a device: got %+v, %v", acls, err)
+		}
+		if err := s.DeleteDevice(ctx, "device-1"); !errors.Is(err, ErrConflict) {
+			t.Errorf("DeleteDevice of a missing device: got %v, want ErrConflict", err)
+		}
+	})
+}
+
+func TestPhoneNumbers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		created := createPhoneNumber(t, s, "phone-number-1", "+15550100")
+		createPhoneNumber(t, s, "phone-number-2", "+15550101")
+
+		phoneNumber, err := s.GetPhoneNumberByID(ctx, "phone-number-1")
+		if err != nil || phoneNumber == nil || phoneNumber.PhoneNumber != "+15550100" {
+			t.Fatalf("GetPhoneNumberByID: got %+v, %v", phoneNumber, err)
+		}
+		phoneNumber, err = s.GetPhoneNumberByPhoneNumber(ctx, "+15550101")
+		if err != nil || phoneNumber == nil || phoneNumber.ID != "phone-number-2" {
+			t.Errorf("GetPhoneNumberByPhoneNumber: got %+v, %v", phoneNumber, err)
+		}
+		if phoneNumber, err := s.GetPhoneNumberByPhoneNumber(ctx, "+15550199"); phoneNumber != nil || err != nil {
+			t.Errorf("GetPhoneNumberByPhoneNumber of a missing number: got %+v, %v, want nil, nil", phoneNumber, err)
+		}
+
+		err = s.CreatePhoneNumber(ctx, &models.PhoneNumber{ID: "phone-number-3", PhoneNumber: "+15550100"},
+			&models.ACL{ID: "acl-phone-number-3", UserID: "user-1", PhoneNumberID: "phone-number-3"})
+		if !errors.Is(err, ErrPhoneNumberTaken) {
+			t.Errorf("CreatePhoneNumber of a taken number: got %v, want ErrPhoneNumberTaken", err)
+		}
+
+		update := *created
+		update.Name = "Personal"
+		if err := s.UpdatePhoneNumber(ctx, &update, created.UpdatedAt); err != nil {
+			t.Fatalf("UpdatePhoneNumber: %v", err)
+		}
+		if update.UpdatedAt == created.UpdatedAt {
+			t.Error("UpdatePhoneNumber didn't set UpdatedAt")
+		}
+		stale := *created
+		stale.Name = "Stale"
+		if err := s.UpdatePhoneNumber(ctx, &stale, created.UpdatedAt); !errors.Is(err, ErrConflict) {
+			t.Errorf("UpdatePhoneNumber with a stale UpdatedAt: got %v, want ErrConflict", err)
+		}
+		renumbered := update
+		renumbered.PhoneNumber = "+15550101"
+		if err := s.UpdatePhoneNumber(ctx, &renumbered, update.UpdatedAt); !errors.Is(err, ErrPhoneNumberTaken) {
+			t.Errorf("UpdatePhoneNumber to a taken number: got %v, want ErrPhoneNumberTaken", err)
+		}
+		phoneNumber, err = s.GetPhoneNumberByID(ctx, "phone-number-1")
+		if err != nil || phoneNumber == nil || phoneNumber.Name != "Personal" || phoneNumber.PhoneNumber != "+15550100" {
+			t.Errorf("updated phone number: got %+v, %v", phoneNumber, err)
+		}
+
+		createDevice(t, s, "device-1")
+		if err := s.AttachPhoneNumber(ctx, "device-1", "phone-number-1"); err != nil {
+			t.Fatalf("AttachPhoneNumber: %v", err)
+		}
+		createDevice(t, s, "device-2")
+		if err := s.AttachPhoneNumber(ctx, "device-2", "phone-number-1"); !errors.Is(err, ErrConflict) {
+			t.Errorf("AttachPhoneNumber of a phone number attached elsewhere: got %v, want ErrConflict", err)
+		}
+		if err := s.DeletePhoneNumber(ctx, "phone-number-1"); !errors.Is(err, ErrConflict) {
+			t.Errorf("DeletePhoneNumber of an attached phone number: got %v, want ErrConflict", err)
+		}
+		if err := s.DeleteDevice(ctx, "device-1"); !errors.Is(err, ErrConflict) {
+			t.Errorf("DeleteDevice of a device with phone numbers: got %v, want ErrConflict", err)
+		}
+		if err := s.DetachPhoneNumber(ctx, "device-1", "phone-number-1"); err != nil {
+			t.Fatalf("DetachPhoneNumber: %v", err)
+		}
+		device, err := s.GetDeviceByID(ctx, "device-1")
+		if err != nil || device == nil || len(device.PhoneNumberIDs) != 0 {
+			t.Errorf("device after DetachPhoneNumber: got %+v, %v", device, err)
+		}
+
+		if err := s.DeletePhoneNumber(ctx, "phone-number-1"); err != nil {
+			t.Fatalf("DeletePhoneNumber: %v", err)
+		}
+		if phoneNumber, err := s.GetPhoneNumberByID(ctx, "phone-number-1"); phoneNumber != nil || err != nil {
+			t.Errorf("deleted phone number: got %+v, %v, want nil, nil", phoneNumber, err)
+		}
+		acls, err := s.ListACLsByUserID(ctx, "user-1")
+		if err != nil || slices.ContainsFunc(acls, func(acl models.ACL) bool { return acl.PhoneNumberID == "phone-number-1" }) {
+			t.Errorf("ACL entries after deleting a phone number: got %+v, %v", acls, err)
+		}
+		if err := s.DeletePhoneNumber(ctx, "phone-number-1"); !errors.Is(err, ErrConflict) {
+			t.Errorf("DeletePhoneNumber of a missing phone number: got %v, want ErrConflict", err)
+		}
+		// The number of a deleted phone number can be reused
+		createPhoneNumber(t, s, "phone-number-3", "+15550100")
+	})
+}
+
+func TestListSMSByPhoneNumberID(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		var want []string // IDs newest first
+		for i := range 5 {
+			sms := &models.SMS{
+				ID:            fmt.Sprintf("sms-%d", i),
+				PhoneNumberID: "phone-number-1",
+				From:          "+15550199",
+				Body:          "hello",
+				CreatedAt:     models.FormatTimestamp(start.Add(time.Duration(i) * time.Minute)),
+			}
+			if err := s.PutSMS(ctx, sms); err != nil {
+				t.Fatalf("PutSMS: %v", err)
+			}
+			want = append([]string{sms.ID}, want...)
+		}
+		err := s.PutSMS(ctx, &models.SMS{ID: "other", PhoneNumberID: "phone-number-2", CreatedAt: models.FormatTimestamp(start)})
+		if err != nil {
+			t.Fatalf("PutSMS: %v", err)
+		}
+		if err := s.PutSMS(ctx, &models.SMS{ID: "sms-0", PhoneNumberID: "phone-number-1"}); !errors.Is(err, ErrConflict) {
+			t.Errorf("PutSMS of an existing ID: got %v, want ErrConflict", err)
+		}
+
+		var got []string
+		var pageSizes []int
+		cursor := ""
+		for {
+			page, nextCursor, err := s.ListSMSByPhoneNumberID(ctx, "phone-number-1", 2, cursor)
+			if err != nil {
+				t.Fatalf("ListSMSByPhoneNumberID: %v", err)
+			}
+			pageSizes = append(pageSizes, len(page))
+			for _, sms := range page {
+				got = append(got, sms.ID)
+			}
+			if nextCursor == "" {
+				break
+			}
+			cursor = nextCursor
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("got SMS %v, want %v", got, want)
+		}
+		if !slices.Equal(pageSizes, []int{2, 2, 1}) {
+			t.Errorf("got page sizes %v, want [2 2 1]", pageSizes)
+		}
+
+		for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} { // "not json", "{}"
+			if _, _, err := s.ListSMSByPhoneNumberID(ctx, "phone-number-1", 2, cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("cursor %q: got %v, want ErrInvalidCursor", cursor, err)
+			}
+		}
+	})
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		now := time.Now()
+		for _, token := range []*models.RefreshToken{
+			{ID: "valid", UserID: "user-1", FamilyID: "family-1", ExpiresAt: now.Add(time.Hour).Unix()},
+			{ID: "expired", UserID: "user-1", FamilyID: "family-1", ExpiresAt: now.Add(-time.Second).Unix()},
+		} {
+			if err := s.PutRefreshToken(ctx, token); err != nil {
+				t.Fatalf("PutRefreshToken: %v", err)
+			}
+		}
+
+		if token, err := s.GetRefreshToken(ctx, "valid"); err != nil || token == nil {
+			t.Errorf("GetRefreshToken of a valid token: got %+v, %v", token, err)
+		}
+		if token, err := s.GetRefreshToken(ctx, "expired"); token != nil || err != nil {
+			t.Errorf("GetRefreshToken of an expired token: got %+v, %v, want nil, nil", token, err)
+		}
+		tokens, err := s.ListRefreshTokensByUserID(ctx, "user-1")
+		if err != nil || len(tokens) != 1 || tokens[0].ID != "valid" {
+			t.Errorf("ListRefreshTokensByUserID: got %+v, %v, want only the valid token", tokens, err)
+		}
+		if used, err := s.UseRefreshToken(ctx, "expired", models.FormatTimestamp(now)); used || err != nil {
+			t.Errorf("UseRefreshToken of an expired token: got %t, %v, want false", used, err)
+		}
+		if used, err := s.UseRefreshToken(ctx, "valid", models.FormatTimestamp(now)); !used || err != nil {
+			t.Errorf("UseRefreshToken: got %t, %v, want true", used, err)
+		}
+		if used, err := s.UseRefreshToken(ctx, "valid", models.FormatTimestamp(now)); used || err != nil {
+			t.Errorf("UseRefreshToken of a used token: got %t, %v, want false", used, err)
+		}
+	})
+}
+
+func TestUpdateOutboundSMSStatus(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		err := s.PutOutboundSMS(ctx, &models.OutboundSMS{
+			ID: "outbound-sms-1", DeviceID: "device-1", To: "+15550199", Body: "hello",
+			Status: models.OutboundSMSStatusQueued,
+		})
+		if err != nil {
+			t.Fatalf("PutOutboundSMS: %v", err)
+		}
+
+		for _, status := range []string{models.OutboundSMSStatusSent, models.OutboundSMSStatusFailed} {
+			if err := s.UpdateOutboundSMSStatus(ctx, "outbound-sms-1", status, "no signal"); err != nil {
+				t.Fatalf("UpdateOutboundSMSStatus %s: %v", status, err)
+			}
+		}
+		// A final status is kept, whatever the next report says
+		for _, status := range []string{models.OutboundSMSStatusDelivered, models.OutboundSMSStatusFailed} {
+			if err := s.UpdateOutboundSMSStatus(ctx, "outbound-sms-1", status, ""); !errors.Is(err, ErrConflict) {
+				t.Errorf("UpdateOutboundSMSStatus %s of a failed SMS: got %v, want ErrConflict", status, err)
+			}
+		}
+		outboundSMS, err := s.GetOutboundSMSByID(ctx, "outbound-sms-1")
+		if err != nil || outboundSMS == nil {
+			t.Fatalf("GetOutboundSMSByID: got %+v, %v", outboundSMS, err)
+		}
+		if outboundSMS.Status != models.OutboundSMSStatusFailed || outboundSMS.Error != "no signal" {
+			t.Errorf("got status %s, error %q, want failed, no signal", outboundSMS.Status, outboundSMS.Error)
+		}
+
+		err = s.UpdateOutboundSMSStatus(ctx, "missing", models.OutboundSMSStatusSent, "")
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("UpdateOutboundSMSStatus of a missing SMS: got %v, want ErrConflict", err)
+		}
+	})
+}
+
+func TestCompletePairing(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, putUser func(models.User)) {
+		ctx := context.Background()
+		now := time.Now()
+		for _, code := range []*models.PairingCode{
+			{ID: "code-1", UserID: "user-1", DeviceName: "Phone", ExpiresAt: now.Add(time.Minute).Unix()},
+			{ID: "expired", UserID: "user-1", DeviceName: "Phone", ExpiresAt: now.Add(-time.Second).Unix()},
+		} {
+			if err := s.PutPairingCode(ctx, code); err != nil {
+				t.Fatalf("PutPairingCode: %v", err)
+			}
+		}
+		pair := func(codeID string, deviceID string, userID string) (bool, error) {
+			return s.CompletePairing(ctx, codeID,
+				&models.Device{ID: deviceID, Name: "Phone", PhoneNumberIDs: []string{}},
+				&models.User{ID: userID, Username: userID, UserType: models.UserTypeDevice, DeviceID: deviceID},
+				&models.ACL{ID: "acl-" + deviceID, UserID: "user-1", DeviceID: deviceID})
+		}
+
+		if paired, err := pair("expired", "device-1", "user-device-1"); paired || err != nil {
+			t.Errorf("expired code: got %t, %v, want false", paired, err)
+		}
+		if device, _ := s.GetDeviceByID(ctx, "device-1"); device != nil {
+			t.Error("device created with an expired code")
+		}
+
+		// Nothing is written, and the code isn't consumed, if a write of the pairing fails
+		putUser(models.User{ID: "existing", Username: "existing", UserType: models.UserTypeUser})
+		if paired, err := pair("code-1", "device-1", "existing"); paired || err == nil {
+			t.Errorf("pairing with a conflicting user: got %t, %v, want an error", paired, err)
+		}
+		if device, _ := s.GetDeviceByID(ctx, "device-1"); device != nil {
+			t.Error("device created by a failed pairing")
+		}
+		if code, err := s.GetPairingCode(ctx, "code-1"); err != nil || code == nil {
+			t.Errorf("code after a failed pairing: got %+v, %v, want it unconsumed", code, err)
+		}
+
+		if paired, err := pair("code-1", "device-1", "user-device-1"); !paired || err != nil {
+			t.Fatalf("CompletePairing: got %t, %v, want true", paired, err)
+		}
+		if device, err := s.GetDeviceByID(ctx, "device-1"); err != nil || device == nil {
+			t.Errorf("paired device: got %+v, %v", device, err)
+		}
+		if user, err := s.GetUserByID(ctx, "user-device-1"); err != nil || user == nil {
+			t.Errorf("paired device user: got %+v, %v", user, err)
+		}
+		if acls, err := s.ListACLsByUserID(ctx, "user-1"); err != nil || len(acls) != 1 || acls[0].DeviceID != "device-1" {
+			t.Errorf("ACL entries of the paired device: got %+v, %v", acls, err)
+		}
+		if code, err := s.GetPairingCode(ctx, "code-1"); code != nil || err != nil {
+			t.Errorf("consumed code: got %+v, %v, want nil, nil", code, err)
+		}
+		if paired, err := pair("code-1", "device-2", "user-device-2"); paired || err != nil {
+			t.Errorf("reused code: got %t, %v, want false", paired, err)
+		}
+	})
+}
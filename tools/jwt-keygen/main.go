@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/zhouziqunzzq/sms-relay-server/auth"
+)
+
+func main() {
+	keyID := flag.String("kid", "", "ID of the key, e.g. 2026-10")
+	algorithm := flag.String("alg", auth.AlgorithmEdDSA, "Signing algorithm, EdDSA or ES256")
+	flag.Parse()
+	if *keyID == "" {
+		log.Fatal("-kid is required")
+	}
+
+	var privateKey crypto.Signer
+	var err error
+	switch *algorithm {
+	case auth.AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case auth.AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		log.Fatalf("Unsupported algorithm %s", *algorithm)
+	}
+	if err != nil {
+		log.Fatalf("Error generating key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		log.Fatalf("Error encoding private key: %v", err)
+	}
+	key, err := json.Marshal(auth.SigningKey{
+		ID:        *keyID,
+		Algorithm: *algorithm,
+		Key:       string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	if err != nil {
+		log.Fatalf("Error encoding key: %v", err)
+	}
+	jwk, err := auth.NewJWK(*keyID, privateKey.Public())
+	if err != nil {
+		log.Fatalf("Error encoding public key: %v", err)
+	}
+	publicKey, err := json.Marshal(jwk)
+	if err != nil {
+		log.Fatalf("Error encoding public key: %v", err)
+	}
+
+	fmt.Printf("Key to add to the keys of the JWT secret: %s\n", key)
+	fmt.Printf("Public key: %s\n", publicKey)
+}